@@ -38,19 +38,42 @@ type EventMaker interface {
 		fields map[string]interface{},
 		t time.Time,
 	) optic.LogLine
+
+	MakeEvent(event optic.Event) optic.Event
+}
+
+// EventQueue receives the events added to an accumulator.
+type EventQueue interface {
+	// Push adds an event, applying the overflow policy of the queue if it is
+	// full.
+	Push(event optic.Event)
+	// TryPush adds an event if the queue isn't full, and reports whether it
+	// was added.
+	TryPush(event optic.Event) bool
 }
 
 type accumulator struct {
 	maker EventMaker
 
-	events chan optic.Event
+	events EventQueue
 
 	precision time.Duration
 }
 
+// NewAccumulator returns an accumulator which sends the events to the
+// channel, blocking while it is full.
 func NewAccumulator(
 	maker EventMaker,
 	events chan optic.Event,
+) optic.Accumulator {
+	return NewQueueAccumulator(maker, chanQueue(events))
+}
+
+// NewQueueAccumulator returns an accumulator which pushes the events to the
+// queue.
+func NewQueueAccumulator(
+	maker EventMaker,
+	events EventQueue,
 ) optic.Accumulator {
 	acc := accumulator{
 		maker:     maker,
@@ -60,8 +83,34 @@ func NewAccumulator(
 	return &acc
 }
 
+// chanQueue is an EventQueue which blocks while the channel is full.
+type chanQueue chan optic.Event
+
+func (q chanQueue) Push(event optic.Event) {
+	q <- event
+}
+
+func (q chanQueue) TryPush(event optic.Event) bool {
+	select {
+	case q <- event:
+		return true
+	default:
+		return false
+	}
+}
+
 func (ac *accumulator) AddEvent(event optic.Event) {
-	ac.events <- event
+	if e := ac.maker.MakeEvent(event); e != nil {
+		ac.events.Push(e)
+	}
+}
+
+func (ac *accumulator) TryAddEvent(event optic.Event) bool {
+	e := ac.maker.MakeEvent(event)
+	if e == nil {
+		return true
+	}
+	return ac.events.TryPush(e)
 }
 
 func (ac *accumulator) AddRaw(
@@ -72,7 +121,7 @@ func (ac *accumulator) AddRaw(
 	t ...time.Time,
 ) {
 	if e := ac.maker.MakeRaw(source, value, tags, fields, ac.getTime(t)); e != nil {
-		ac.events <- e
+		ac.events.Push(e)
 	}
 }
 
@@ -83,7 +132,7 @@ func (ac *accumulator) AddMetric(
 	t ...time.Time,
 ) {
 	if e := ac.maker.MakeMetric(name, tags, fields, optic.UntypedMetric, ac.getTime(t)); e != nil {
-		ac.events <- e
+		ac.events.Push(e)
 	}
 }
 
@@ -95,7 +144,7 @@ func (ac *accumulator) AddMetricType(
 	t ...time.Time,
 ) {
 	if e := ac.maker.MakeMetric(name, tags, fields, metricType, ac.getTime(t)); e != nil {
-		ac.events <- e
+		ac.events.Push(e)
 	}
 }
 
@@ -107,7 +156,7 @@ func (ac *accumulator) AddLogLine(
 	t ...time.Time,
 ) {
 	if e := ac.maker.MakeLogLine(path, content, tags, fields, ac.getTime(t)); e != nil {
-		ac.events <- e
+		ac.events.Push(e)
 	}
 }
 
@@ -10,7 +10,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zbiljic/pkg/metrics"
 
+	"github.com/zbiljic/optic/internal/models"
 	"github.com/zbiljic/optic/optic"
 	"github.com/zbiljic/optic/optic/logline"
 	"github.com/zbiljic/optic/optic/metric"
@@ -20,6 +22,7 @@ import (
 // Check the interfaces are satisfied
 func TestAccumulator_impl(t *testing.T) {
 	var _ optic.Accumulator = new(accumulator)
+	var _ optic.NonBlockingAccumulator = new(accumulator)
 }
 
 func TestAdd(t *testing.T) {
@@ -193,6 +196,45 @@ func TestAddCounter(t *testing.T) {
 	assert.Equal(t, testm.(optic.Metric).MetricType(), optic.CounterMetric)
 }
 
+func TestAddEvent(t *testing.T) {
+	events := make(chan optic.Event, 10)
+	defer close(events)
+	a := NewAccumulator(&TestEventMaker{}, events)
+
+	r, err := raw.New("acctest", []byte("value"), nil, nil)
+	require.NoError(t, err)
+	a.AddEvent(r)
+	a.AddEvent(nil)
+
+	testm := <-events
+	assert.Equal(t, r, testm)
+	assert.Len(t, events, 0)
+}
+
+func TestTryAddEvent(t *testing.T) {
+	events := make(chan optic.Event, 1)
+	defer close(events)
+	a := NewAccumulator(&TestEventMaker{}, events).(optic.NonBlockingAccumulator)
+
+	r, err := raw.New("acctest", []byte("value"), nil, nil)
+	require.NoError(t, err)
+	assert.True(t, a.TryAddEvent(r))
+	assert.False(t, a.TryAddEvent(r))
+	assert.Len(t, events, 1)
+}
+
+func TestQueueAccumulator(t *testing.T) {
+	queue := models.NewQueue(1, models.OverflowDropNewest, metrics.NewGauge(), metrics.NewCounter())
+	a := NewQueueAccumulator(&TestEventMaker{}, queue)
+
+	a.AddLogLine("/var/log/test.log", "first", nil, nil)
+	a.AddLogLine("/var/log/test.log", "second", nil, nil)
+
+	assert.Equal(t, int64(1), queue.Dropped.Count())
+	testm := <-queue.Events()
+	assert.Equal(t, "first", testm.(optic.LogLine).Content())
+}
+
 type TestEventMaker struct {
 }
 
@@ -250,6 +292,13 @@ func (tm *TestEventMaker) MakeLogLine(
 	return nil
 }
 
+func (tm *TestEventMaker) MakeEvent(event optic.Event) optic.Event {
+	if event == nil {
+		return nil
+	}
+	return event
+}
+
 // Check the interfaces are satisfied
 var (
 	_ EventMaker = &TestEventMaker{}
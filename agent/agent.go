@@ -156,20 +156,51 @@ func (a *Agent) Run(shutdown chan struct{}) error {
 		source.SetDefaultTags(a.Config.Tags)
 	}
 
+	// add the events forwarded to sinks to their buffers in the background
+	for _, sink := range a.Config.Sinks {
+		sink.Start()
+	}
+
 	// Start all ServiceSources
+	var services []optic.ServiceSource
 	for _, source := range a.Config.Sources {
 		switch p := source.Source.(type) {
 		case optic.ServiceSource:
-			acc := NewAccumulator(source, source.EventsCh())
+			acc := NewQueueAccumulator(source, source.Queue())
 			if err := p.Start(acc); err != nil {
 				log.Printf("ERROR Service for source %s failed to start, exiting\n%s\n",
 					source.Name(), err.Error())
+				for _, s := range services {
+					s.Stop()
+				}
 				return err
 			}
-			defer p.Stop()
+			services = append(services, p)
 		}
 	}
 
+	// service sources are stopped before the gatherers drain the queues, so
+	// the events they add while stopping are forwarded as well
+	stopped := make(chan struct{})
+	go func() {
+		<-shutdown
+		// failing sinks don't hold up the shutdown
+		for _, sink := range a.Config.Sinks {
+			sink.StopRetries()
+		}
+		for _, s := range services {
+			s.Stop()
+		}
+		close(stopped)
+	}()
+
+	// a single flusher, so every processor and sink is flushed once per tick
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.flusher(stopped)
+	}()
+
 	wg.Add(len(a.Config.Sources))
 	for _, source := range a.Config.Sources {
 		interval := a.Config.Agent.Interval
@@ -179,90 +210,22 @@ func (a *Agent) Run(shutdown chan struct{}) error {
 		}
 		go func(source *models.RunningSource, interval time.Duration) {
 			defer wg.Done()
-			a.gatherer(shutdown, source, interval)
+			a.gatherer(stopped, source, interval)
 		}(source, interval)
 	}
 
 	wg.Wait()
-	a.Close()
-	return nil
-}
 
-// flush writes a list of events to all configured sinks.
-func (a *Agent) flush() {
-	var wg sync.WaitGroup
-
-	wg.Add(len(a.Config.Processors))
-	for _, p := range a.Config.Processors {
-		go func(processor *models.RunningProcessor) {
-			defer wg.Done()
-			processor.Flush()
-		}(p)
-	}
-
-	wg.Add(len(a.Config.Sinks))
-	for _, s := range a.Config.Sinks {
-		go func(sink *models.RunningSink) {
-			defer wg.Done()
-			err := sink.Write()
-			if err != nil {
-				log.Printf("ERROR Error writing to sink [%s]: %s",
-					sink.Name(), err.Error())
-			}
-		}(s)
+	log.Println("INFO Flushing any cached events before shutdown")
+	flushProcessors(flushOrder(a.Config.Processors))
+	// write out the events still queued for the sinks
+	for _, sink := range a.Config.Sinks {
+		sink.Stop()
 	}
+	a.writeSinks()
 
-	wg.Wait()
-}
-
-func (a *Agent) flusher(
-	shutdown chan struct{},
-	eventCh chan optic.Event,
-) error {
-	// Sleep for one interval before starting flush
-	time.Sleep(a.Config.Agent.FlushInterval)
-
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			select {
-			case <-shutdown:
-				if len(eventCh) > 0 {
-					// keep going until eventCh is flushed
-					continue
-				}
-				return
-			}
-		}
-	}()
-
-	ticker := time.NewTicker(a.Config.Agent.FlushInterval)
-	semaphore := make(chan struct{}, 1)
-	for {
-		select {
-		case <-shutdown:
-			log.Println("INFO Flushing any cached events before shutdown")
-			// wait for eventCh to get flushed before flushing sinks
-			wg.Wait()
-			a.flush()
-			return nil
-		case <-ticker.C:
-			go func() {
-				select {
-				case semaphore <- struct{}{}:
-					internal.RandomSleep(a.Config.Agent.FlushJitter, shutdown)
-					a.flush()
-					<-semaphore
-				default:
-					// skipping this flush because one is already happening
-					log.Println("WARNING Skipping a scheduled flush because there is already a flush ongoing.")
-				}
-			}()
-		}
-	}
+	a.Close()
+	return nil
 }
 
 // gatherer runs the sources that have been configured with their own reporting
@@ -280,7 +243,8 @@ func (a *Agent) gatherer(
 		map[string]string{"source": source.Config.Name},
 	)
 
-	eventCh := source.EventsCh()
+	queue := source.Queue()
+	eventCh := queue.Events()
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -293,17 +257,10 @@ func (a *Agent) gatherer(
 		for {
 			select {
 			case event := <-eventCh:
-				events := []optic.Event{event}
-				for _, processor := range source.Config.Processors {
-					events = processor.Processor.Apply(event)
-					if len(events) == 0 {
-						continue
-					}
-				}
-				for _, e := range events {
-					go func(event optic.Event) {
-						source.ForwardEvent(event)
-					}(e)
+				queue.Depth.Update(int64(len(eventCh)))
+				// forward in order, as they came out of the processors
+				for _, e := range source.Process(event) {
+					source.ForwardEvent(e)
 				}
 			case <-shutdown:
 				if len(eventCh) > 0 {
@@ -317,15 +274,7 @@ func (a *Agent) gatherer(
 		}
 	}()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := a.flusher(shutdown, eventCh); err != nil {
-			log.Printf("ERROR Flusher routine failed, exiting: %s\n", err.Error())
-		}
-	}()
-
-	acc := NewAccumulator(source, eventCh)
+	acc := NewQueueAccumulator(source, queue)
 
 	for {
 		internal.RandomSleep(a.Config.Agent.CollectionJitter, shutdown)
@@ -338,10 +287,8 @@ func (a *Agent) gatherer(
 
 		select {
 		case <-shutdown:
-			log.Println("INFO Flushing any cached events before shutdown")
-			// wait for eventCh to get flushed before flushing sinks
+			// wait for eventCh to get forwarded, the agent flushes afterwards
 			wg.Wait()
-			a.flush()
 			return
 		case <-ticker.C:
 			continue
@@ -0,0 +1,222 @@
+package agent
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zbiljic/optic/internal"
+	"github.com/zbiljic/optic/internal/models"
+)
+
+// flushSchedule is a set of sinks flushed at the same interval. The schedule
+// of the agent flush interval flushes the processors as well.
+type flushSchedule struct {
+	interval time.Duration
+	jitter   time.Duration
+
+	processors []*models.RunningProcessor
+	sinks      []*models.RunningSink
+
+	// the tick the next flush belongs to, and when it happens with jitter
+	tick time.Time
+	due  time.Time
+}
+
+// start schedules the first flush one interval from now.
+func (s *flushSchedule) start(now time.Time) {
+	s.tick = now.Add(s.interval)
+	s.due = s.tick.Add(internal.RandomDuration(s.jitter))
+}
+
+// advance schedules the flush of the next tick after now, skipping the ticks
+// which were missed.
+func (s *flushSchedule) advance(now time.Time) {
+	s.tick = s.tick.Add(s.interval)
+	if !s.tick.After(now) {
+		missed := now.Sub(s.tick)/s.interval + 1
+		log.Printf("WARNING Skipping %d scheduled flushes, the previous flush took too long.", missed)
+		s.tick = s.tick.Add(missed * s.interval)
+	}
+	s.due = s.tick.Add(internal.RandomDuration(s.jitter))
+}
+
+// flushSchedules groups the sinks by their flush interval and jitter, the
+// ones without their own use the agent ones.
+func (a *Agent) flushSchedules() []*flushSchedule {
+	agentSchedule := &flushSchedule{
+		interval:   a.Config.Agent.FlushInterval,
+		jitter:     a.Config.Agent.FlushJitter,
+		processors: flushOrder(a.Config.Processors),
+	}
+	schedules := []*flushSchedule{agentSchedule}
+
+	for _, sink := range sortedSinks(a.Config.Sinks) {
+		interval, jitter := sink.Config.FlushInterval, agentSchedule.jitter
+		if interval <= 0 {
+			interval = agentSchedule.interval
+		}
+		if sink.Config.FlushJitter != nil {
+			jitter = *sink.Config.FlushJitter
+		}
+
+		var schedule *flushSchedule
+		for _, s := range schedules {
+			if s.interval == interval && s.jitter == jitter {
+				schedule = s
+				break
+			}
+		}
+		if schedule == nil {
+			schedule = &flushSchedule{interval: interval, jitter: jitter}
+			schedules = append(schedules, schedule)
+		}
+		schedule.sinks = append(schedule.sinks, sink)
+	}
+	return schedules
+}
+
+// flusher flushes the processors and sinks on their schedules, each of them
+// exactly once per tick, until shutdown.
+func (a *Agent) flusher(shutdown chan struct{}) {
+	schedules := a.flushSchedules()
+	now := time.Now()
+	for _, s := range schedules {
+		s.start(now)
+	}
+
+	// a sink is written at most once at a time
+	var writes sync.WaitGroup
+	writing := make(map[*models.RunningSink]chan struct{}, len(a.Config.Sinks))
+	for _, sink := range a.Config.Sinks {
+		writing[sink] = make(chan struct{}, 1)
+	}
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		next := schedules[0]
+		for _, s := range schedules[1:] {
+			if s.due.Before(next.due) {
+				next = s
+			}
+		}
+		timer.Reset(time.Until(next.due))
+
+		select {
+		case <-shutdown:
+			// the final flush is done by the agent, once the sources stopped
+			writes.Wait()
+			return
+		case <-timer.C:
+		}
+
+		flushProcessors(next.processors)
+		for _, sink := range next.sinks {
+			select {
+			case writing[sink] <- struct{}{}:
+				writes.Add(1)
+				go func(sink *models.RunningSink) {
+					defer writes.Done()
+					defer func() { <-writing[sink] }()
+					writeSink(sink)
+				}(sink)
+			default:
+				log.Printf("WARNING Skipping a scheduled flush of sink [%s] because there is already a flush ongoing.",
+					sink.Name())
+			}
+		}
+		next.advance(time.Now())
+	}
+}
+
+// writeSinks writes all sinks.
+func (a *Agent) writeSinks() {
+	var wg sync.WaitGroup
+	wg.Add(len(a.Config.Sinks))
+	for _, s := range a.Config.Sinks {
+		go func(sink *models.RunningSink) {
+			defer wg.Done()
+			writeSink(sink)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// flushProcessors flushes the processors in order, so the events emitted by
+// a processor reach the processors it forwards to before they are flushed.
+func flushProcessors(processors []*models.RunningProcessor) {
+	for _, processor := range processors {
+		processor.Flush()
+	}
+}
+
+func writeSink(sink *models.RunningSink) {
+	if err := sink.Write(); err != nil {
+		log.Printf("ERROR Error writing to sink [%s]: %s",
+			sink.Name(), err.Error())
+	}
+}
+
+// flushOrder orders the processors so that each one comes after all the
+// processors which forward to it. Processors in a forwarding cycle are
+// ordered by name.
+func flushOrder(processors map[string]*models.RunningProcessor) []*models.RunningProcessor {
+	names := make([]string, 0, len(processors))
+	for name := range processors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	upstream := make(map[*models.RunningProcessor]int, len(processors))
+	for _, p := range processors {
+		for _, fp := range p.Config.ForwardProcessors {
+			upstream[fp]++
+		}
+	}
+
+	ordered := make([]*models.RunningProcessor, 0, len(processors))
+	done := make(map[*models.RunningProcessor]bool, len(processors))
+	for len(ordered) < len(processors) {
+		progress := false
+		for _, name := range names {
+			p := processors[name]
+			if done[p] || upstream[p] > 0 {
+				continue
+			}
+			done[p] = true
+			ordered = append(ordered, p)
+			for _, fp := range p.Config.ForwardProcessors {
+				upstream[fp]--
+			}
+			progress = true
+		}
+		if !progress {
+			// break a cycle with the first remaining processor
+			for _, name := range names {
+				if p := processors[name]; !done[p] {
+					upstream[p] = 0
+					break
+				}
+			}
+		}
+	}
+	return ordered
+}
+
+func sortedSinks(sinks map[string]*models.RunningSink) []*models.RunningSink {
+	names := make([]string, 0, len(sinks))
+	for name := range sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sorted := make([]*models.RunningSink, 0, len(sinks))
+	for _, name := range names {
+		sorted = append(sorted, sinks[name])
+	}
+	return sorted
+}
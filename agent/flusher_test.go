@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/config"
+	"github.com/zbiljic/optic/internal/models"
+)
+
+func newTestProcessor(name string, forward ...*models.RunningProcessor) *models.RunningProcessor {
+	return &models.RunningProcessor{
+		Config: &models.ProcessorConfig{
+			Name:              name,
+			ForwardProcessors: forward,
+		},
+	}
+}
+
+func processorNames(processors []*models.RunningProcessor) []string {
+	names := make([]string, 0, len(processors))
+	for _, p := range processors {
+		names = append(names, p.Config.Name)
+	}
+	return names
+}
+
+func TestFlushOrder(t *testing.T) {
+	// a -> c -> b, d standalone
+	b := newTestProcessor("b")
+	c := newTestProcessor("c", b)
+	a := newTestProcessor("a", c)
+	d := newTestProcessor("d")
+
+	order := flushOrder(map[string]*models.RunningProcessor{
+		"a": a, "b": b, "c": c, "d": d,
+	})
+	assert.Equal(t, []string{"a", "c", "d", "b"}, processorNames(order))
+}
+
+func TestFlushOrder_Cycle(t *testing.T) {
+	// x -> y -> z -> y
+	z := newTestProcessor("z")
+	y := newTestProcessor("y", z)
+	z.Config.ForwardProcessors = []*models.RunningProcessor{y}
+	x := newTestProcessor("x", y)
+
+	order := flushOrder(map[string]*models.RunningProcessor{
+		"x": x, "y": y, "z": z,
+	})
+	assert.Equal(t, []string{"x", "y", "z"}, processorNames(order))
+}
+
+func TestFlushSchedules(t *testing.T) {
+	c := config.NewConfig()
+	c.Agent.FlushInterval = 10 * time.Second
+	c.Agent.FlushJitter = time.Second
+
+	fast := &models.RunningSink{Config: &models.SinkConfig{Name: "fast", FlushInterval: time.Second}}
+	slow := &models.RunningSink{Config: &models.SinkConfig{Name: "slow", FlushInterval: time.Minute}}
+	other := &models.RunningSink{Config: &models.SinkConfig{Name: "other", FlushInterval: time.Second}}
+	plain := &models.RunningSink{Config: &models.SinkConfig{Name: "plain"}}
+	noJitter := time.Duration(0)
+	steady := &models.RunningSink{Config: &models.SinkConfig{Name: "steady", FlushJitter: &noJitter}}
+	c.Sinks = map[string]*models.RunningSink{
+		"fast": fast, "slow": slow, "other": other, "plain": plain, "steady": steady,
+	}
+	p := newTestProcessor("p")
+	c.Processors = map[string]*models.RunningProcessor{"p": p}
+
+	a := &Agent{Config: c}
+	schedules := a.flushSchedules()
+	require.Len(t, schedules, 4)
+
+	// the agent schedule flushes the processors
+	assert.Equal(t, 10*time.Second, schedules[0].interval)
+	assert.Equal(t, time.Second, schedules[0].jitter)
+	assert.Equal(t, []*models.RunningProcessor{p}, schedules[0].processors)
+	assert.Equal(t, []*models.RunningSink{plain}, schedules[0].sinks)
+
+	assert.Equal(t, time.Second, schedules[1].interval)
+	assert.Empty(t, schedules[1].processors)
+	assert.Equal(t, []*models.RunningSink{fast, other}, schedules[1].sinks)
+
+	assert.Equal(t, time.Minute, schedules[2].interval)
+	assert.Equal(t, []*models.RunningSink{slow}, schedules[2].sinks)
+
+	// jitter turned off for the sink, not the agent one
+	assert.Equal(t, 10*time.Second, schedules[3].interval)
+	assert.Equal(t, time.Duration(0), schedules[3].jitter)
+	assert.Equal(t, []*models.RunningSink{steady}, schedules[3].sinks)
+}
+
+func TestFlushSchedule_Advance(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := &flushSchedule{interval: 10 * time.Second}
+	s.start(now)
+	assert.Equal(t, now.Add(10*time.Second), s.tick)
+	assert.Equal(t, s.tick, s.due)
+
+	// flushed on time
+	s.advance(now.Add(11 * time.Second))
+	assert.Equal(t, now.Add(20*time.Second), s.tick)
+
+	// the flush took until after the next two ticks
+	s.advance(now.Add(45 * time.Second))
+	assert.Equal(t, now.Add(50*time.Second), s.tick)
+	assert.Equal(t, s.tick, s.due)
+}
@@ -483,6 +483,26 @@ func (c *Config) buildSourceConfig(
 		}
 	}
 
+	// queue_size - OPTIONAL
+	if node, ok := config["queue_size"]; ok {
+		queueSize, err := cast.ToIntE(node)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse queue_size for source '%s': %s", name, err)
+		}
+
+		conf.QueueSize = queueSize
+	}
+
+	// overflow - OPTIONAL
+	if node, ok := config["overflow"]; ok {
+		policy, err := models.ParseOverflowPolicy(cast.ToString(node))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse overflow for source '%s': %s", name, err)
+		}
+
+		conf.Overflow = policy
+	}
+
 	// processors - OPTIONAL
 	conf.Processors = make([]*models.RunningProcessor, 0)
 	if node, ok := config["processors"]; ok {
@@ -548,6 +568,8 @@ func (c *Config) buildSourceConfig(
 	delete(config, "kind")
 	delete(config, "interval")
 	delete(config, "tags")
+	delete(config, "queue_size")
+	delete(config, "overflow")
 	delete(config, "processors")
 	delete(config, "forwards")
 	delete(config, "codec")
@@ -608,6 +630,27 @@ func (c *Config) buildSinkConfig(
 
 	conf := &models.SinkConfig{Kind: kind, Name: name}
 
+	// dead_letter - OPTIONAL
+	// resolved before the buffer is created, since the sink is built again
+	// once the referenced plugin exists
+	conf.DeadLetterProcessors = make([]*models.RunningProcessor, 0)
+	conf.DeadLetterSinks = make([]*models.RunningSink, 0)
+	if node, ok := config["dead_letter"]; ok {
+		v, err := cast.ToStringE(node)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse dead_letter for sink '%s': %s", name, err)
+		}
+		switch {
+		case c.Processors[v] != nil:
+			conf.DeadLetterProcessors = append(conf.DeadLetterProcessors, c.Processors[v])
+		case c.Sinks[v] != nil:
+			conf.DeadLetterSinks = append(conf.DeadLetterSinks, c.Sinks[v])
+		default:
+			log.Printf("TRACE Required dead letter '%s' not found", v)
+			return nil, errPluginReferenceNotFound
+		}
+	}
+
 	// batch_size - OPTIONAL
 	if node, ok := config["batch_size"]; ok {
 		batchSize, err := cast.ToIntE(node)
@@ -618,6 +661,56 @@ func (c *Config) buildSinkConfig(
 		conf.EventBatchSize = batchSize
 	}
 
+	// queue_size - OPTIONAL
+	if node, ok := config["queue_size"]; ok {
+		queueSize, err := cast.ToIntE(node)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse queue_size for sink '%s': %s", name, err)
+		}
+
+		conf.QueueSize = queueSize
+	}
+
+	// batch_bytes - OPTIONAL
+	if node, ok := config["batch_bytes"]; ok {
+		batchBytes, err := cast.ToIntE(node)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse batch_bytes for sink '%s': %s", name, err)
+		}
+
+		conf.BatchBytes = batchBytes
+	}
+
+	// batch_timeout - OPTIONAL
+	if node, ok := config["batch_timeout"]; ok {
+		dur, err := cast.ToDurationE(node)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse batch_timeout for sink '%s': %s", name, err)
+		}
+
+		conf.BatchTimeout = dur
+	}
+
+	// flush_interval - OPTIONAL
+	if node, ok := config["flush_interval"]; ok {
+		dur, err := cast.ToDurationE(node)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse flush_interval for sink '%s': %s", name, err)
+		}
+
+		conf.FlushInterval = dur
+	}
+
+	// flush_jitter - OPTIONAL
+	if node, ok := config["flush_jitter"]; ok {
+		dur, err := cast.ToDurationE(node)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse flush_jitter for sink '%s': %s", name, err)
+		}
+
+		conf.FlushJitter = &dur
+	}
+
 	// buffer - OPTIONAL
 	if bufferConfig, ok := config["buffer"]; ok {
 		bufferConfigMap, err := cast.ToStringMapE(bufferConfig)
@@ -653,10 +746,37 @@ func (c *Config) buildSinkConfig(
 		conf.Encoder = codec
 	}
 
+	// retry - OPTIONAL
+	conf.Retry = models.NewRetryConfig()
+	if retryConfig, ok := config["retry"]; ok {
+		lv := viper.New()
+		lv.Set("retry", retryConfig)
+		if err := lv.UnmarshalKey("retry", &conf.Retry); err != nil {
+			return nil, fmt.Errorf("Unable to parse retry for sink '%s': %s", name, err)
+		}
+	}
+
+	// circuit_breaker - OPTIONAL
+	if breakerConfig, ok := config["circuit_breaker"]; ok {
+		lv := viper.New()
+		lv.Set("circuit_breaker", breakerConfig)
+		if err := lv.UnmarshalKey("circuit_breaker", &conf.CircuitBreaker); err != nil {
+			return nil, fmt.Errorf("Unable to parse circuit_breaker for sink '%s': %s", name, err)
+		}
+	}
+
 	delete(config, "kind")
 	delete(config, "batch_size")
+	delete(config, "batch_bytes")
+	delete(config, "batch_timeout")
+	delete(config, "queue_size")
+	delete(config, "flush_interval")
+	delete(config, "flush_jitter")
 	delete(config, "buffer")
 	delete(config, "codec")
+	delete(config, "retry")
+	delete(config, "circuit_breaker")
+	delete(config, "dead_letter")
 
 	return conf, nil
 }
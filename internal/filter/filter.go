@@ -0,0 +1,77 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Filter matches strings against a set of patterns.
+type Filter interface {
+	Match(s string) bool
+}
+
+// Compile compiles the patterns into a Filter which matches strings matching
+// any of them. Patterns enclosed in slashes, like "/^cpu[0-9]+$/", are regular
+// expressions. All other patterns are globs, where '*' matches any sequence of
+// characters and '?' matches a single character.
+//
+// Compile returns nil if there are no patterns.
+func Compile(patterns ...string) (Filter, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	f := &filter{exact: make(map[string]struct{})}
+	for _, p := range patterns {
+		if len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %s", p, err)
+			}
+			f.regexps = append(f.regexps, re)
+			continue
+		}
+		if !strings.ContainsAny(p, "*?") {
+			f.exact[p] = struct{}{}
+			continue
+		}
+		f.regexps = append(f.regexps, regexp.MustCompile(globToRegexp(p)))
+	}
+	return f, nil
+}
+
+type filter struct {
+	exact   map[string]struct{}
+	regexps []*regexp.Regexp
+}
+
+func (f *filter) Match(s string) bool {
+	if _, ok := f.exact[s]; ok {
+		return true
+	}
+	for _, re := range f.regexps {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// globToRegexp converts a glob into an anchored regular expression.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
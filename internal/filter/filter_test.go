@@ -0,0 +1,29 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	f, err := Compile()
+	require.NoError(t, err)
+	assert.Nil(t, f)
+
+	_, err = Compile("/(/")
+	assert.Error(t, err)
+}
+
+func TestMatch(t *testing.T) {
+	f, err := Compile("cpu", "mem_*", "disk?", `/^net[0-9]+$/`, "a.b")
+	require.NoError(t, err)
+
+	for _, s := range []string{"cpu", "mem_", "mem_used", "disk0", "net0", "net12", "a.b"} {
+		assert.True(t, f.Match(s), s)
+	}
+	for _, s := range []string{"cpu0", "mem", "disk", "disk10", "net", "xnet0", "axb"} {
+		assert.False(t, f.Match(s), s)
+	}
+}
@@ -28,14 +28,8 @@ func RandomSleep(max time.Duration, shutdown chan struct{}) {
 	if max == 0 {
 		return
 	}
-	maxSleep := big.NewInt(max.Nanoseconds())
 
-	var sleepns int64
-	if j, err := rand.Int(rand.Reader, maxSleep); err == nil {
-		sleepns = j.Int64()
-	}
-
-	t := time.NewTimer(time.Nanosecond * time.Duration(sleepns))
+	t := time.NewTimer(RandomDuration(max))
 	select {
 	case <-t.C:
 		return
@@ -44,3 +38,16 @@ func RandomSleep(max time.Duration, shutdown chan struct{}) {
 		return
 	}
 }
+
+// RandomDuration returns a random duration in the range [0, max).
+func RandomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	var ns int64
+	if j, err := rand.Int(rand.Reader, big.NewInt(max.Nanoseconds())); err == nil {
+		ns = j.Int64()
+	}
+	return time.Duration(ns)
+}
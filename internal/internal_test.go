@@ -48,3 +48,11 @@ func TestRandomSleep(t *testing.T) {
 	elapsed = time.Since(s)
 	assert.True(t, elapsed < time.Millisecond*150)
 }
+
+func TestRandomDuration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), RandomDuration(0))
+	for i := 0; i < 100; i++ {
+		d := RandomDuration(time.Second)
+		assert.True(t, d >= 0 && d < time.Second, d)
+	}
+}
@@ -0,0 +1,244 @@
+package listener
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Listener listens on a datagram or stream socket for the listener sources,
+// and tracks the stream connections so they are closed along with it.
+type Listener struct {
+	// Name of the source, used in log messages.
+	Name string
+
+	// Address to listen on, e.g. "tcp://:8094", "udp://127.0.0.1:8094",
+	// "unix:///var/run/optic.sock" or "unixgram:///var/run/optic.sock".
+	Address string
+
+	// Networks which are supported, all of them if empty.
+	Networks []string
+
+	// MaxConnections limits the number of concurrent stream connections.
+	// Connections over the limit are closed right away. Zero means no limit.
+	MaxConnections int
+
+	// ReadTimeout closes stream connections which are idle for longer. Zero
+	// means no timeout.
+	ReadTimeout time.Duration
+
+	// MaxMessageSize is the maximum size of a single datagram.
+	MaxMessageSize int
+
+	// HandlePacket is called with every datagram received. The buffer is
+	// reused for the next datagram.
+	HandlePacket func(b []byte, remote net.Addr)
+
+	// HandleConn is called with every stream connection, in its own
+	// goroutine. The connection is closed once it returns, or once the
+	// listener is closed.
+	HandleConn func(conn net.Conn)
+
+	// HandleError is called with the errors which aren't caused by closing
+	// the listener, or by an idle connection timing out.
+	HandleError func(err error)
+
+	network string
+	addr    string
+
+	packetConn net.PacketConn
+	listener   net.Listener
+
+	conns map[net.Conn]struct{}
+	mu    sync.Mutex
+	wg    sync.WaitGroup
+}
+
+// Listen starts listening on the address, and handling what is received in
+// the background.
+func (l *Listener) Listen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	network, addr, err := ParseAddress(l.Address)
+	if err != nil {
+		return err
+	}
+	if !l.supports(network) {
+		return fmt.Errorf("unsupported network: %s", network)
+	}
+	l.network, l.addr = network, addr
+	l.conns = make(map[net.Conn]struct{})
+
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		if network == "unixgram" {
+			os.Remove(addr)
+		}
+		pc, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return err
+		}
+		l.packetConn = pc
+		l.wg.Add(1)
+		go l.listenPacket()
+	case "tcp", "tcp4", "tcp6", "unix":
+		if network == "unix" {
+			os.Remove(addr)
+		}
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			return err
+		}
+		l.listener = ln
+		l.wg.Add(1)
+		go l.listenStream()
+	default:
+		return fmt.Errorf("unsupported network: %s", network)
+	}
+
+	log.Printf("INFO [%s] listening on %s", l.Name, l.Address)
+	return nil
+}
+
+// Close stops listening, closes the open connections and waits for their
+// handlers to return.
+func (l *Listener) Close() {
+	l.mu.Lock()
+	if l.packetConn != nil {
+		l.packetConn.Close()
+	}
+	if l.listener != nil {
+		l.listener.Close()
+	}
+	if l.network == "unix" || l.network == "unixgram" {
+		os.Remove(l.addr)
+	}
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+
+	l.wg.Wait()
+}
+
+// Addr returns the address the listener is listening on.
+func (l *Listener) Addr() net.Addr {
+	if l.packetConn != nil {
+		return l.packetConn.LocalAddr()
+	}
+	return l.listener.Addr()
+}
+
+func (l *Listener) supports(network string) bool {
+	if len(l.Networks) == 0 {
+		return true
+	}
+	for _, n := range l.Networks {
+		if n == network {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Listener) listenPacket() {
+	defer l.wg.Done()
+
+	buf := make([]byte, l.MaxMessageSize)
+	for {
+		n, remote, err := l.packetConn.ReadFrom(buf)
+		if err != nil {
+			l.handleError(err)
+			return
+		}
+		l.HandlePacket(buf[:n], remote)
+	}
+}
+
+func (l *Listener) listenStream() {
+	defer l.wg.Done()
+
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			l.handleError(err)
+			return
+		}
+
+		l.mu.Lock()
+		if l.MaxConnections > 0 && len(l.conns) >= l.MaxConnections {
+			l.mu.Unlock()
+			log.Printf("WARNING [%s] connection limit of %d reached, rejecting %s",
+				l.Name, l.MaxConnections, conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		l.conns[conn] = struct{}{}
+		l.mu.Unlock()
+
+		l.wg.Add(1)
+		go l.handleConn(conn)
+	}
+}
+
+func (l *Listener) handleConn(conn net.Conn) {
+	defer func() {
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+		conn.Close()
+		l.wg.Done()
+	}()
+
+	if l.ReadTimeout > 0 {
+		conn = &timeoutConn{Conn: conn, timeout: l.ReadTimeout}
+	}
+	l.HandleConn(conn)
+}
+
+func (l *Listener) handleError(err error) {
+	if !IsClosed(err) && l.HandleError != nil {
+		l.HandleError(err)
+	}
+}
+
+// timeoutConn times out reads once the connection is idle for too long.
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+// ParseAddress splits the address into network and address.
+func ParseAddress(address string) (string, string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid address %q: %s", address, err)
+	}
+	switch u.Scheme {
+	case "unix", "unixgram":
+		return u.Scheme, u.Path, nil
+	case "":
+		return "", "", fmt.Errorf("missing network in address %q", address)
+	}
+	return u.Scheme, u.Host, nil
+}
+
+// IsClosed reports whether the error is caused by closing the socket, or by
+// an idle connection timing out.
+func IsClosed(err error) bool {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return true
+	}
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
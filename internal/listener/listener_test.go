@@ -0,0 +1,88 @@
+package listener
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+	}{
+		{"tcp://:8094", "tcp", ":8094"},
+		{"udp://127.0.0.1:8094", "udp", "127.0.0.1:8094"},
+		{"unix:///var/run/optic.sock", "unix", "/var/run/optic.sock"},
+		{"unixgram:///var/run/optic.sock", "unixgram", "/var/run/optic.sock"},
+	}
+	for _, tt := range tests {
+		network, addr, err := ParseAddress(tt.address)
+		require.NoError(t, err, tt.address)
+		assert.Equal(t, tt.network, network, tt.address)
+		assert.Equal(t, tt.addr, addr, tt.address)
+	}
+
+	_, _, err := ParseAddress(":8094")
+	assert.Error(t, err)
+}
+
+func TestIsClosed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l.Close()
+	_, err = l.Accept()
+	assert.True(t, IsClosed(err))
+
+	assert.False(t, IsClosed(errors.New("connection reset by peer")))
+}
+
+func TestListenUnsupportedNetwork(t *testing.T) {
+	l := &Listener{
+		Address:  "unix:///tmp/optic.sock",
+		Networks: []string{"udp", "tcp"},
+	}
+	assert.Error(t, l.Listen())
+}
+
+func TestCloseConnections(t *testing.T) {
+	done := make(chan struct{})
+	l := &Listener{
+		Address: "tcp://127.0.0.1:0",
+		HandleConn: func(conn net.Conn) {
+			// blocks until the connection is closed
+			ioutil.ReadAll(conn)
+			close(done)
+		},
+	}
+	require.NoError(t, l.Listen())
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the handler is running once the connection is tracked
+	for i := 0; i < 100; i++ {
+		l.mu.Lock()
+		n := len(l.conns)
+		l.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the open connection is closed, and its handler waited for
+	l.Close()
+	select {
+	case <-done:
+	default:
+		t.Fatal("handler still running after close")
+	}
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	// DefaultCircuitResetTimeout is the default time an open circuit waits
+	// before letting a probe through.
+	DefaultCircuitResetTimeout = 30 * time.Second
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+// Possible values for the CircuitState enum.
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerConfig defines when a failing sink stops being called.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed writes after which
+	// the circuit opens. Zero disables the circuit breaker.
+	FailureThreshold int `mapstructure:"failure_threshold"`
+
+	// ResetTimeout is the time after which an open circuit lets a single
+	// probe write through.
+	ResetTimeout time.Duration `mapstructure:"reset_timeout"`
+}
+
+// circuitBreaker stops calls to a failing sink. After FailureThreshold
+// consecutive failures the circuit opens and all calls are rejected. Once
+// ResetTimeout passes the circuit becomes half-open and the next call is let
+// through as a probe: success closes the circuit, failure opens it again.
+//
+// circuitBreaker is not safe for concurrent use.
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+
+	state    CircuitState
+	failures int
+	openedAt time.Time
+
+	// onChange is called on every state change.
+	onChange func(from, to CircuitState)
+
+	now func() time.Time
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	if config.ResetTimeout <= 0 {
+		config.ResetTimeout = DefaultCircuitResetTimeout
+	}
+	return &circuitBreaker{
+		config:   config,
+		state:    CircuitClosed,
+		onChange: func(from, to CircuitState) {},
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether a call may be made.
+func (cb *circuitBreaker) Allow() bool {
+	switch cb.state {
+	case CircuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.config.ResetTimeout {
+			return false
+		}
+		cb.setState(CircuitHalfOpen)
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records a successful call.
+func (cb *circuitBreaker) Success() {
+	cb.failures = 0
+	if cb.state != CircuitClosed {
+		cb.setState(CircuitClosed)
+	}
+}
+
+// Failure records a failed call.
+func (cb *circuitBreaker) Failure() {
+	cb.failures++
+	switch cb.state {
+	case CircuitHalfOpen:
+		cb.open()
+	case CircuitClosed:
+		if cb.failures >= cb.config.FailureThreshold {
+			cb.open()
+		}
+	}
+}
+
+func (cb *circuitBreaker) State() CircuitState {
+	return cb.state
+}
+
+func (cb *circuitBreaker) open() {
+	cb.openedAt = cb.now()
+	cb.setState(CircuitOpen)
+}
+
+func (cb *circuitBreaker) setState(state CircuitState) {
+	from := cb.state
+	cb.state = state
+	cb.onChange(from, state)
+}
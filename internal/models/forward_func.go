@@ -74,6 +74,7 @@ func forwardFunc(
 	log.Printf("INFO [%s] will not forward events anywhere.", name)
 
 	return func(event optic.Event) {
-		// no-op
+		// nothing to deliver to
+		optic.Ack(event)
 	}
 }
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zbiljic/pkg/metrics"
+
+	"github.com/zbiljic/optic/optic"
+)
+
+// OverflowPolicy selects what happens to events added to a full queue.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits until there is room in the queue.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNewest drops the added event.
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDropOldest drops the oldest event in the queue to make room.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+)
+
+// ParseOverflowPolicy returns the overflow policy with the given name.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(s); p {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+		return p, nil
+	case "":
+		return OverflowBlock, nil
+	}
+	return "", fmt.Errorf("invalid overflow policy: %s", s)
+}
+
+// Queue is a bounded queue of events between two stages of the pipeline.
+type Queue struct {
+	ch     chan optic.Event
+	policy OverflowPolicy
+
+	// serializes dropping the oldest event and adding the new one, so
+	// concurrent producers can't starve each other
+	mu sync.Mutex
+
+	Depth   metrics.Gauge
+	Dropped metrics.Counter
+}
+
+// NewQueue returns a queue of the given size, reporting its depth and drops
+// in the given gauge and counter.
+func NewQueue(
+	size int,
+	policy OverflowPolicy,
+	depth metrics.Gauge,
+	dropped metrics.Counter,
+) *Queue {
+	if size < 1 {
+		size = 1
+	}
+	if policy == "" {
+		policy = OverflowBlock
+	}
+	return &Queue{
+		ch:      make(chan optic.Event, size),
+		policy:  policy,
+		Depth:   depth,
+		Dropped: dropped,
+	}
+}
+
+// Events returns the channel the events are received from. Receivers should
+// update the Depth after receiving.
+func (q *Queue) Events() chan optic.Event {
+	return q.ch
+}
+
+// Len returns the number of events in the queue.
+func (q *Queue) Len() int {
+	return len(q.ch)
+}
+
+// Push adds an event to the queue, applying the overflow policy if it is full.
+func (q *Queue) Push(event optic.Event) {
+	switch q.policy {
+	case OverflowDropNewest:
+		if !q.TryPush(event) {
+			q.Dropped.Inc(1)
+			optic.Nack(event)
+		}
+		return
+	case OverflowDropOldest:
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		for !q.TryPush(event) {
+			select {
+			case dropped := <-q.ch:
+				q.Dropped.Inc(1)
+				optic.Nack(dropped)
+			default:
+			}
+		}
+		return
+	}
+
+	q.ch <- event
+	q.Depth.Update(int64(len(q.ch)))
+}
+
+// TryPush adds an event to the queue if it isn't full, and reports whether it
+// was added. The overflow policy isn't applied.
+func (q *Queue) TryPush(event optic.Event) bool {
+	select {
+	case q.ch <- event:
+		q.Depth.Update(int64(len(q.ch)))
+		return true
+	default:
+		return false
+	}
+}
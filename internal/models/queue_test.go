@@ -0,0 +1,79 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zbiljic/pkg/metrics"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+func newTestQueue(size int, policy OverflowPolicy) *Queue {
+	return NewQueue(size, policy, metrics.NewGauge(), metrics.NewCounter())
+}
+
+func drain(q *Queue) []string {
+	var contents []string
+	for q.Len() > 0 {
+		event := <-q.Events()
+		contents = append(contents, event.(optic.LogLine).Content())
+	}
+	return contents
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for _, s := range []string{"block", "drop_newest", "drop_oldest"} {
+		policy, err := ParseOverflowPolicy(s)
+		require.NoError(t, err)
+		assert.Equal(t, OverflowPolicy(s), policy)
+	}
+
+	policy, err := ParseOverflowPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, OverflowBlock, policy)
+
+	_, err = ParseOverflowPolicy("drop")
+	assert.Error(t, err)
+}
+
+func TestQueueDropNewest(t *testing.T) {
+	q := newTestQueue(2, OverflowDropNewest)
+	for _, s := range []string{"a", "b", "c", "d"} {
+		q.Push(testutil.TestLogLine(s))
+	}
+
+	assert.Equal(t, int64(2), q.Depth.Value())
+	assert.Equal(t, int64(2), q.Dropped.Count())
+	assert.Equal(t, []string{"a", "b"}, drain(q))
+}
+
+func TestQueueDropOldest(t *testing.T) {
+	q := newTestQueue(2, OverflowDropOldest)
+	for _, s := range []string{"a", "b", "c", "d"} {
+		q.Push(testutil.TestLogLine(s))
+	}
+
+	assert.Equal(t, int64(2), q.Depth.Value())
+	assert.Equal(t, int64(2), q.Dropped.Count())
+	assert.Equal(t, []string{"c", "d"}, drain(q))
+}
+
+func TestQueueBlock(t *testing.T) {
+	q := newTestQueue(1, OverflowBlock)
+	q.Push(testutil.TestLogLine("a"))
+	assert.False(t, q.TryPush(testutil.TestLogLine("b")))
+
+	pushed := make(chan struct{})
+	go func() {
+		q.Push(testutil.TestLogLine("b"))
+		close(pushed)
+	}()
+
+	assert.Equal(t, "a", (<-q.Events()).(optic.LogLine).Content())
+	<-pushed
+	assert.Equal(t, []string{"b"}, drain(q))
+	assert.Equal(t, int64(0), q.Dropped.Count())
+}
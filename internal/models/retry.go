@@ -0,0 +1,76 @@
+package models
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultRetryInitialInterval is the default delay before the first retry.
+	DefaultRetryInitialInterval = 500 * time.Millisecond
+	// DefaultRetryMaxInterval is the default upper bound of the retry delay.
+	DefaultRetryMaxInterval = 30 * time.Second
+	// DefaultRetryMultiplier is the default growth factor of the retry delay.
+	DefaultRetryMultiplier = 2.0
+)
+
+// RetryConfig defines how many times a failed write is attempted and how long
+// to wait between attempts.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts of a write, including the
+	// first one. Values lower than 2 disable retries.
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	// InitialInterval is the delay before the first retry.
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+
+	// MaxInterval is the upper bound of the delay between attempts.
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+
+	// Multiplier is the factor by which the delay grows after every attempt.
+	Multiplier float64 `mapstructure:"multiplier"`
+
+	// Jitter is the fraction, between 0 and 1, by which every delay is
+	// randomly shortened, so failing sinks are not retried in lockstep.
+	Jitter float64 `mapstructure:"jitter"`
+}
+
+// NewRetryConfig returns the default config, which does not retry.
+func NewRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:     1,
+		InitialInterval: DefaultRetryInitialInterval,
+		MaxInterval:     DefaultRetryMaxInterval,
+		Multiplier:      DefaultRetryMultiplier,
+	}
+}
+
+// Backoff returns the delay before the given retry, starting with 1.
+func (c RetryConfig) Backoff(retry int) time.Duration {
+	interval := c.InitialInterval
+	if interval <= 0 {
+		interval = DefaultRetryInitialInterval
+	}
+	maxInterval := c.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultRetryMaxInterval
+	}
+	multiplier := c.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	d := float64(interval) * math.Pow(multiplier, float64(retry-1))
+	if d > float64(maxInterval) {
+		d = float64(maxInterval)
+	}
+
+	if jitter := c.Jitter; jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= d * jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
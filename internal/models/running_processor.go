@@ -56,22 +56,95 @@ func (r *RunningProcessor) Name() string {
 }
 
 func (r *RunningProcessor) Apply(in ...optic.Event) []optic.Event {
+	// processors may reuse the input slice, keep the inputs if any of them
+	// is tracked
+	var inputs []optic.Event
+	for _, event := range in {
+		if event.Delivery() != nil {
+			inputs = append([]optic.Event(nil), in...)
+			break
+		}
+	}
+
 	out := r.Processor.Apply(in...)
 	diff := len(in) - len(out)
 	r.EventsProcessed.Inc(int64(len(in)))
 	r.EventsFiltered.Inc(int64(diff))
+
+	if len(inputs) > 0 {
+		resolveReplaced(inputs, out)
+	}
 	return out
 }
 
+// resolveReplaced moves the delivery of the tracked events which the
+// processor didn't pass on to the new events it returned instead, e.g. a log
+// line parsed into a metric. Without new events they were filtered, and won't
+// reach any sink, so they are acknowledged.
+func resolveReplaced(in, out []optic.Event) {
+	passed := make(map[optic.Event]bool, len(out))
+	for _, event := range out {
+		passed[event] = true
+	}
+	isInput := make(map[optic.Event]bool, len(in))
+	var consumed []*optic.Delivery
+	for _, event := range in {
+		isInput[event] = true
+		if d := event.Delivery(); d != nil && !passed[event] {
+			consumed = append(consumed, d)
+		}
+	}
+	if len(consumed) == 0 {
+		return
+	}
+
+	// copies made by the processor already share the delivery
+	var replacements []optic.Event
+	for _, event := range out {
+		if !isInput[event] && event.Delivery() == nil {
+			replacements = append(replacements, event)
+		}
+	}
+	if len(replacements) == 0 {
+		for _, d := range consumed {
+			d.Ack()
+		}
+		return
+	}
+
+	d := consumed[0]
+	if len(consumed) > 1 {
+		d = joinDeliveries(consumed)
+	}
+	d.Add(len(replacements) - 1)
+	for _, event := range replacements {
+		event.SetDelivery(d)
+	}
+}
+
+// joinDeliveries returns a delivery which resolves all of the given ones.
+func joinDeliveries(deliveries []*optic.Delivery) *optic.Delivery {
+	return optic.NewDelivery(func(delivered bool) {
+		for _, d := range deliveries {
+			if delivered {
+				d.Ack()
+			} else {
+				d.Nack()
+			}
+		}
+	})
+}
+
+// Flush applies the processor to no events, which lets it emit the events it
+// holds, e.g. aggregates, and forwards them. The processors it forwards to are
+// flushed separately, after it.
 func (r *RunningProcessor) Flush() {
 	// apply self with empty array
 	events := r.Apply([]optic.Event{}...)
 
 	switch len(events) {
 	case 0:
-		for _, ff := range r.Config.ForwardProcessors {
-			ff.Flush()
-		}
+		return
 	case 1:
 		r.forwardFunc(events[0])
 	default:
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"log"
 	"sync"
 	"time"
@@ -14,8 +15,14 @@ import (
 const (
 	// DefaultEventBatchSize is default size of events batch size.
 	DefaultEventBatchSize = 1000
+
+	// DefaultSinkQueueSize is the default number of events queued for a sink
+	// before they are added to its buffer.
+	DefaultSinkQueueSize = 1000
 )
 
+var errCircuitOpen = errors.New("circuit breaker is open")
+
 type RunningSink struct {
 	Sink   optic.Sink
 	Config *SinkConfig
@@ -24,10 +31,48 @@ type RunningSink struct {
 	BufferLimit   metrics.Gauge
 	EventsWritten metrics.Counter
 	WriteTime     metrics.Histogram
+	WriteRetries  metrics.Counter
+	CircuitState  metrics.Gauge
+	CircuitTrips  metrics.Counter
+
+	EventsRejected metrics.Counter
+	QueueDepth     metrics.Gauge
 
 	buffer optic.Buffer
 
-	// Guards against concurrent calls to the Sink
+	// nil while the sink isn't started, events are then added to the buffer
+	// directly
+	queue     chan optic.Event
+	queueDone chan struct{}
+	queueMu   sync.RWMutex
+
+	// nil if rejected events are dropped
+	deadLetterFunc func(optic.Event)
+
+	// nil if the circuit breaker is disabled
+	breaker *circuitBreaker
+
+	// when the oldest buffered event was added, and the encoded size of the
+	// buffered events, only tracked with a batch timeout and byte limit
+	oldest      time.Time
+	bufferBytes int
+	// encoded sizes of the newest buffered events, computed once when they
+	// are added; events buffered before, e.g. recovered from disk, have none
+	sizes []int
+
+	// Guards against concurrent calls to the Sink, held for the whole write
+	writing chan struct{}
+	// set while the buffered events are written; events that would evict
+	// the ones being written are held back until the write is done
+	inWrite   bool
+	held      []optic.Event
+	heldSizes []int
+
+	// closed to stop waiting for retries, e.g. on shutdown
+	stopRetries     chan struct{}
+	stopRetriesOnce sync.Once
+
+	// Guards the buffer, it isn't held while the Sink is called
 	mu sync.Mutex
 }
 
@@ -35,6 +80,9 @@ func NewRunningSink(sink optic.Sink, config *SinkConfig) *RunningSink {
 	if config.EventBatchSize <= 0 {
 		config.EventBatchSize = DefaultEventBatchSize
 	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultSinkQueueSize
+	}
 	r := &RunningSink{
 		Sink:   sink,
 		Config: config,
@@ -58,11 +106,58 @@ func NewRunningSink(sink optic.Sink, config *SinkConfig) *RunningSink {
 			"write_time_nanoseconds",
 			map[string]string{"sink": config.Name},
 		),
-		buffer: config.Buffer,
+		WriteRetries: selfmetric.GetOrRegisterCounter(
+			"sink",
+			"write_retries",
+			map[string]string{"sink": config.Name},
+		),
+		CircuitState: selfmetric.GetOrRegisterGauge(
+			"sink",
+			"circuit_state",
+			map[string]string{"sink": config.Name},
+		),
+		CircuitTrips: selfmetric.GetOrRegisterCounter(
+			"sink",
+			"circuit_trips",
+			map[string]string{"sink": config.Name},
+		),
+		EventsRejected: selfmetric.GetOrRegisterCounter(
+			"sink",
+			"events_rejected",
+			map[string]string{"sink": config.Name},
+		),
+		QueueDepth: selfmetric.GetOrRegisterGauge(
+			"sink",
+			"queue_depth",
+			map[string]string{"sink": config.Name},
+		),
+		buffer:      config.Buffer,
+		writing:     make(chan struct{}, 1),
+		stopRetries: make(chan struct{}),
+	}
+
+	if len(config.DeadLetterProcessors)+len(config.DeadLetterSinks) > 0 {
+		r.deadLetterFunc = forwardFunc(
+			r.Name(),
+			config.DeadLetterProcessors,
+			config.DeadLetterSinks,
+		)
 	}
 
 	r.BufferLimit.Update(int64(config.Buffer.Cap()))
 
+	if config.CircuitBreaker.FailureThreshold > 0 {
+		r.breaker = newCircuitBreaker(config.CircuitBreaker)
+		r.breaker.onChange = func(from, to CircuitState) {
+			log.Printf("INFO Sink [%s] circuit breaker changed from %s to %s",
+				r.Config.Name, from, to)
+			r.CircuitState.Update(int64(to))
+			if to == CircuitOpen {
+				r.CircuitTrips.Inc(1)
+			}
+		}
+	}
+
 	if r.Config.Encoder != nil {
 		// configure encoder if possible
 		if eo, ok := r.Sink.(optic.EncoderOutput); ok {
@@ -82,33 +177,294 @@ type SinkConfig struct {
 
 	EventBatchSize int
 
+	// BatchBytes limits the encoded size of a batch, a batch always has at
+	// least one event. Zero means no limit.
+	BatchBytes int
+
+	// BatchTimeout is the maximum time an event is buffered before the sink is
+	// written, independently of the flush interval. Zero disables it.
+	BatchTimeout time.Duration
+
+	// QueueSize is the number of events queued for the sink before they are
+	// added to its buffer. Forwarding blocks while the queue is full.
+	QueueSize int
+
+	// FlushInterval and FlushJitter override the ones of the agent, if set.
+	// FlushJitter is nil when it isn't set, as no jitter is a valid setting.
+	FlushInterval time.Duration
+	FlushJitter   *time.Duration
+
 	Encoder optic.Encoder
+
+	Retry          RetryConfig
+	CircuitBreaker CircuitBreakerConfig
+
+	// Events permanently rejected by the sink are forwarded to these.
+	DeadLetterProcessors []*RunningProcessor
+	DeadLetterSinks      []*RunningSink
 }
 
 func (r *RunningSink) Name() string {
 	return "sinks." + r.Config.Name
 }
 
-// WriteEvent adds an event to the sink.
+// Start starts adding the events to the buffer, and writing full batches, in
+// the background. Until then, and after Stop, WriteEvent does it itself.
+func (r *RunningSink) Start() {
+	r.queueMu.Lock()
+	defer r.queueMu.Unlock()
+	if r.queue != nil {
+		return
+	}
+
+	r.queue = make(chan optic.Event, r.Config.QueueSize)
+	r.queueDone = make(chan struct{})
+	go func(queue chan optic.Event, done chan struct{}) {
+		defer close(done)
+
+		// nil, and never ready, without a batch timeout
+		var expire <-chan time.Time
+		if r.Config.BatchTimeout > 0 {
+			ticker := time.NewTicker(batchCheckInterval(r.Config.BatchTimeout))
+			defer ticker.Stop()
+			expire = ticker.C
+		}
+
+		for {
+			select {
+			case event, ok := <-queue:
+				if !ok {
+					return
+				}
+				r.QueueDepth.Update(int64(len(queue)))
+				r.addEvent(event)
+			case now := <-expire:
+				r.mu.Lock()
+				expired := r.expired(now)
+				r.mu.Unlock()
+				if expired {
+					r.writeExpired()
+				}
+			}
+		}
+	}(r.queue, r.queueDone)
+}
+
+// batchCheckInterval returns how often the age of the buffered events is
+// checked, so they are written at most a tenth of the timeout late.
+func batchCheckInterval(timeout time.Duration) time.Duration {
+	interval := timeout / 10
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	return interval
+}
+
+// Stop adds the queued events to the buffer, and stops the background writer.
+func (r *RunningSink) Stop() {
+	r.queueMu.Lock()
+	queue, done := r.queue, r.queueDone
+	r.queue = nil
+	r.queueMu.Unlock()
+	if queue == nil {
+		return
+	}
+
+	close(queue)
+	<-done
+}
+
+// WriteEvent adds an event to the sink. While the sink is started, it blocks
+// when the queue is full.
 func (r *RunningSink) WriteEvent(event optic.Event) {
 	if event == nil {
 		return
 	}
 
+	r.queueMu.RLock()
+	if r.queue != nil {
+		r.queue <- event
+		r.QueueDepth.Update(int64(len(r.queue)))
+		r.queueMu.RUnlock()
+		return
+	}
+	r.queueMu.RUnlock()
+
+	r.addEvent(event)
+}
+
+// addEvent adds an event to the buffer, and writes it once a batch is full,
+// or the oldest buffered event timed out.
+func (r *RunningSink) addEvent(event optic.Event) {
+	now := time.Now()
+
 	r.mu.Lock()
-	r.buffer.Append(event)
+	var size []int
+	if r.Config.BatchBytes > 0 {
+		size = []int{r.eventSize(event)}
+	}
+	if r.inWrite && r.buffer.Len() >= r.buffer.Cap() {
+		// the buffer would evict events while they are written
+		r.hold(event, size)
+		r.mu.Unlock()
+		return
+	}
+	r.appendEvents([]optic.Event{event}, size)
+	if r.Config.BatchTimeout > 0 && r.oldest.IsZero() {
+		r.oldest = now
+	}
+	full := r.buffer.Len() >= r.Config.EventBatchSize
+	if r.Config.BatchBytes > 0 {
+		full = full || r.bufferBytes >= r.Config.BatchBytes
+	}
+	expired := r.expired(now)
 	r.mu.Unlock()
 
-	if r.buffer.Len() >= r.Config.EventBatchSize {
-		r.Write()
+	if full {
+		r.tryWrite()
+	} else if expired {
+		r.writeExpired()
+	}
+}
+
+// hold keeps an event until the ongoing write is done, at most as many as the
+// buffer holds. Must be called with the lock held.
+func (r *RunningSink) hold(event optic.Event, size []int) {
+	r.held = append(r.held, event)
+	r.heldSizes = append(r.heldSizes, size...)
+	if over := len(r.held) - r.buffer.Cap(); over > 0 {
+		// dropped events won't be delivered
+		optic.Nack(r.held[:over]...)
+		r.held = append(r.held[:0], r.held[over:]...)
+		if len(r.heldSizes) > 0 {
+			r.heldSizes = append(r.heldSizes[:0], r.heldSizes[over:]...)
+		}
 	}
+}
 
+// appendEvents adds events to the buffer along with their encoded sizes, if
+// tracked, and forgets the sizes of the events the buffer evicted. Must be
+// called with the lock held.
+func (r *RunningSink) appendEvents(events []optic.Event, sizes []int) {
+	n := r.buffer.Len() + len(events)
+	r.buffer.Append(events...)
+	for _, size := range sizes {
+		r.bufferBytes += size
+	}
+	r.sizes = append(r.sizes, sizes...)
+	r.removeSizes(n, n-r.buffer.Len())
 }
 
-// Write writes all cached events to this sink.
+// removeSizes forgets the sizes of the oldest count of n buffered events.
+// Must be called with the lock held.
+func (r *RunningSink) removeSizes(n, count int) {
+	// the oldest events may have no size
+	count -= n - len(r.sizes)
+	if count <= 0 {
+		return
+	}
+	if count > len(r.sizes) {
+		count = len(r.sizes)
+	}
+	for _, size := range r.sizes[:count] {
+		r.bufferBytes -= size
+	}
+	r.sizes = append(r.sizes[:0], r.sizes[count:]...)
+}
+
+// expired reports whether the oldest buffered event is older than the batch
+// timeout. Must be called with the lock held.
+func (r *RunningSink) expired(now time.Time) bool {
+	if r.Config.BatchTimeout <= 0 || r.oldest.IsZero() {
+		return false
+	}
+	return now.Sub(r.oldest) >= r.Config.BatchTimeout
+}
+
+func (r *RunningSink) writeExpired() {
+	log.Printf("DEBUG Sink [%s] batch timeout of %s expired, writing",
+		r.Config.Name, r.Config.BatchTimeout)
+	r.tryWrite()
+}
+
+// eventSize returns the size of the event encoded by the sink encoder, or
+// serialized if the sink doesn't have one.
+func (r *RunningSink) eventSize(event optic.Event) int {
+	if r.Config.Encoder != nil {
+		if b, err := r.Config.Encoder.Encode(event); err == nil {
+			return len(b)
+		}
+	}
+	return len(event.Serialize())
+}
+
+// Write writes all cached events to this sink, waiting for an ongoing write
+// to finish first.
 func (r *RunningSink) Write() error {
+	r.writing <- struct{}{}
+	defer func() { <-r.writing }()
+	return r.writeAll()
+}
+
+// tryWrite writes the cached events, unless a write is already ongoing.
+func (r *RunningSink) tryWrite() {
+	select {
+	case r.writing <- struct{}{}:
+	default:
+		return
+	}
+	defer func() { <-r.writing }()
+
+	if err := r.writeAll(); err != nil {
+		log.Printf("ERROR Error writing to sink [%s]: %s",
+			r.Config.Name, err.Error())
+	}
+}
+
+// StopRetries stops waiting to retry failed writes, e.g. on shutdown. The
+// events of failed writes stay buffered.
+func (r *RunningSink) StopRetries() {
+	r.stopRetriesOnce.Do(func() {
+		close(r.stopRetries)
+	})
+}
+
+// writeAll writes the buffered events. Must be called while writing.
+func (r *RunningSink) writeAll() error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.inWrite = true
+	rejected, err := r.writeBuffer()
+	r.inWrite = false
+	if len(r.held) > 0 {
+		r.appendEvents(r.held, r.heldSizes)
+		r.held = nil
+		r.heldSizes = nil
+	}
+	r.mu.Unlock()
+
+	// forwarded without holding the lock, since the dead letter target may
+	// forward events back to this sink
+	r.deadLetter(rejected)
+
+	return err
+}
+
+// writeBuffer writes the buffered events and returns the events which the
+// sink rejected. Must be called with the lock held.
+func (r *RunningSink) writeBuffer() ([]optic.Event, error) {
+	defer func() {
+		if r.buffer.IsEmpty() {
+			r.oldest = time.Time{}
+			r.bufferBytes = 0
+			r.sizes = nil
+		} else if !r.oldest.IsZero() {
+			// the events left time out again only after another batch
+			// timeout, so failed writes aren't retried on every check
+			r.oldest = time.Now()
+		}
+	}()
+
+	var rejected []optic.Event
 
 	nEvents := r.buffer.Len()
 	r.BufferSize.Update(int64(nEvents))
@@ -116,59 +472,225 @@ func (r *RunningSink) Write() error {
 		r.Config.Name, nEvents, r.buffer.Cap())
 
 	var (
-		batch []optic.Event
-		err   error
+		batch      []optic.Event
+		full       bool
+		incomplete bool
+		err        error
 	)
 
-	start := 0
-	end := r.Config.EventBatchSize
-
 	i := 0
 	for {
-		if i > 0 && r.buffer.Len() < r.Config.EventBatchSize {
+		batch, full, err = r.nextBatch()
+		if err != nil {
+			return rejected, err
+		}
+		// the rest of an incomplete write is written right away
+		if i > 0 && !full && !incomplete {
 			break
 		}
 		i++
 
-		batch, err = r.buffer.Slice(start, end)
+		err = r.write(batch)
+		if err == errCircuitOpen {
+			break
+		}
+		written := len(batch)
+		incomplete = false
+		var batchRejected map[int]bool
+		switch werr := err.(type) {
+		case *optic.PartialWriteError:
+			log.Printf("WARNING Sink [%s] rejected %d of %d events: %s",
+				r.Config.Name, len(werr.Rejected), len(batch), werr.Err)
+			batchRejected, rejected = rejectedEvents(batch, werr.Rejected, rejected)
+			err = nil
+		case *optic.IncompleteWriteError:
+			if werr.Written < written {
+				written = werr.Written
+				incomplete = true
+			}
+			if len(werr.Rejected) > 0 {
+				log.Printf("WARNING Sink [%s] rejected %d of %d events",
+					r.Config.Name, len(werr.Rejected), len(batch))
+			}
+			batchRejected, rejected = rejectedEvents(batch[:written], werr.Rejected, rejected)
+			err = nil
+		}
 		if err != nil {
-			return err
+			// the batch used up its retries, the rest waits for the next
+			// write instead of going through the retries as well
+			break
 		}
 
-		err = r.write(batch)
-		// If this fails, don't bother, just continue writing other events.
-		// It will be removed once new events come in.
-		if err == nil {
-			r.buffer.RemoveRange(start, end)
-			continue
+		for idx, event := range batch[:written] {
+			if !batchRejected[idx] {
+				optic.Ack(event)
+			}
 		}
-
-		// prepare for next iteration
-		start += r.Config.EventBatchSize
-		end += r.Config.EventBatchSize
-		if start > nEvents {
+		r.removeSizes(r.buffer.Len(), written)
+		r.buffer.RemoveRange(0, written)
+		if len(batch) == 0 {
 			break
 		}
 	}
 
-	return nil
+	return rejected, nil
+}
+
+// rejectedEvents appends the events of the batch at the given indices to
+// rejected, and returns the indices as a set.
+func rejectedEvents(batch []optic.Event, indices []int, rejected []optic.Event) (map[int]bool, []optic.Event) {
+	set := make(map[int]bool, len(indices))
+	for _, idx := range indices {
+		if idx >= 0 && idx < len(batch) && !set[idx] {
+			set[idx] = true
+			rejected = append(rejected, batch[idx])
+		}
+	}
+	return set, rejected
+}
+
+// nextBatch returns the oldest batch of buffered events, and whether it is
+// full, i.e. at the batch size or the byte limit. Must be called with the
+// lock held.
+func (r *RunningSink) nextBatch() ([]optic.Event, bool, error) {
+	batch, err := r.buffer.Slice(0, r.Config.EventBatchSize)
+	if err != nil {
+		return nil, false, err
+	}
+	full := len(batch) >= r.Config.EventBatchSize
+	if r.Config.BatchBytes <= 0 {
+		return batch, full, nil
+	}
+
+	// the oldest events may have no size yet
+	unsized := r.buffer.Len() - len(r.sizes)
+	size := 0
+	for i, event := range batch {
+		var n int
+		if i < unsized {
+			n = r.eventSize(event)
+		} else {
+			n = r.sizes[i-unsized]
+		}
+		if i > 0 && size+n > r.Config.BatchBytes {
+			return batch[:i], true, nil
+		}
+		size += n
+	}
+	return batch, full || size >= r.Config.BatchBytes, nil
+}
+
+// deadLetter forwards rejected events to the dead letter target, if any.
+func (r *RunningSink) deadLetter(events []optic.Event) {
+	if len(events) == 0 {
+		return
+	}
+	r.EventsRejected.Inc(int64(len(events)))
+
+	if r.deadLetterFunc == nil {
+		log.Printf("WARNING Sink [%s] dropping %d rejected events",
+			r.Config.Name, len(events))
+		optic.Nack(events...)
+		return
+	}
+	// the delivery is resolved by the dead letter target
+	for _, event := range events {
+		r.deadLetterFunc(event)
+	}
 }
 
+// write writes a batch of buffered events, retrying failed writes. Must be
+// called while writing, with the lock held; the lock is released while the
+// sink is called, and while waiting to retry.
 func (r *RunningSink) write(events []optic.Event) error {
 	count := len(events)
 	if len(events) == 0 {
 		return nil
 	}
 
+	if r.breaker != nil && !r.breaker.Allow() {
+		log.Printf("DEBUG Sink [%s] circuit breaker is open, skipping write of %d events",
+			r.Config.Name, count)
+		return errCircuitOpen
+	}
+
+	r.mu.Unlock()
 	start := time.Now()
-	err := r.Sink.Write(events)
+	err := r.sinkWrite(events)
+	for retry := 1; retryable(err) && retry < r.Config.Retry.MaxAttempts; retry++ {
+		backoff := r.Config.Retry.Backoff(retry)
+		log.Printf("DEBUG Sink [%s] write failed, retrying in %s: %s",
+			r.Config.Name, backoff, err)
+		if !r.waitRetry(backoff) {
+			log.Printf("DEBUG Sink [%s] retries stopped", r.Config.Name)
+			break
+		}
+		r.WriteRetries.Inc(1)
+		err = r.sinkWrite(events)
+	}
 	elapsed := time.Since(start)
+	r.mu.Lock()
+
+	if r.breaker != nil {
+		if !retryable(err) {
+			r.breaker.Success()
+		} else {
+			r.breaker.Failure()
+		}
+	}
+
+	switch werr := err.(type) {
+	case *optic.PartialWriteError:
+		count -= len(werr.Rejected)
+	case *optic.IncompleteWriteError:
+		log.Printf("ERROR Sink [%s] failed after writing %d of %d events: %s",
+			r.Config.Name, werr.Written, count, werr.Err)
+		count = werr.Written - len(werr.Rejected)
+	}
 
-	if err == nil {
+	if !retryable(err) {
 		log.Printf("DEBUG Sink [%s] wrote batch of %d events in %s",
 			r.Config.Name, count, elapsed)
 		r.EventsWritten.Inc(int64(count))
 		r.WriteTime.Update(elapsed.Nanoseconds())
+	} else {
+		log.Printf("ERROR Sink [%s] failed to write batch of %d events: %s",
+			r.Config.Name, count, err)
 	}
 	return err
 }
+
+// sinkWrite writes the events to the sink. An incomplete write which didn't
+// write anything is a plain failure.
+func (r *RunningSink) sinkWrite(events []optic.Event) error {
+	err := r.Sink.Write(events)
+	if ierr, ok := err.(*optic.IncompleteWriteError); ok && ierr.Written <= 0 {
+		return ierr.Err
+	}
+	return err
+}
+
+// waitRetry waits before retrying a write, and reports false if retries were
+// stopped in the meantime.
+func (r *RunningSink) waitRetry(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.stopRetries:
+		return false
+	}
+}
+
+// retryable reports whether the write failed and can be attempted again.
+// Events rejected by the sink are not retried, and neither are the events of
+// an incomplete write, the rest of them is written as the next batch.
+func retryable(err error) bool {
+	switch err.(type) {
+	case nil, *optic.PartialWriteError, *optic.IncompleteWriteError:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,433 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/buffers/memory"
+)
+
+// mockSink fails the first `failures` writes, and rejects events at indices
+// in `reject`.
+type mockSink struct {
+	sync.Mutex
+	failures int
+	reject   []int
+	calls    int
+	events   []optic.Event
+	batches  []int
+}
+
+func (*mockSink) Kind() string        { return "mock" }
+func (*mockSink) Description() string { return "" }
+func (*mockSink) Connect() error      { return nil }
+func (*mockSink) Close() error        { return nil }
+
+func (s *mockSink) Write(events []optic.Event) error {
+	s.Lock()
+	defer s.Unlock()
+	s.calls++
+	if s.failures != 0 {
+		if s.failures > 0 {
+			s.failures--
+		}
+		return errors.New("write failed")
+	}
+	if len(s.reject) > 0 {
+		for i, event := range events {
+			if !containsInt(s.reject, i) {
+				s.events = append(s.events, event)
+			}
+		}
+		return &optic.PartialWriteError{
+			Rejected: s.reject,
+			Err:      errors.New("rejected"),
+		}
+	}
+	s.events = append(s.events, events...)
+	s.batches = append(s.batches, len(events))
+	return nil
+}
+
+func containsInt(s []int, v int) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestRunningSink(t *testing.T, name string, sink optic.Sink, config *SinkConfig) *RunningSink {
+	buffer := memory.NewMemory()
+	require.NoError(t, buffer.Build())
+	config.Name = name
+	config.Buffer = buffer
+	return NewRunningSink(sink, config)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	c := RetryConfig{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      2,
+	}
+	assert.Equal(t, 100*time.Millisecond, c.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, c.Backoff(2))
+	assert.Equal(t, 400*time.Millisecond, c.Backoff(3))
+	assert.Equal(t, time.Second, c.Backoff(10))
+
+	c.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := c.Backoff(2)
+		assert.True(t, d > 100*time.Millisecond && d <= 200*time.Millisecond, d)
+	}
+}
+
+func TestRunningSinkRetry(t *testing.T) {
+	sink := &mockSink{failures: 2}
+	rs := newTestRunningSink(t, "retry", sink, &SinkConfig{
+		Retry: RetryConfig{
+			MaxAttempts:     3,
+			InitialInterval: time.Millisecond,
+		},
+	})
+
+	rs.WriteEvent(testutil.TestLogLine("a"))
+	require.NoError(t, rs.Write())
+
+	assert.Equal(t, 3, sink.calls)
+	assert.Len(t, sink.events, 1)
+	assert.Equal(t, int64(2), rs.WriteRetries.Count())
+	assert.True(t, rs.buffer.IsEmpty())
+}
+
+func TestRunningSinkRetryExhausted(t *testing.T) {
+	sink := &mockSink{failures: -1}
+	rs := newTestRunningSink(t, "retry_exhausted", sink, &SinkConfig{
+		Retry: RetryConfig{
+			MaxAttempts:     2,
+			InitialInterval: time.Millisecond,
+		},
+	})
+
+	rs.WriteEvent(testutil.TestLogLine("a"))
+	require.NoError(t, rs.Write())
+
+	assert.Equal(t, 2, sink.calls)
+	// failed events stay buffered
+	assert.Equal(t, 1, rs.buffer.Len())
+}
+
+func TestRunningSinkRetryUnlocked(t *testing.T) {
+	sink := &mockSink{failures: -1}
+	rs := newTestRunningSink(t, "retry_unlocked", sink, &SinkConfig{
+		EventBatchSize: 1,
+		Retry: RetryConfig{
+			MaxAttempts:     3,
+			InitialInterval: time.Hour,
+		},
+	})
+	rs.buffer.Append(testutil.TestLogLine("a"), testutil.TestLogLine("b"))
+
+	done := make(chan error)
+	go func() {
+		done <- rs.Write()
+	}()
+
+	// events are added while the write waits to retry
+	for {
+		sink.Lock()
+		calls := sink.calls
+		sink.Unlock()
+		if calls > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	rs.WriteEvent(testutil.TestLogLine("c"))
+	rs.StopRetries()
+	require.NoError(t, <-done)
+
+	// the first batch used up its retries, the next one isn't attempted
+	sink.Lock()
+	assert.Equal(t, 1, sink.calls)
+	sink.Unlock()
+	assert.Equal(t, 3, rs.buffer.Len())
+}
+
+func TestRunningSinkCircuitBreaker(t *testing.T) {
+	sink := &mockSink{failures: -1}
+	rs := newTestRunningSink(t, "breaker", sink, &SinkConfig{
+		CircuitBreaker: CircuitBreakerConfig{
+			FailureThreshold: 2,
+			ResetTimeout:     time.Minute,
+		},
+	})
+	now := time.Now()
+	rs.breaker.now = func() time.Time { return now }
+
+	rs.WriteEvent(testutil.TestLogLine("a"))
+	rs.Write()
+	rs.Write()
+	assert.Equal(t, 2, sink.calls)
+	assert.Equal(t, CircuitOpen, rs.breaker.State())
+	assert.Equal(t, int64(CircuitOpen), rs.CircuitState.Value())
+	assert.Equal(t, int64(1), rs.CircuitTrips.Count())
+
+	// open circuit doesn't call the sink
+	rs.Write()
+	assert.Equal(t, 2, sink.calls)
+
+	// failed probe opens the circuit again
+	now = now.Add(time.Minute)
+	rs.Write()
+	assert.Equal(t, 3, sink.calls)
+	assert.Equal(t, CircuitOpen, rs.breaker.State())
+	rs.Write()
+	assert.Equal(t, 3, sink.calls)
+
+	// successful probe closes the circuit
+	sink.failures = 0
+	now = now.Add(time.Minute)
+	rs.Write()
+	assert.Equal(t, 4, sink.calls)
+	assert.Equal(t, CircuitClosed, rs.breaker.State())
+	assert.Equal(t, int64(CircuitClosed), rs.CircuitState.Value())
+	assert.Equal(t, int64(2), rs.CircuitTrips.Count())
+	assert.True(t, rs.buffer.IsEmpty())
+}
+
+func TestRunningSinkDeadLetter(t *testing.T) {
+	dlSink := &mockSink{}
+	dl := newTestRunningSink(t, "dead_letter", dlSink, &SinkConfig{})
+
+	sink := &mockSink{reject: []int{1}}
+	rs := newTestRunningSink(t, "rejecting", sink, &SinkConfig{
+		Retry: RetryConfig{
+			MaxAttempts:     3,
+			InitialInterval: time.Millisecond,
+		},
+		DeadLetterSinks: []*RunningSink{dl},
+	})
+
+	rs.WriteEvent(testutil.TestLogLine("a"))
+	rs.WriteEvent(testutil.TestLogLine("b"))
+	rs.WriteEvent(testutil.TestLogLine("c"))
+	require.NoError(t, rs.Write())
+
+	// rejected events are not retried, the rest of the batch is acknowledged
+	assert.Equal(t, 1, sink.calls)
+	assert.True(t, rs.buffer.IsEmpty())
+	require.Len(t, sink.events, 2)
+	assert.Equal(t, "a", sink.events[0].String())
+	assert.Equal(t, "c", sink.events[1].String())
+	assert.Equal(t, int64(1), rs.EventsRejected.Count())
+
+	require.NoError(t, dl.Write())
+	require.Len(t, dlSink.events, 1)
+	assert.Equal(t, "b", dlSink.events[0].String())
+}
+
+func TestRunningSinkRejectedWithoutDeadLetter(t *testing.T) {
+	sink := &mockSink{reject: []int{0}}
+	rs := newTestRunningSink(t, "rejecting_drop", sink, &SinkConfig{})
+
+	rs.WriteEvent(testutil.TestLogLine("a"))
+	require.NoError(t, rs.Write())
+	assert.True(t, rs.buffer.IsEmpty())
+	assert.Equal(t, int64(1), rs.EventsRejected.Count())
+}
+
+func TestRunningSinkQueue(t *testing.T) {
+	sink := &mockSink{}
+	rs := newTestRunningSink(t, "queue", sink, &SinkConfig{
+		EventBatchSize: 2,
+		QueueSize:      10,
+	})
+
+	rs.Start()
+	for _, s := range []string{"a", "b", "c"} {
+		rs.WriteEvent(testutil.TestLogLine(s))
+	}
+	// the queued events are added to the buffer
+	rs.Stop()
+
+	sink.Lock()
+	require.Len(t, sink.events, 2)
+	assert.Equal(t, "a", sink.events[0].(optic.LogLine).Content())
+	assert.Equal(t, "b", sink.events[1].(optic.LogLine).Content())
+	sink.Unlock()
+	assert.Equal(t, 1, rs.buffer.Len())
+
+	// once stopped, events are added directly
+	rs.WriteEvent(testutil.TestLogLine("d"))
+	assert.Equal(t, 0, rs.buffer.Len())
+	sink.Lock()
+	assert.Len(t, sink.events, 4)
+	sink.Unlock()
+}
+
+func TestRunningSinkDelivery(t *testing.T) {
+	sink := &mockSink{reject: []int{1}}
+	rs := newTestRunningSink(t, "delivery", sink, &SinkConfig{})
+
+	results := make(map[string]bool)
+	for _, s := range []string{"a", "b"} {
+		content := s
+		event := testutil.TestLogLine(content)
+		event.SetDelivery(optic.NewDelivery(func(delivered bool) {
+			results[content] = delivered
+		}))
+		rs.WriteEvent(event)
+	}
+	assert.Empty(t, results)
+
+	require.NoError(t, rs.Write())
+	// the rejected event is nacked, without a dead letter target
+	assert.Equal(t, map[string]bool{"a": true, "b": false}, results)
+}
+
+func TestRunningSinkBatchBytes(t *testing.T) {
+	sink := &mockSink{}
+	rs := newTestRunningSink(t, "batch_bytes", sink, &SinkConfig{
+		EventBatchSize: 10,
+		BatchBytes:     10,
+	})
+
+	// log lines are serialized as their content
+	for _, s := range []string{"aaaa", "bbbb", "cccccc"} {
+		rs.WriteEvent(testutil.TestLogLine(s))
+	}
+	// written once the buffered events reach the limit, in batches within it
+	assert.Equal(t, []int{2}, sink.batches)
+	assert.Equal(t, 1, rs.buffer.Len())
+
+	rs.WriteEvent(testutil.TestLogLine("dd"))
+	rs.WriteEvent(testutil.TestLogLine("e"))
+	assert.Equal(t, []int{2}, sink.batches)
+
+	require.NoError(t, rs.Write())
+	assert.Equal(t, []int{2, 3}, sink.batches)
+	assert.True(t, rs.buffer.IsEmpty())
+}
+
+// countingEncoder encodes events as their string, and counts the calls.
+type countingEncoder struct {
+	calls int
+}
+
+func (e *countingEncoder) Encode(event optic.Event) ([]byte, error) {
+	e.calls++
+	return []byte(event.String()), nil
+}
+
+func (e *countingEncoder) EncodeTo(event optic.Event, dst []byte) error {
+	_, err := e.Encode(event)
+	return err
+}
+
+func TestRunningSinkBatchBytesEviction(t *testing.T) {
+	sink := &mockSink{}
+	encoder := &countingEncoder{}
+	rs := newTestRunningSink(t, "batch_bytes_eviction", sink, &SinkConfig{
+		EventBatchSize: 10,
+		BatchBytes:     100,
+		Encoder:        encoder,
+	})
+	rs.buffer.(*memory.Memory).Limit = 2
+
+	for _, s := range []string{"aaaa", "bbbb", "cc"} {
+		rs.WriteEvent(testutil.TestLogLine(s))
+	}
+	// the size of the evicted event no longer counts
+	assert.Equal(t, 2, rs.buffer.Len())
+	assert.Equal(t, 6, rs.bufferBytes)
+
+	// every event is encoded once, when it is added
+	require.NoError(t, rs.Write())
+	assert.Equal(t, []int{2}, sink.batches)
+	assert.Equal(t, 3, encoder.calls)
+	assert.Equal(t, 0, rs.bufferBytes)
+}
+
+func TestRunningSinkBatchTimeout(t *testing.T) {
+	sink := &mockSink{}
+	rs := newTestRunningSink(t, "batch_timeout", sink, &SinkConfig{
+		EventBatchSize: 10,
+		BatchTimeout:   10 * time.Millisecond,
+	})
+
+	rs.WriteEvent(testutil.TestLogLine("a"))
+	assert.Empty(t, sink.events)
+
+	// the next event finds the oldest one timed out
+	time.Sleep(20 * time.Millisecond)
+	rs.WriteEvent(testutil.TestLogLine("b"))
+	assert.Len(t, sink.events, 2)
+	assert.True(t, rs.buffer.IsEmpty())
+
+	// while started, the age is checked in the background
+	rs.Start()
+	defer rs.Stop()
+	rs.WriteEvent(testutil.TestLogLine("c"))
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		sink.Lock()
+		n := len(sink.events)
+		sink.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	sink.Lock()
+	assert.Len(t, sink.events, 3)
+	sink.Unlock()
+}
+
+// incompleteSink fails after writing the first `written` events of the first
+// write.
+type incompleteSink struct {
+	mockSink
+	written int
+}
+
+func (s *incompleteSink) Write(events []optic.Event) error {
+	s.Lock()
+	if n := s.written; n > 0 && n < len(events) {
+		s.written = 0
+		s.calls++
+		s.events = append(s.events, events[:n]...)
+		s.Unlock()
+		return &optic.IncompleteWriteError{Written: n, Err: errors.New("write failed")}
+	}
+	s.Unlock()
+	return s.mockSink.Write(events)
+}
+
+func TestRunningSinkIncompleteWrite(t *testing.T) {
+	sink := &incompleteSink{written: 1}
+	rs := newTestRunningSink(t, "incomplete", sink, &SinkConfig{})
+
+	for _, s := range []string{"a", "b", "c"} {
+		rs.WriteEvent(testutil.TestLogLine(s))
+	}
+	require.NoError(t, rs.Write())
+
+	// the written events aren't written again, the rest is written right away
+	assert.Equal(t, 2, sink.calls)
+	require.Len(t, sink.events, 3)
+	assert.Equal(t, "a", sink.events[0].String())
+	assert.Equal(t, "b", sink.events[1].String())
+	assert.Equal(t, "c", sink.events[2].String())
+	assert.True(t, rs.buffer.IsEmpty())
+}
@@ -15,7 +15,9 @@ import (
 )
 
 const (
-	defaultEventChannelBufferSize = 100
+	// DefaultSourceQueueSize is the default number of events queued between a
+	// source and its processors.
+	DefaultSourceQueueSize = 100
 )
 
 var (
@@ -29,24 +31,42 @@ type RunningSource struct {
 	trace       bool // only used by 'test' command
 	defaultTags map[string]string
 
-	eventsCh chan optic.Event
+	queue *Queue
 
 	forwardFunc func(optic.Event)
 
 	EventsProcessed metrics.Counter
+	EventsDropped   metrics.Counter
+	QueueDepth      metrics.Gauge
 }
 
 func NewRunningSource(source optic.Source, config *SourceConfig) *RunningSource {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultSourceQueueSize
+	}
+	if config.Overflow == "" {
+		config.Overflow = OverflowBlock
+	}
 	r := &RunningSource{
-		Source:   source,
-		Config:   config,
-		eventsCh: make(chan optic.Event, defaultEventChannelBufferSize),
+		Source: source,
+		Config: config,
 		EventsProcessed: selfmetric.GetOrRegisterCounter(
 			"sources",
 			"events_processed",
 			map[string]string{"source": config.Name},
 		),
+		EventsDropped: selfmetric.GetOrRegisterCounter(
+			"sources",
+			"events_dropped",
+			map[string]string{"source": config.Name},
+		),
+		QueueDepth: selfmetric.GetOrRegisterGauge(
+			"sources",
+			"queue_depth",
+			map[string]string{"source": config.Name},
+		),
 	}
+	r.queue = NewQueue(config.QueueSize, config.Overflow, r.QueueDepth, r.EventsDropped)
 
 	r.forwardFunc = forwardFunc(
 		r.Name(),
@@ -74,6 +94,11 @@ type SourceConfig struct {
 
 	Decoder optic.Decoder
 
+	// QueueSize is the number of events queued between the source and its
+	// processors, and Overflow what happens to events when it is full.
+	QueueSize int
+	Overflow  OverflowPolicy
+
 	Processors []*RunningProcessor
 
 	ForwardProcessors []*RunningProcessor
@@ -97,7 +122,30 @@ func (r *RunningSource) SetDefaultTags(tags map[string]string) {
 }
 
 func (r *RunningSource) EventsCh() chan optic.Event {
-	return r.eventsCh
+	return r.queue.Events()
+}
+
+// Queue returns the queue of events between the source and its processors.
+func (r *RunningSource) Queue() *Queue {
+	return r.queue
+}
+
+// Process applies the source-local processors in order, each one to the output
+// of the previous one, and returns the events which passed all of them.
+func (r *RunningSource) Process(event optic.Event) []optic.Event {
+	if event == nil {
+		return nil
+	}
+
+	events := []optic.Event{event}
+	for _, processor := range r.Config.Processors {
+		events = processor.Apply(events...)
+		if len(events) == 0 {
+			// filtered, the rest of the chain isn't applied
+			return nil
+		}
+	}
+	return events
 }
 
 // ForwardEvent adds an event to the source to be forwarded.
@@ -193,6 +241,36 @@ func (r *RunningSource) MakeLogLine(
 	return logline
 }
 
+// MakeEvent applies the plugin-wide and daemon-wide tags to an event which
+// the source created by itself, e.g. with a decoder.
+func (r *RunningSource) MakeEvent(event optic.Event) optic.Event {
+	if event == nil {
+		return nil
+	}
+
+	// Apply plugin-wide tags if set
+	for k, v := range r.Config.Tags {
+		if !event.HasTag(k) {
+			event.AddTag(k, v)
+		}
+	}
+	// Apply daemon-wide tags if set
+	for k, v := range r.defaultTags {
+		if !event.HasTag(k) {
+			event.AddTag(k, v)
+		}
+	}
+
+	if r.trace {
+		fmt.Print("> " + event.String())
+	}
+
+	GlobalEventsProcessed.Inc(1)
+	r.EventsProcessed.Inc(1)
+
+	return event
+}
+
 func makeRaw(
 	source string,
 	value []byte,
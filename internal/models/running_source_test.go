@@ -0,0 +1,135 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/logline"
+	"github.com/zbiljic/optic/optic/metric"
+	"github.com/zbiljic/optic/plugins/processors/grok"
+)
+
+// mockProcessor tags events with its name, and drops the ones tagged with
+// the drop tag.
+type mockProcessor struct {
+	name  string
+	drop  string
+	calls int
+}
+
+func (*mockProcessor) Kind() string        { return "mock" }
+func (*mockProcessor) Description() string { return "" }
+func (*mockProcessor) Init() error         { return nil }
+
+func (p *mockProcessor) Apply(in ...optic.Event) []optic.Event {
+	p.calls++
+	out := in[:0]
+	for _, event := range in {
+		if p.drop != "" && event.HasTag(p.drop) {
+			continue
+		}
+		event.AddTag(p.name, "true")
+		out = append(out, event)
+	}
+	return out
+}
+
+func newTestRunningProcessor(name string, processor optic.Processor) *RunningProcessor {
+	return NewRunningProcessor(processor, &ProcessorConfig{Kind: "mock", Name: name})
+}
+
+func TestRunningSourceProcess(t *testing.T) {
+	first := &mockProcessor{name: "first"}
+	second := &mockProcessor{name: "second", drop: "drop"}
+	third := &mockProcessor{name: "third"}
+
+	rs := NewRunningSource(nil, &SourceConfig{
+		Name: "TestRunningSourceProcess",
+		Processors: []*RunningProcessor{
+			newTestRunningProcessor("TestRunningSourceProcess_first", first),
+			newTestRunningProcessor("TestRunningSourceProcess_second", second),
+			newTestRunningProcessor("TestRunningSourceProcess_third", third),
+		},
+	})
+
+	m, err := metric.New("test", nil, map[string]interface{}{"value": 1}, time.Now())
+	require.NoError(t, err)
+
+	// every stage receives the output of the previous one
+	events := rs.Process(m)
+	require.Len(t, events, 1)
+	assert.Equal(t, map[string]string{"first": "true", "second": "true", "third": "true"}, events[0].Tags())
+
+	// filtered events stop the chain
+	m, err = metric.New("test", map[string]string{"drop": "true"}, map[string]interface{}{"value": 1}, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, rs.Process(m))
+	assert.Equal(t, 2, first.calls)
+	assert.Equal(t, 2, second.calls)
+	assert.Equal(t, 1, third.calls)
+
+	// the counters of the running processors are updated
+	assert.Equal(t, int64(2), rs.Config.Processors[1].EventsProcessed.Count())
+	assert.Equal(t, int64(1), rs.Config.Processors[1].EventsFiltered.Count())
+}
+
+func TestRunningSourceProcessDelivery(t *testing.T) {
+	rs := NewRunningSource(nil, &SourceConfig{
+		Name: "TestRunningSourceProcessDelivery",
+		Processors: []*RunningProcessor{
+			newTestRunningProcessor("TestRunningSourceProcessDelivery", &mockProcessor{name: "mock", drop: "drop"}),
+		},
+	})
+
+	var calls int
+	var delivered bool
+	m, err := metric.New("test", map[string]string{"drop": "true"}, map[string]interface{}{"value": 1}, time.Now())
+	require.NoError(t, err)
+	m.SetDelivery(optic.NewDelivery(func(ok bool) {
+		calls++
+		delivered = ok
+	}))
+
+	// filtered events won't reach any sink
+	assert.Empty(t, rs.Process(m))
+	assert.Equal(t, 1, calls)
+	assert.True(t, delivered)
+}
+
+func TestRunningSourceProcessDeliveryReplaced(t *testing.T) {
+	g := grok.NewGrok().(*grok.Grok)
+	g.Patterns = []string{"%{WORD:method} %{NUMBER:took:float}"}
+	g.MetricName = "request"
+	require.NoError(t, g.Init())
+
+	rs := NewRunningSource(nil, &SourceConfig{
+		Name: "TestRunningSourceProcessDeliveryReplaced",
+		Processors: []*RunningProcessor{
+			newTestRunningProcessor("TestRunningSourceProcessDeliveryReplaced", g),
+		},
+	})
+
+	var calls int
+	var delivered bool
+	ll, err := logline.New("/var/log/test.log", "GET 1.5", nil, nil)
+	require.NoError(t, err)
+	ll.SetDelivery(optic.NewDelivery(func(ok bool) {
+		calls++
+		delivered = ok
+	}))
+
+	// the metric parsed from the log line carries its delivery
+	out := rs.Process(ll)
+	require.Len(t, out, 1)
+	_, ok := out[0].(optic.Metric)
+	require.True(t, ok)
+	assert.Equal(t, 0, calls)
+
+	optic.Ack(out...)
+	assert.Equal(t, 1, calls)
+	assert.True(t, delivered)
+}
@@ -0,0 +1,78 @@
+package prometheus
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Field names used for the values of Prometheus metrics.
+const (
+	CounterField = "counter"
+	GaugeField   = "gauge"
+	UntypedField = "value"
+	CountField   = "count"
+	SumField     = "sum"
+
+	BucketPrefix   = "bucket_"
+	QuantilePrefix = "quantile_"
+)
+
+// BucketField returns the field name of the histogram bucket with the given
+// upper bound, e.g. "bucket_0_5" for 0.5 or "bucket_inf" for +Inf.
+func BucketField(le float64) string {
+	return BucketPrefix + encodeBound(le)
+}
+
+// QuantileField returns the field name of the summary quantile, e.g.
+// "quantile_0_99" for 0.99.
+func QuantileField(q float64) string {
+	return QuantilePrefix + encodeBound(q)
+}
+
+// ParseBucketField returns the upper bound of the bucket field, and whether
+// the name is a bucket field at all.
+func ParseBucketField(field string) (float64, bool) {
+	return parseField(field, BucketPrefix)
+}
+
+// ParseQuantileField returns the quantile of the quantile field, and whether
+// the name is a quantile field at all.
+func ParseQuantileField(field string) (float64, bool) {
+	return parseField(field, QuantilePrefix)
+}
+
+// encodeBound formats the value so it can be used in a field name: "." is
+// replaced by "_", a minus sign by "neg" and infinity by "inf".
+func encodeBound(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "neginf"
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	s = strings.Replace(s, "+", "", -1)
+	s = strings.Replace(s, "-", "neg", -1)
+	return strings.Replace(s, ".", "_", -1)
+}
+
+func parseField(field, prefix string) (float64, bool) {
+	if !strings.HasPrefix(field, prefix) {
+		return 0, false
+	}
+	s := strings.TrimPrefix(field, prefix)
+	switch s {
+	case "inf":
+		return math.Inf(1), true
+	case "neginf":
+		return math.Inf(-1), true
+	}
+	s = strings.Replace(s, "neg", "-", -1)
+	s = strings.Replace(s, "_", ".", -1)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
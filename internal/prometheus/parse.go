@@ -0,0 +1,392 @@
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+)
+
+// Metric types as they appear in "# TYPE" lines.
+const (
+	typeCounter        = "counter"
+	typeGauge          = "gauge"
+	typeHistogram      = "histogram"
+	typeGaugeHistogram = "gaugehistogram"
+	typeSummary        = "summary"
+	typeUntyped        = "untyped"
+	typeUnknown        = "unknown"
+	typeInfo           = "info"
+	typeStateSet       = "stateset"
+)
+
+// suffixes of samples which belong to a family, per metric type
+var familySuffixes = map[string][]string{
+	typeCounter:        {"_total", "_created"},
+	typeHistogram:      {"_bucket", "_count", "_sum", "_created"},
+	typeGaugeHistogram: {"_bucket", "_gcount", "_gsum"},
+	typeSummary:        {"_count", "_sum", "_created"},
+	typeInfo:           {"_info"},
+}
+
+// Parse parses metrics in the Prometheus text exposition format, or in the
+// OpenMetrics format if openMetrics is set.
+//
+// Counters, gauges and untyped samples become metrics with a single "counter",
+// "gauge" or "value" field. All samples of a histogram or summary with the
+// same labels are joined into one metric, with "count" and "sum" fields and a
+// field for every bucket or quantile. Samples without a timestamp get now.
+func Parse(buf []byte, openMetrics bool, now time.Time) ([]optic.Metric, error) {
+	p := &parser{
+		openMetrics: openMetrics,
+		now:         now,
+		types:       make(map[string]string),
+		groups:      make(map[string]*group),
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if err := p.parseLine(line); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	metrics := make([]optic.Metric, 0, len(p.order))
+	for _, g := range p.order {
+		m, err := metric.New(g.name, g.tags, g.fields, g.ts, g.metricType)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+type parser struct {
+	openMetrics bool
+	now         time.Time
+
+	// metric types by family name
+	types map[string]string
+
+	groups map[string]*group
+	order  []*group
+}
+
+// group collects all samples which end up in a single metric.
+type group struct {
+	name       string
+	tags       map[string]string
+	fields     map[string]interface{}
+	ts         time.Time
+	metricType optic.MetricType
+}
+
+func (p *parser) parseLine(line string) error {
+	if line == "" {
+		return nil
+	}
+	if line[0] == '#' {
+		p.parseComment(line)
+		return nil
+	}
+
+	name, labels, value, ts, err := p.parseSample(line)
+	if err != nil {
+		return err
+	}
+
+	family, typ, suffix := p.resolve(name)
+
+	var (
+		metricName = name
+		field      string
+		metricType optic.MetricType
+	)
+	switch typ {
+	case typeCounter:
+		if suffix == "_created" {
+			return nil
+		}
+		field, metricType = CounterField, optic.CounterMetric
+	case typeGauge, typeStateSet:
+		field, metricType = GaugeField, optic.GaugeMetric
+	case typeHistogram, typeGaugeHistogram:
+		metricName, metricType = family, optic.HistogramMetric
+		switch suffix {
+		case "_bucket":
+			le, err := parseBound(labels, "le")
+			if err != nil {
+				return err
+			}
+			field = BucketField(le)
+		case "_count", "_gcount":
+			field = CountField
+		case "_sum", "_gsum":
+			field = SumField
+		default:
+			return nil
+		}
+	case typeSummary:
+		metricName, metricType = family, optic.SummaryMetric
+		switch suffix {
+		case "":
+			q, err := parseBound(labels, "quantile")
+			if err != nil {
+				return err
+			}
+			field = QuantileField(q)
+		case "_count":
+			field = CountField
+		case "_sum":
+			field = SumField
+		default:
+			return nil
+		}
+	default:
+		field, metricType = UntypedField, optic.UntypedMetric
+	}
+
+	key := groupKey(metricName, labels)
+	g, ok := p.groups[key]
+	if !ok {
+		g = &group{
+			name:       metricName,
+			tags:       labels,
+			fields:     make(map[string]interface{}),
+			ts:         ts,
+			metricType: metricType,
+		}
+		p.groups[key] = g
+		p.order = append(p.order, g)
+	}
+	g.fields[field] = value
+	return nil
+}
+
+// parseComment records metric types, all other comments are ignored.
+func (p *parser) parseComment(line string) {
+	parts := strings.Fields(line)
+	if len(parts) < 4 || parts[0] != "#" || parts[1] != "TYPE" {
+		return
+	}
+	typ := strings.ToLower(parts[3])
+	if typ == typeUnknown {
+		typ = typeUntyped
+	}
+	p.types[parts[2]] = typ
+}
+
+// resolve returns the family the sample belongs to, its type and the suffix
+// of the sample name.
+func (p *parser) resolve(name string) (string, string, string) {
+	if typ, ok := p.types[name]; ok {
+		return name, typ, ""
+	}
+	for typ, suffixes := range familySuffixes {
+		for _, suffix := range suffixes {
+			if !strings.HasSuffix(name, suffix) {
+				continue
+			}
+			family := strings.TrimSuffix(name, suffix)
+			if p.types[family] == typ {
+				return family, typ, suffix
+			}
+		}
+	}
+	return name, typeUntyped, ""
+}
+
+func (p *parser) parseSample(line string) (string, map[string]string, float64, time.Time, error) {
+	i := 0
+	for i < len(line) && line[i] != '{' && line[i] != ' ' && line[i] != '\t' {
+		i++
+	}
+	name := line[:i]
+	if name == "" {
+		return "", nil, 0, time.Time{}, fmt.Errorf("missing metric name")
+	}
+
+	labels := make(map[string]string)
+	rest := line[i:]
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		labels, rest, err = parseLabels(rest[1:])
+		if err != nil {
+			return "", nil, 0, time.Time{}, err
+		}
+	}
+
+	// drop OpenMetrics exemplars
+	if j := strings.Index(rest, " # "); j >= 0 {
+		rest = rest[:j]
+	}
+
+	parts := strings.Fields(rest)
+	if len(parts) < 1 || len(parts) > 2 {
+		return "", nil, 0, time.Time{}, fmt.Errorf("invalid sample: %s", line)
+	}
+
+	value, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return "", nil, 0, time.Time{}, fmt.Errorf("invalid value: %s", parts[0])
+	}
+
+	ts := p.now
+	if len(parts) == 2 {
+		if ts, err = p.parseTimestamp(parts[1]); err != nil {
+			return "", nil, 0, time.Time{}, err
+		}
+	}
+
+	return name, labels, value, ts, nil
+}
+
+// parseTimestamp parses milliseconds in the text format and seconds in
+// OpenMetrics.
+func (p *parser) parseTimestamp(s string) (time.Time, error) {
+	if p.openMetrics {
+		// seconds and fraction are parsed separately to avoid float rounding
+		if sec, frac, ok := splitDecimal(s); ok {
+			return time.Unix(sec, frac), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp: %s", s)
+		}
+		return time.Unix(0, int64(f*float64(time.Second))), nil
+	}
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %s", s)
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
+
+// splitDecimal splits a plain decimal number of seconds into seconds and
+// nanoseconds.
+func splitDecimal(s string) (int64, int64, bool) {
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	sec, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || sec < 0 || len(fracPart) > 9 {
+		return 0, 0, false
+	}
+	if fracPart == "" {
+		return sec, 0, true
+	}
+	frac, err := strconv.ParseInt((fracPart + "000000000")[:9], 10, 64)
+	if err != nil || frac < 0 {
+		return 0, 0, false
+	}
+	return sec, frac, true
+}
+
+// parseLabels parses labels up to and including the closing brace, and
+// returns the rest of the line.
+func parseLabels(s string) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, "", fmt.Errorf("unterminated label set")
+		}
+		if s[i] == '}' {
+			return labels, s[i+1:], nil
+		}
+
+		start := i
+		for i < len(s) && s[i] != '=' && s[i] != ' ' {
+			i++
+		}
+		key := s[start:i]
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if key == "" || i >= len(s) || s[i] != '=' {
+			return nil, "", fmt.Errorf("invalid label: %s", s[start:])
+		}
+		i++
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i >= len(s) || s[i] != '"' {
+			return nil, "", fmt.Errorf("missing value of label %s", key)
+		}
+		i++
+
+		var value []byte
+		for {
+			if i >= len(s) {
+				return nil, "", fmt.Errorf("unterminated value of label %s", key)
+			}
+			c := s[i]
+			if c == '"' {
+				i++
+				break
+			}
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					c = '\n'
+				default:
+					c = s[i]
+				}
+			}
+			value = append(value, c)
+			i++
+		}
+		labels[key] = string(value)
+	}
+}
+
+// parseBound removes the label from labels and returns its value.
+func parseBound(labels map[string]string, key string) (float64, error) {
+	s, ok := labels[key]
+	if !ok {
+		return 0, fmt.Errorf("missing %s label", key)
+	}
+	delete(labels, key)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s label: %s", key, s)
+	}
+	return v, nil
+}
+
+func groupKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
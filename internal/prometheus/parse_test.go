@@ -0,0 +1,188 @@
+package prometheus
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/optic"
+)
+
+const textFormat = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+
+# A metric without a type
+untyped_metric{path="C:\\dir\\",msg="say \"hi\"\n"} -1.5
+
+# TYPE temperature gauge
+temperature 21.5
+
+# HELP http_request_duration_seconds A histogram of the request duration.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.05"} 24054
+http_request_duration_seconds_bucket{le="0.1"} 33444
+http_request_duration_seconds_bucket{le="+Inf"} 144320
+http_request_duration_seconds_sum 53423
+http_request_duration_seconds_count 144320
+
+# HELP rpc_duration_seconds A summary of the RPC duration in seconds.
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{service="a",quantile="0.5"} 4773
+rpc_duration_seconds{service="a",quantile="0.99"} 76656
+rpc_duration_seconds_sum{service="a"} 1.7560473e+07
+rpc_duration_seconds_count{service="a"} 2693
+rpc_duration_seconds{service="b",quantile="0.5"} 1
+rpc_duration_seconds_sum{service="b"} 2
+rpc_duration_seconds_count{service="b"} 3
+`
+
+const openMetricsFormat = `# TYPE acme_http_router_request_seconds histogram
+# UNIT acme_http_router_request_seconds seconds
+acme_http_router_request_seconds_bucket{path="/api/v1",le="0.5"} 1 # {trace_id="abc"} 0.3 1520879607.789
+acme_http_router_request_seconds_bucket{path="/api/v1",le="+Inf"} 2
+acme_http_router_request_seconds_sum{path="/api/v1"} 1.5
+acme_http_router_request_seconds_count{path="/api/v1"} 2
+acme_http_router_request_seconds_created{path="/api/v1"} 1520430000.123
+# TYPE process_cpu_seconds counter
+process_cpu_seconds_total 4.2 1520879607.789
+process_cpu_seconds_created 1520430000.123
+# TYPE build info
+build_info{version="1.0"} 1
+# EOF
+`
+
+func find(metrics []optic.Metric, name string, tags map[string]string) optic.Metric {
+	for _, m := range metrics {
+		if m.Name() != name {
+			continue
+		}
+		match := true
+		for k, v := range tags {
+			if m.Tags()[k] != v {
+				match = false
+			}
+		}
+		if match {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestParseText(t *testing.T) {
+	now := time.Now()
+	metrics, err := Parse([]byte(textFormat), false, now)
+	require.NoError(t, err)
+	require.Len(t, metrics, 7)
+
+	m := find(metrics, "http_requests_total", map[string]string{"code": "400"})
+	require.NotNil(t, m)
+	assert.Equal(t, optic.CounterMetric, m.MetricType())
+	assert.Equal(t, map[string]string{"method": "post", "code": "400"}, m.Tags())
+	assert.Equal(t, map[string]interface{}{"counter": float64(3)}, m.Fields())
+	assert.Equal(t, int64(1395066363000000000), m.Time().UnixNano())
+
+	m = find(metrics, "untyped_metric", nil)
+	require.NotNil(t, m)
+	assert.Equal(t, optic.UntypedMetric, m.MetricType())
+	assert.Equal(t, map[string]string{"path": `C:\dir\`, "msg": "say \"hi\"\n"}, m.Tags())
+	assert.Equal(t, map[string]interface{}{"value": float64(-1.5)}, m.Fields())
+	assert.Equal(t, now, m.Time())
+
+	m = find(metrics, "temperature", nil)
+	require.NotNil(t, m)
+	assert.Equal(t, optic.GaugeMetric, m.MetricType())
+	assert.Equal(t, map[string]interface{}{"gauge": float64(21.5)}, m.Fields())
+
+	m = find(metrics, "http_request_duration_seconds", nil)
+	require.NotNil(t, m)
+	assert.Equal(t, optic.HistogramMetric, m.MetricType())
+	assert.Equal(t, map[string]string{}, m.Tags())
+	assert.Equal(t, map[string]interface{}{
+		"bucket_0_05": float64(24054),
+		"bucket_0_1":  float64(33444),
+		"bucket_inf":  float64(144320),
+		"sum":         float64(53423),
+		"count":       float64(144320),
+	}, m.Fields())
+
+	m = find(metrics, "rpc_duration_seconds", map[string]string{"service": "a"})
+	require.NotNil(t, m)
+	assert.Equal(t, optic.SummaryMetric, m.MetricType())
+	assert.Equal(t, map[string]interface{}{
+		"quantile_0_5":  float64(4773),
+		"quantile_0_99": float64(76656),
+		"sum":           float64(1.7560473e+07),
+		"count":         float64(2693),
+	}, m.Fields())
+	assert.NotNil(t, find(metrics, "rpc_duration_seconds", map[string]string{"service": "b"}))
+}
+
+func TestParseOpenMetrics(t *testing.T) {
+	metrics, err := Parse([]byte(openMetricsFormat), true, time.Now())
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+
+	m := find(metrics, "acme_http_router_request_seconds", nil)
+	require.NotNil(t, m)
+	assert.Equal(t, optic.HistogramMetric, m.MetricType())
+	assert.Equal(t, map[string]string{"path": "/api/v1"}, m.Tags())
+	assert.Equal(t, map[string]interface{}{
+		"bucket_0_5": float64(1),
+		"bucket_inf": float64(2),
+		"sum":        float64(1.5),
+		"count":      float64(2),
+	}, m.Fields())
+
+	m = find(metrics, "process_cpu_seconds_total", nil)
+	require.NotNil(t, m)
+	assert.Equal(t, optic.CounterMetric, m.MetricType())
+	assert.Equal(t, map[string]interface{}{"counter": float64(4.2)}, m.Fields())
+	assert.Equal(t, int64(1520879607789), m.Time().UnixNano()/int64(time.Millisecond))
+
+	m = find(metrics, "build_info", nil)
+	require.NotNil(t, m)
+	assert.Equal(t, map[string]string{"version": "1.0"}, m.Tags())
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		`metric{label="unterminated} 1`,
+		`metric{label} 1`,
+		`metric abc`,
+		`metric 1 2 3`,
+		`metric 1 notatimestamp`,
+		"# TYPE h histogram\nh_bucket 1",
+	} {
+		_, err := Parse([]byte(in), false, time.Now())
+		assert.Error(t, err, in)
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	for _, v := range []float64{0, 0.005, 1, 2.5, -1, 1e-05, 1e+21, math.Inf(1), math.Inf(-1)} {
+		bucket := BucketField(v)
+		parsed, ok := ParseBucketField(bucket)
+		assert.True(t, ok, bucket)
+		assert.Equal(t, v, parsed, bucket)
+
+		quantile := QuantileField(v)
+		parsed, ok = ParseQuantileField(quantile)
+		assert.True(t, ok, quantile)
+		assert.Equal(t, v, parsed, quantile)
+	}
+	assert.Equal(t, "bucket_0_005", BucketField(0.005))
+	assert.Equal(t, "bucket_neg1", BucketField(-1))
+	assert.Equal(t, "bucket_inf", BucketField(math.Inf(1)))
+	assert.Equal(t, "quantile_0_99", QuantileField(0.99))
+
+	_, ok := ParseBucketField("count")
+	assert.False(t, ok)
+	_, ok = ParseBucketField("bucket_x")
+	assert.False(t, ok)
+}
@@ -91,6 +91,7 @@ func (a *Accumulator) AddEvent(event optic.Event) {
 		e.MetricType = v.MetricType()
 		e.Fields = v.Fields()
 	case optic.LogLine:
+		e.Path = v.Path()
 		e.Content = v.Content()
 		e.Fields = v.Fields()
 	}
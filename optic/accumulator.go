@@ -11,3 +11,13 @@ type Accumulator interface {
 
 	AddError(err error)
 }
+
+// NonBlockingAccumulator is an Accumulator which can refuse an event instead
+// of blocking when the event channel is full.
+type NonBlockingAccumulator interface {
+	Accumulator
+
+	// TryAddEvent adds the event if it can be done without blocking, and
+	// reports whether it was added.
+	TryAddEvent(Event) bool
+}
@@ -23,6 +23,12 @@ type Encoder interface {
 	EncodeTo(event Event, dst []byte) error
 }
 
+// ContentTypeEncoder is an interface for encoders which know the media type of
+// the data they produce, e.g. for the Content-Type header of HTTP requests.
+type ContentTypeEncoder interface {
+	ContentType() string
+}
+
 // EncoderOutput is an interface for sink plugins that are able to encode optic
 // events into arbitrary data formats.
 type EncoderOutput interface {
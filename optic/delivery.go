@@ -0,0 +1,79 @@
+package optic
+
+import "sync"
+
+// Delivery tracks the delivery of an event, and of its copies, to the sinks it
+// was forwarded to. Once every copy was written, or failed permanently, the
+// callback is called with whether all of them were written.
+//
+// A source which needs to know when its events were delivered, e.g. to commit
+// offsets, sets a delivery on the events before adding them. Copies made with
+// `Event.Copy` are tracked by the same delivery.
+type Delivery struct {
+	mu       sync.Mutex
+	pending  int
+	failed   bool
+	callback func(delivered bool)
+}
+
+// NewDelivery returns a delivery of a single event, which calls the callback
+// once the event and all of its copies were resolved.
+func NewDelivery(callback func(delivered bool)) *Delivery {
+	return &Delivery{
+		pending:  1,
+		callback: callback,
+	}
+}
+
+// Add tracks n more copies of the event.
+func (d *Delivery) Add(n int) {
+	d.mu.Lock()
+	d.pending += n
+	d.mu.Unlock()
+}
+
+// Ack resolves a copy of the event as written.
+func (d *Delivery) Ack() {
+	d.resolve(false)
+}
+
+// Nack resolves a copy of the event as permanently failed.
+func (d *Delivery) Nack() {
+	d.resolve(true)
+}
+
+func (d *Delivery) resolve(failed bool) {
+	d.mu.Lock()
+	if d.pending <= 0 {
+		// already resolved
+		d.mu.Unlock()
+		return
+	}
+	d.failed = d.failed || failed
+	d.pending--
+	done := d.pending == 0
+	delivered := !d.failed
+	d.mu.Unlock()
+
+	if done && d.callback != nil {
+		d.callback(delivered)
+	}
+}
+
+// Ack resolves the tracked events as written.
+func Ack(events ...Event) {
+	for _, event := range events {
+		if d := event.Delivery(); d != nil {
+			d.Ack()
+		}
+	}
+}
+
+// Nack resolves the tracked events as permanently failed.
+func Nack(events ...Event) {
+	for _, event := range events {
+		if d := event.Delivery(); d != nil {
+			d.Nack()
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package optic_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+)
+
+type result struct {
+	calls     int
+	delivered bool
+}
+
+func newTrackedEvent(t *testing.T, r *result) optic.Event {
+	m, err := metric.New("test", nil, map[string]interface{}{"value": 1}, time.Now())
+	require.NoError(t, err)
+	m.SetDelivery(optic.NewDelivery(func(delivered bool) {
+		r.calls++
+		r.delivered = delivered
+	}))
+	return m
+}
+
+func TestDeliveryCopies(t *testing.T) {
+	r := &result{}
+	event := newTrackedEvent(t, r)
+	first := event.Copy()
+	second := first.Copy()
+	assert.Equal(t, event.Delivery(), second.Delivery())
+
+	optic.Ack(event, first)
+	assert.Equal(t, 0, r.calls)
+	optic.Ack(second)
+	assert.Equal(t, 1, r.calls)
+	assert.True(t, r.delivered)
+
+	// resolved only once
+	optic.Ack(event)
+	assert.Equal(t, 1, r.calls)
+}
+
+func TestDeliveryNack(t *testing.T) {
+	r := &result{}
+	event := newTrackedEvent(t, r)
+	copied := event.Copy()
+
+	optic.Nack(copied)
+	optic.Ack(event)
+	assert.Equal(t, 1, r.calls)
+	assert.False(t, r.delivered)
+}
+
+func TestDeliveryUntracked(t *testing.T) {
+	m, err := metric.New("test", nil, map[string]interface{}{"value": 1}, time.Now())
+	require.NoError(t, err)
+	assert.Nil(t, m.Copy().Delivery())
+	// no-op
+	optic.Ack(m)
+	optic.Nack(m)
+}
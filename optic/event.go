@@ -49,6 +49,12 @@ type Event interface {
 	Serialize() []byte
 	// String serializes the event into a string.
 	String() string
-	// Copy deep-copies the event.
+	// Copy deep-copies the event. The copy is tracked by the same delivery.
 	Copy() Event
+
+	// Delivery returns the delivery tracking the event, nil if it isn't
+	// tracked.
+	Delivery() *Delivery
+	// SetDelivery tracks the event with the delivery.
+	SetDelivery(d *Delivery)
 }
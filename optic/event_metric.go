@@ -15,6 +15,18 @@ const (
 	SummaryMetric
 )
 
+var metricTypes = map[MetricType]string{
+	CounterMetric:   "counter",
+	GaugeMetric:     "gauge",
+	UntypedMetric:   "untyped",
+	HistogramMetric: "histogram",
+	SummaryMetric:   "summary",
+}
+
+func (mt MetricType) String() string {
+	return metricTypes[mt]
+}
+
 // A Metric is a name, tagmap, timestamp, and one or more metric fields
 // containing point-in-time values.
 type Metric interface {
@@ -25,6 +37,9 @@ type Metric interface {
 	Name() string
 	// The specific type of the metric.
 	MetricType() MetricType
+	// HashID returns a hash of the name and the sorted tags, identifying the
+	// series the metric belongs to.
+	HashID() uint64
 
 	SetName(name string)
 	SetPrefix(prefix string)
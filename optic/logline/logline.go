@@ -13,6 +13,8 @@ type logline struct {
 	fields  map[string]interface{}
 	path    string
 	content string
+
+	delivery *optic.Delivery
 }
 
 func New(
@@ -110,7 +112,20 @@ func (l *logline) String() string {
 }
 
 func (l *logline) Copy() optic.Event {
-	return copyFrom(l)
+	out := copyFrom(l)
+	if l.delivery != nil {
+		l.delivery.Add(1)
+		out.SetDelivery(l.delivery)
+	}
+	return out
+}
+
+func (l *logline) Delivery() *optic.Delivery {
+	return l.delivery
+}
+
+func (l *logline) SetDelivery(d *optic.Delivery) {
+	l.delivery = d
 }
 
 func (l *logline) Path() string {
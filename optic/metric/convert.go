@@ -0,0 +1,25 @@
+package metric
+
+// ToFloat returns a numeric field value as a float64, and false for values of
+// other types.
+func ToFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -13,6 +13,8 @@ type metric struct {
 	fields     map[string]interface{}
 	name       string
 	metricType optic.MetricType
+
+	delivery *optic.Delivery
 }
 
 func New(
@@ -147,7 +149,20 @@ func (m *metric) String() string {
 }
 
 func (m *metric) Copy() optic.Event {
-	return copyFrom(m)
+	out := copyFrom(m)
+	if m.delivery != nil {
+		m.delivery.Add(1)
+		out.SetDelivery(m.delivery)
+	}
+	return out
+}
+
+func (m *metric) Delivery() *optic.Delivery {
+	return m.delivery
+}
+
+func (m *metric) SetDelivery(d *optic.Delivery) {
+	m.delivery = d
 }
 
 func (m *metric) Name() string {
@@ -158,6 +173,10 @@ func (m *metric) MetricType() optic.MetricType {
 	return m.metricType
 }
 
+func (m *metric) HashID() uint64 {
+	return hashID(m)
+}
+
 func (m *metric) SetName(name string) {
 	m.name = sanitize(name, "name")
 }
@@ -35,3 +35,90 @@ func TestNewMetric(t *testing.T) {
 	assert.Equal(t, "cpu", m.Name())
 	assert.Equal(t, optic.UntypedMetric, m.MetricType())
 }
+
+func TestSerializeEscaping(t *testing.T) {
+	m, err := NewParsed(
+		"cpu load,total",
+		map[string]string{"host name": "a=b,c"},
+		map[string]interface{}{"field key": "say \"hi\""},
+		time.Unix(0, 1),
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t,
+		`cpu\ load\,total,host\ name=a\=b\,c field\ key="say \"hi\"" 1`,
+		m.String())
+}
+
+func TestSerializeMultipleFields(t *testing.T) {
+	m, err := New("cpu", nil, map[string]interface{}{"a": int64(1), "b": int64(2)}, time.Unix(0, 1))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "cpu a=1i,b=2i 1", m.String())
+}
+
+func TestSerializeSorted(t *testing.T) {
+	m, err := New("cpu",
+		map[string]string{"c": "3", "a": "1", "b": "2"},
+		map[string]interface{}{"z": int64(1), "x": int64(2), "y": int64(3)},
+		time.Unix(0, 1),
+	)
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "cpu,a=1,b=2,c=3 x=2i,y=3i,z=1i 1", m.String())
+	}
+}
+
+func TestHashID(t *testing.T) {
+	m1, _ := New("cpu",
+		map[string]string{"host": "a", "dc": "eu"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 1),
+	)
+	// same series, different fields and time
+	m2, _ := New("cpu",
+		map[string]string{"dc": "eu", "host": "a"},
+		map[string]interface{}{"other": float64(2)},
+		time.Unix(0, 2),
+	)
+	m3, _ := New("cpu",
+		map[string]string{"host": "b", "dc": "eu"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 1),
+	)
+	// tags must not be confused with the name
+	m4, _ := NewParsed("cpu\nhost",
+		map[string]string{"a": "dc", "eu": ""},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 1),
+	)
+	m5, _ := NewParsed("cpu\nhost\na",
+		map[string]string{},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 1),
+	)
+	m6, _ := New("cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(0, 1),
+	)
+
+	assert.Equal(t, m1.HashID(), m2.HashID())
+	assert.NotEqual(t, m1.HashID(), m3.HashID())
+	assert.NotEqual(t, m1.HashID(), m4.HashID())
+	assert.NotEqual(t, m5.HashID(), m6.HashID())
+}
+
+func TestToFloat(t *testing.T) {
+	for _, v := range []interface{}{float64(2), float32(2), int64(2), 2, int32(2), uint64(2), uint32(2), uint(2)} {
+		f, ok := ToFloat(v)
+		assert.True(t, ok, "%T", v)
+		assert.Equal(t, 2.0, f, "%T", v)
+	}
+
+	for _, v := range []interface{}{"2", true, nil} {
+		_, ok := ToFloat(v)
+		assert.False(t, ok, "%T", v)
+	}
+}
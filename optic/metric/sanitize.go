@@ -19,6 +19,20 @@ var (
 	namePrefixSanitizer = regexp.MustCompile(`^[^a-zA-Z_:]`)
 	nameBodySanitizer   = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
 
+	// measurementEscaper is for escaping serialized metric names.
+	measurementEscaper = strings.NewReplacer(
+		`,`, `\,`,
+		` `, `\ `,
+	)
+
+	// keyEscaper is for escaping serialized tag keys, tag values and field
+	// keys.
+	keyEscaper = strings.NewReplacer(
+		`,`, `\,`,
+		`=`, `\=`,
+		` `, `\ `,
+	)
+
 	// stringFieldEscaper is for escaping string field values only.
 	stringFieldEscaper = strings.NewReplacer(
 		`"`, `\"`,
@@ -42,6 +56,10 @@ func sanitize(s string, t string) string {
 			namePrefixSanitizer.ReplaceAllString(s, "_"),
 			"_",
 		)
+	case "measurement":
+		return measurementEscaper.Replace(s)
+	case "key":
+		return keyEscaper.Replace(s)
 	case "fieldval":
 		return stringFieldEscaper.Replace(s)
 	}
@@ -1,107 +1,172 @@
 package metric
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/zbiljic/optic/optic"
 )
 
-const maxInt = int(^uint(0) >> 1)
+var errNoFields = errors.New("metric has no fields which can be serialized")
 
-func serialize(metric optic.Metric) []byte {
-	b := []byte(metric.Name())
-	for k, v := range metric.Tags() {
+// serialize writes the metric in line protocol, without the trailing newline.
+// A metric which can't be represented in line protocol serializes to nothing.
+func serialize(m optic.Metric) []byte {
+	b, err := AppendLine(nil, m, time.Nanosecond)
+	if err != nil {
+		return nil
+	}
+	return b[:len(b)-1]
+}
+
+// AppendLine appends the metric in line protocol to dst, with the timestamp in
+// the given precision. Tags and fields are written in sorted order, so the
+// same metric always gives the same line. Fields with values that line
+// protocol can't represent, such as NaN, are skipped. As in line protocol
+// itself, a tag key or value ending with a backslash can't be represented.
+func AppendLine(dst []byte, m optic.Metric, precision time.Duration) ([]byte, error) {
+	if m.Name() == "" {
+		return nil, fmt.Errorf("missing metric name")
+	}
+	if precision <= 0 {
+		precision = time.Nanosecond
+	}
+
+	b := append(dst, measurementEscaper.Replace(m.Name())...)
+
+	tags := m.Tags()
+	tagKeys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if k == "" || v == "" {
+			continue
+		}
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	for _, k := range tagKeys {
 		b = append(b, ',')
-		b = appendTag(b, k, v)
+		b = append(b, keyEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, keyEscaper.Replace(tags[k])...)
 	}
+
+	fields := m.Fields()
+	sep := byte(' ')
+	for _, k := range sortedFieldKeys(fields) {
+		value, ok := appendFieldValue(nil, fields[k])
+		if !ok || k == "" {
+			continue
+		}
+		b = append(b, sep)
+		b = append(b, keyEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, value...)
+		sep = ','
+	}
+	if sep == ' ' {
+		return nil, fmt.Errorf("%s: %s", m.Name(), errNoFields)
+	}
+
 	b = append(b, ' ')
-	for k, v := range metric.Fields() {
-		b = appendField(b, k, v)
-		b = append(b, ' ')
+	b = strconv.AppendInt(b, m.Time().UnixNano()/int64(precision), 10)
+	b = append(b, '\n')
+	return b, nil
+}
+
+// hashID returns the FNV-1a hash of the metric name and its sorted tags. Every
+// string is prefixed with its length, so no name, key or value can be mistaken
+// for another.
+func hashID(metric optic.Metric) uint64 {
+	h := fnv.New64a()
+	hashString(h, metric.Name())
+	tags := metric.Tags()
+	for _, k := range sortedTagKeys(tags) {
+		hashString(h, k)
+		hashString(h, tags[k])
 	}
-	b = append(b, []byte(fmt.Sprint(metric.Time().UnixNano()))...)
-	return b
+	return h.Sum64()
 }
 
-func appendTag(b []byte, k, v string) []byte {
-	b = append(b, []byte(k)...)
-	b = append(b, '=')
-	b = append(b, []byte(v)...)
-	return b
+func hashString(h hash.Hash64, s string) {
+	var n [binary.MaxVarintLen64]byte
+	h.Write(n[:binary.PutUvarint(n[:], uint64(len(s)))])
+	h.Write([]byte(s))
 }
 
-func appendField(b []byte, k string, v interface{}) []byte {
-	if v == nil {
-		return b
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
 	}
-	b = append(b, []byte(k)...)
-	b = append(b, '=')
+	sort.Strings(keys)
+	return keys
+}
 
-	// check popular types first
+func sortedFieldKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appendFieldValue appends the value in line protocol format, and reports
+// whether the value is supported.
+func appendFieldValue(b []byte, v interface{}) ([]byte, bool) {
 	switch v := v.(type) {
 	case float64:
-		b = strconv.AppendFloat(b, v, 'f', -1, 64)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return b, false
+		}
+		return strconv.AppendFloat(b, v, 'g', -1, 64), true
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return b, false
+		}
+		return strconv.AppendFloat(b, float64(v), 'g', -1, 32), true
 	case int64:
-		b = strconv.AppendInt(b, v, 10)
-		b = append(b, 'i')
-	case string:
-		b = append(b, '"')
-		b = append(b, []byte(sanitize(v, "fieldval"))...)
-		b = append(b, '"')
-	case bool:
-		b = strconv.AppendBool(b, v)
+		return append(strconv.AppendInt(b, v, 10), 'i'), true
+	case int:
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), true
 	case int32:
-		b = strconv.AppendInt(b, int64(v), 10)
-		b = append(b, 'i')
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), true
 	case int16:
-		b = strconv.AppendInt(b, int64(v), 10)
-		b = append(b, 'i')
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), true
 	case int8:
-		b = strconv.AppendInt(b, int64(v), 10)
-		b = append(b, 'i')
-	case int:
-		b = strconv.AppendInt(b, int64(v), 10)
-		b = append(b, 'i')
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), true
 	case uint64:
-		// Cap uints above the maximum int value
-		var intv int64
-		if v <= uint64(maxInt) {
-			intv = int64(v)
-		} else {
-			intv = int64(maxInt)
-		}
-		b = strconv.AppendInt(b, intv, 10)
-		b = append(b, 'i')
+		return append(strconv.AppendUint(b, v, 10), 'u'), true
+	case uint:
+		return append(strconv.AppendUint(b, uint64(v), 10), 'u'), true
 	case uint32:
-		b = strconv.AppendInt(b, int64(v), 10)
-		b = append(b, 'i')
+		return append(strconv.AppendUint(b, uint64(v), 10), 'u'), true
 	case uint16:
-		b = strconv.AppendInt(b, int64(v), 10)
-		b = append(b, 'i')
+		return append(strconv.AppendUint(b, uint64(v), 10), 'u'), true
 	case uint8:
-		b = strconv.AppendInt(b, int64(v), 10)
-		b = append(b, 'i')
-	case uint:
-		// Cap uints above the maximum int value
-		var intv int64
-		if v <= uint(maxInt) {
-			intv = int64(v)
-		} else {
-			intv = int64(maxInt)
-		}
-		b = strconv.AppendInt(b, intv, 10)
-		b = append(b, 'i')
-	case float32:
-		b = strconv.AppendFloat(b, float64(v), 'f', -1, 32)
+		return append(strconv.AppendUint(b, uint64(v), 10), 'u'), true
+	case bool:
+		return strconv.AppendBool(b, v), true
+	case string:
+		return appendString(b, v), true
 	case []byte:
-		b = append(b, v...)
+		return appendString(b, string(v)), true
+	case nil:
+		return b, false
 	default:
-		// Can't determine the type, so convert to string
-		b = append(b, '"')
-		b = append(b, []byte(sanitize(fmt.Sprintf("%v", v), "fieldval"))...)
-		b = append(b, '"')
+		return appendString(b, fmt.Sprint(v)), true
 	}
+}
 
-	return b
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	b = append(b, stringFieldEscaper.Replace(s)...)
+	return append(b, '"')
 }
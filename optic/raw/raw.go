@@ -14,6 +14,8 @@ type raw struct {
 	fields map[string]interface{}
 	source string
 	value  []byte
+
+	delivery *optic.Delivery
 }
 
 func New(
@@ -115,7 +117,20 @@ func (r *raw) String() string {
 }
 
 func (r *raw) Copy() optic.Event {
-	return copyFrom(r)
+	out := copyFrom(r)
+	if r.delivery != nil {
+		r.delivery.Add(1)
+		out.SetDelivery(r.delivery)
+	}
+	return out
+}
+
+func (r *raw) Delivery() *optic.Delivery {
+	return r.delivery
+}
+
+func (r *raw) SetDelivery(d *optic.Delivery) {
+	r.delivery = d
 }
 
 func (r *raw) Source() string {
@@ -1,5 +1,7 @@
 package optic
 
+import "fmt"
+
 type Sink interface {
 	Plugin
 
@@ -22,3 +24,37 @@ type ServiceSink interface {
 	// Stop the "service" that will provide an Sink.
 	Stop()
 }
+
+// PartialWriteError is returned from `Sink.Write` when some of the events were
+// rejected permanently, for example because they can not be encoded. All other
+// events of the batch are considered written.
+type PartialWriteError struct {
+	// Rejected are the indices of the rejected events in the batch.
+	Rejected []int
+
+	// Err is the reason the events were rejected.
+	Err error
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("%d events rejected: %s", len(e.Rejected), e.Err)
+}
+
+// IncompleteWriteError is returned from `Sink.Write` when the sink failed after
+// writing some of the events, for example when every event is sent in its own
+// request. The written events aren't written again, the rest are retried.
+type IncompleteWriteError struct {
+	// Written is the number of events at the start of the batch which were
+	// written, or rejected.
+	Written int
+
+	// Rejected are the indices of the rejected events among the written ones.
+	Rejected []int
+
+	// Err is the reason the rest of the events weren't written.
+	Err error
+}
+
+func (e *IncompleteWriteError) Error() string {
+	return fmt.Sprintf("failed after %d events: %s", e.Written, e.Err)
+}
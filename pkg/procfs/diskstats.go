@@ -0,0 +1,66 @@
+package procfs
+
+import (
+	"fmt"
+	"os"
+)
+
+// sectorSize is the size of the sectors counted in /proc/diskstats, which is
+// always 512 bytes, regardless of the device.
+const sectorSize = 512
+
+// DiskStat is the I/O statistics of a block device. Times are in
+// milliseconds.
+type DiskStat struct {
+	Name string
+
+	Reads        uint64
+	ReadsMerged  uint64
+	ReadBytes    uint64
+	ReadTime     uint64
+	Writes       uint64
+	WritesMerged uint64
+	WriteBytes   uint64
+	WriteTime    uint64
+
+	IOInProgress   uint64
+	IOTime         uint64
+	WeightedIOTime uint64
+}
+
+// DiskStats reads /proc/diskstats.
+func (fs FS) DiskStats() ([]DiskStat, error) {
+	var stats []DiskStat
+	err := readLines(fs.proc("diskstats"), func(fields []string) error {
+		if len(fields) < 14 {
+			return nil
+		}
+		values, err := parseUints(fields[3:14])
+		if err != nil {
+			return fmt.Errorf("invalid diskstats of %s", fields[2])
+		}
+		stats = append(stats, DiskStat{
+			Name:           fields[2],
+			Reads:          values[0],
+			ReadsMerged:    values[1],
+			ReadBytes:      values[2] * sectorSize,
+			ReadTime:       values[3],
+			Writes:         values[4],
+			WritesMerged:   values[5],
+			WriteBytes:     values[6] * sectorSize,
+			WriteTime:      values[7],
+			IOInProgress:   values[8],
+			IOTime:         values[9],
+			WeightedIOTime: values[10],
+		})
+		return nil
+	})
+	return stats, err
+}
+
+// IsBlockDevice reports whether the device is a whole block device, rather
+// than a partition, by looking it up in /sys/block.
+func (fs FS) IsBlockDevice(name string) bool {
+	_, err := os.Stat(fs.sys("block", name))
+	return err == nil
+}
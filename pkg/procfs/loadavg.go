@@ -0,0 +1,56 @@
+package procfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// LoadAvg is the system load average in /proc/loadavg.
+type LoadAvg struct {
+	Load1  float64
+	Load5  float64
+	Load15 float64
+}
+
+// LoadAvg reads /proc/loadavg.
+func (fs FS) LoadAvg() (LoadAvg, error) {
+	b, err := ioutil.ReadFile(fs.proc("loadavg"))
+	if err != nil {
+		return LoadAvg{}, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) < 3 {
+		return LoadAvg{}, fmt.Errorf("invalid loadavg: %s", b)
+	}
+
+	var loads [3]float64
+	for i := range loads {
+		if loads[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return LoadAvg{}, fmt.Errorf("invalid loadavg: %s", b)
+		}
+	}
+	return LoadAvg{
+		Load1:  loads[0],
+		Load5:  loads[1],
+		Load15: loads[2],
+	}, nil
+}
+
+// Uptime reads the system uptime in seconds from /proc/uptime.
+func (fs FS) Uptime() (float64, error) {
+	b, err := ioutil.ReadFile(fs.proc("uptime"))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("invalid uptime: %s", b)
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid uptime: %s", b)
+	}
+	return uptime, nil
+}
@@ -0,0 +1,30 @@
+package procfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MemInfo reads /proc/meminfo. Values are in bytes, keyed by the names used
+// in the file, e.g. "MemTotal" or "SwapFree". Values without a unit, such as
+// "HugePages_Total", are returned as they are.
+func (fs FS) MemInfo() (map[string]uint64, error) {
+	info := make(map[string]uint64)
+	err := readLines(fs.proc("meminfo"), func(fields []string) error {
+		if len(fields) < 2 {
+			return nil
+		}
+		key := strings.TrimSuffix(fields[0], ":")
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s in meminfo: %s", key, fields[1])
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			v *= 1024
+		}
+		info[key] = v
+		return nil
+	})
+	return info, err
+}
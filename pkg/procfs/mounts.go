@@ -0,0 +1,62 @@
+package procfs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Mount is a mounted filesystem.
+type Mount struct {
+	Device     string
+	MountPoint string
+	FSType     string
+	Options    []string
+}
+
+// ReadOnly reports whether the filesystem is mounted read-only.
+func (m Mount) ReadOnly() bool {
+	for _, opt := range m.Options {
+		if opt == "ro" {
+			return true
+		}
+	}
+	return false
+}
+
+// Mounts reads the mounted filesystems from /proc/self/mounts.
+func (fs FS) Mounts() ([]Mount, error) {
+	var mounts []Mount
+	err := readLines(fs.proc("self", "mounts"), func(fields []string) error {
+		if len(fields) < 4 {
+			return nil
+		}
+		mounts = append(mounts, Mount{
+			Device:     unescapeMount(fields[0]),
+			MountPoint: unescapeMount(fields[1]),
+			FSType:     fields[2],
+			Options:    strings.Split(fields[3], ","),
+		})
+		return nil
+	})
+	return mounts, err
+}
+
+// unescapeMount replaces the octal escapes the kernel uses for spaces, tabs,
+// newlines and backslashes in mount paths.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package procfs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NetDevStat is the statistics of a network interface.
+type NetDevStat struct {
+	Interface string
+
+	RxBytes   uint64
+	RxPackets uint64
+	RxErrors  uint64
+	RxDropped uint64
+	TxBytes   uint64
+	TxPackets uint64
+	TxErrors  uint64
+	TxDropped uint64
+}
+
+// NetDev reads /proc/net/dev.
+func (fs FS) NetDev() ([]NetDevStat, error) {
+	var stats []NetDevStat
+	err := readLines(fs.proc("net", "dev"), func(fields []string) error {
+		if len(fields) == 0 {
+			return nil
+		}
+		// the interface name may be stuck to the first value, as in "eth0:123"
+		line := strings.Join(fields, " ")
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			return nil
+		}
+		name := strings.TrimSpace(line[:i])
+		if name == "" || strings.Contains(name, "|") {
+			return nil
+		}
+
+		values, err := parseUints(strings.Fields(line[i+1:]))
+		if err != nil || len(values) < 16 {
+			return fmt.Errorf("invalid net/dev line of %s", name)
+		}
+		stats = append(stats, NetDevStat{
+			Interface: name,
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		})
+		return nil
+	})
+	return stats, err
+}
@@ -0,0 +1,69 @@
+package procfs
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ProcessStat is the state of a single process, from /proc/[pid]/stat.
+type ProcessStat struct {
+	PID   int
+	Comm  string
+	State byte
+	// Threads is the number of threads in the process.
+	Threads uint64
+}
+
+// Processes reads the state of all processes. Processes which can't be read,
+// usually because they exited in the meantime, are skipped.
+func (fs FS) Processes() ([]ProcessStat, error) {
+	entries, err := ioutil.ReadDir(fs.ProcRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []ProcessStat
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		stat, err := fs.processStat(pid)
+		if err != nil {
+			continue
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+func (fs FS) processStat(pid int) (ProcessStat, error) {
+	b, err := ioutil.ReadFile(fs.proc(strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return ProcessStat{}, err
+	}
+	s := string(b)
+
+	// the command may contain spaces and parentheses, so it ends at the last
+	// closing parenthesis
+	start, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if start < 0 || end < start {
+		return ProcessStat{PID: pid, State: '?'}, nil
+	}
+	stat := ProcessStat{
+		PID:  pid,
+		Comm: s[start+1 : end],
+	}
+
+	// the fields after the command start with the state, which is the third
+	// field of the line; the number of threads is the twentieth
+	fields := strings.Fields(s[end+1:])
+	if len(fields) > 0 && len(fields[0]) > 0 {
+		stat.State = fields[0][0]
+	}
+	if len(fields) > 17 {
+		stat.Threads, _ = strconv.ParseUint(fields[17], 10, 64)
+	}
+	return stat, nil
+}
@@ -0,0 +1,84 @@
+// Package procfs reads system statistics from the proc and sys filesystems of
+// Linux. The roots of both filesystems are configurable, so that the host
+// filesystems can be read from within a container, and tests can use
+// fixtures.
+package procfs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultProcRoot is the default mount point of the proc filesystem.
+	DefaultProcRoot = "/proc"
+	// DefaultSysRoot is the default mount point of the sys filesystem.
+	DefaultSysRoot = "/sys"
+
+	// userHZ is the number of clock ticks per second used in /proc. It is
+	// fixed to 100 on all architectures the kernel exposes to userspace.
+	userHZ = 100
+)
+
+// FS is the proc and sys filesystems.
+type FS struct {
+	ProcRoot string
+	SysRoot  string
+}
+
+// NewFS returns the filesystems with the given roots. Empty roots are
+// replaced with the defaults.
+func NewFS(procRoot, sysRoot string) FS {
+	if procRoot == "" {
+		procRoot = DefaultProcRoot
+	}
+	if sysRoot == "" {
+		sysRoot = DefaultSysRoot
+	}
+	return FS{
+		ProcRoot: procRoot,
+		SysRoot:  sysRoot,
+	}
+}
+
+func (fs FS) proc(elem ...string) string {
+	return filepath.Join(append([]string{fs.ProcRoot}, elem...)...)
+}
+
+func (fs FS) sys(elem ...string) string {
+	return filepath.Join(append([]string{fs.SysRoot}, elem...)...)
+}
+
+// readLines calls fn with the fields of every line of the file.
+func readLines(path string, fn func(fields []string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := fn(strings.Fields(scanner.Text())); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseUints parses all fields as unsigned integers.
+func parseUints(fields []string) ([]uint64, error) {
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,138 @@
+package procfs
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFS = NewFS("testdata/proc", "testdata/sys")
+
+func TestNewFS(t *testing.T) {
+	assert.Equal(t, FS{ProcRoot: "/proc", SysRoot: "/sys"}, NewFS("", ""))
+}
+
+func TestStat(t *testing.T) {
+	stat, err := testFS.Stat()
+	require.NoError(t, err)
+
+	assert.Equal(t, CPUStat{
+		CPU:     "cpu",
+		User:    101.32,
+		Nice:    0.14,
+		System:  35.8,
+		Idle:    1891.2,
+		IOWait:  2.21,
+		SoftIRQ: 1.49,
+	}, stat.CPUTotal)
+	require.Len(t, stat.CPUs, 2)
+	assert.Equal(t, "cpu1", stat.CPUs[1].CPU)
+	assert.Equal(t, 50.71, stat.CPUs[1].User)
+	assert.InDelta(t, 2032.16, stat.CPUTotal.Total(), 1e-9)
+
+	assert.Equal(t, uint64(2037385), stat.ContextSwitches)
+	assert.Equal(t, uint64(1514764800), stat.BootTime)
+	assert.Equal(t, uint64(24507), stat.ProcessesCreated)
+	assert.Equal(t, uint64(2), stat.ProcsRunning)
+	assert.Equal(t, uint64(1), stat.ProcsBlocked)
+}
+
+func TestMemInfo(t *testing.T) {
+	info, err := testFS.MemInfo()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(8046916*1024), info["MemTotal"])
+	assert.Equal(t, uint64(2097148*1024), info["SwapFree"])
+	assert.Equal(t, uint64(0), info["HugePages_Total"])
+}
+
+func TestLoadAvg(t *testing.T) {
+	load, err := testFS.LoadAvg()
+	require.NoError(t, err)
+	assert.Equal(t, LoadAvg{Load1: 0.25, Load5: 0.5, Load15: 1}, load)
+
+	uptime, err := testFS.Uptime()
+	require.NoError(t, err)
+	assert.Equal(t, 12345.67, uptime)
+}
+
+func TestMounts(t *testing.T) {
+	mounts, err := testFS.Mounts()
+	require.NoError(t, err)
+	require.Len(t, mounts, 4)
+
+	assert.Equal(t, Mount{
+		Device:     "/dev/sda1",
+		MountPoint: "/",
+		FSType:     "ext4",
+		Options:    []string{"rw", "relatime"},
+	}, mounts[1])
+	assert.False(t, mounts[1].ReadOnly())
+
+	assert.Equal(t, "/mnt/with space", mounts[3].MountPoint)
+	assert.True(t, mounts[3].ReadOnly())
+}
+
+func TestDiskStats(t *testing.T) {
+	stats, err := testFS.DiskStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+
+	assert.Equal(t, DiskStat{
+		Name:           "sda",
+		Reads:          12000,
+		ReadsMerged:    3000,
+		ReadBytes:      800000 * 512,
+		ReadTime:       6000,
+		Writes:         24000,
+		WritesMerged:   9000,
+		WriteBytes:     1600000 * 512,
+		WriteTime:      30000,
+		IOInProgress:   2,
+		IOTime:         18000,
+		WeightedIOTime: 36000,
+	}, stats[1])
+
+	assert.True(t, testFS.IsBlockDevice("sda"))
+	assert.False(t, testFS.IsBlockDevice("sda1"))
+}
+
+func TestNetDev(t *testing.T) {
+	stats, err := testFS.NetDev()
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	assert.Equal(t, NetDevStat{
+		Interface: "eth0",
+		RxBytes:   123456789,
+		RxPackets: 100000,
+		RxErrors:  1,
+		RxDropped: 2,
+		TxBytes:   98765432,
+		TxPackets: 90000,
+		TxErrors:  3,
+		TxDropped: 4,
+	}, stats[1])
+}
+
+func TestProcesses(t *testing.T) {
+	stats, err := testFS.Processes()
+	require.NoError(t, err)
+	sort.Slice(stats, func(i, j int) bool { return stats[i].PID < stats[j].PID })
+
+	assert.Equal(t, []ProcessStat{
+		{PID: 1, Comm: "init", State: 'S', Threads: 1},
+		{PID: 42, Comm: "my (weird) cmd", State: 'R', Threads: 4},
+		{PID: 1337, Comm: "zombie", State: 'Z', Threads: 1},
+	}, stats)
+}
+
+func TestMissing(t *testing.T) {
+	fs := NewFS("testdata/missing", "testdata/missing")
+	_, err := fs.Stat()
+	assert.Error(t, err)
+	_, err = fs.Processes()
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package procfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CPUStat is the time a CPU spent in each mode, in seconds. User and Nice
+// include the time spent running guests.
+type CPUStat struct {
+	CPU       string
+	User      float64
+	Nice      float64
+	System    float64
+	Idle      float64
+	IOWait    float64
+	IRQ       float64
+	SoftIRQ   float64
+	Steal     float64
+	Guest     float64
+	GuestNice float64
+}
+
+// Total returns the total time, without the guest time already included in
+// User and Nice.
+func (s CPUStat) Total() float64 {
+	return s.User + s.Nice + s.System + s.Idle + s.IOWait + s.IRQ + s.SoftIRQ + s.Steal
+}
+
+// Stat is the kernel and system statistics in /proc/stat.
+type Stat struct {
+	// CPUTotal is the sum over all CPUs.
+	CPUTotal CPUStat
+	// CPUs are the statistics of the individual CPUs.
+	CPUs []CPUStat
+
+	ContextSwitches  uint64
+	BootTime         uint64
+	ProcessesCreated uint64
+	ProcsRunning     uint64
+	ProcsBlocked     uint64
+}
+
+// Stat reads /proc/stat.
+func (fs FS) Stat() (Stat, error) {
+	var stat Stat
+	err := readLines(fs.proc("stat"), func(fields []string) error {
+		if len(fields) < 2 {
+			return nil
+		}
+		if strings.HasPrefix(fields[0], "cpu") {
+			cpu, err := parseCPUStat(fields)
+			if err != nil {
+				return err
+			}
+			if cpu.CPU == "cpu" {
+				stat.CPUTotal = cpu
+			} else {
+				stat.CPUs = append(stat.CPUs, cpu)
+			}
+			return nil
+		}
+
+		var dst *uint64
+		switch fields[0] {
+		case "ctxt":
+			dst = &stat.ContextSwitches
+		case "btime":
+			dst = &stat.BootTime
+		case "processes":
+			dst = &stat.ProcessesCreated
+		case "procs_running":
+			dst = &stat.ProcsRunning
+		case "procs_blocked":
+			dst = &stat.ProcsBlocked
+		default:
+			return nil
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s in stat: %s", fields[0], fields[1])
+		}
+		*dst = v
+		return nil
+	})
+	return stat, err
+}
+
+func parseCPUStat(fields []string) (CPUStat, error) {
+	values, err := parseUints(fields[1:])
+	if err != nil || len(values) < 4 {
+		return CPUStat{}, fmt.Errorf("invalid %s line in stat", fields[0])
+	}
+	// older kernels have less columns
+	for len(values) < 10 {
+		values = append(values, 0)
+	}
+
+	seconds := func(i int) float64 {
+		return float64(values[i]) / userHZ
+	}
+	return CPUStat{
+		CPU:       fields[0],
+		User:      seconds(0),
+		Nice:      seconds(1),
+		System:    seconds(2),
+		Idle:      seconds(3),
+		IOWait:    seconds(4),
+		IRQ:       seconds(5),
+		SoftIRQ:   seconds(6),
+		Steal:     seconds(7),
+		Guest:     seconds(8),
+		GuestNice: seconds(9),
+	}, nil
+}
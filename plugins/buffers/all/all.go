@@ -1,5 +1,6 @@
 package all
 
 import (
+	_ "github.com/zbiljic/optic/plugins/buffers/disk"
 	_ "github.com/zbiljic/optic/plugins/buffers/memory"
 )
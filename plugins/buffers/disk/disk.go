@@ -0,0 +1,483 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/buffers"
+)
+
+const (
+	name        = "disk"
+	description = `Disk is a buffer which stores events in append-only segment files.`
+)
+
+const (
+	// DefaultBufferLimit represents default number of events in disk buffer.
+	DefaultBufferLimit = 100000
+
+	// DefaultSegmentSize represents default size in bytes after which a new
+	// segment file is started.
+	DefaultSegmentSize = 8 * 1024 * 1024
+
+	segmentExt = ".seg"
+)
+
+type Disk struct {
+	// Path is the directory where segment files are stored. Every buffer must
+	// have its own directory.
+	Path string `mapstructure:"path"`
+
+	// Limit is the maximum number of events that the buffer can store.
+	Limit int `mapstructure:"limit"`
+
+	// SegmentSize is the size in bytes after which a new segment is started.
+	SegmentSize int64 `mapstructure:"segment_size"`
+
+	// Sync forces a sync of the segment file after every write.
+	Sync bool `mapstructure:"sync"`
+
+	entries  []entry
+	segments []*segment
+	nextSeq  uint64
+}
+
+// entry is the in-memory index of a buffered event.
+type entry struct {
+	seq    uint64
+	seg    *segment
+	offset int64
+	size   int
+
+	// not persisted, so events recovered after a restart aren't tracked
+	delivery *optic.Delivery
+}
+
+// segment is a single append-only file.
+type segment struct {
+	id   uint64
+	file *os.File
+	size int64
+	// number of events stored in this segment that were not removed
+	live int
+}
+
+func NewDisk() optic.Buffer {
+	return &Disk{
+		Limit:       DefaultBufferLimit,
+		SegmentSize: DefaultSegmentSize,
+	}
+}
+
+func (*Disk) Kind() string {
+	return name
+}
+
+func (*Disk) Description() string {
+	return description
+}
+
+func (d *Disk) Build() error {
+	if d.Path == "" {
+		return fmt.Errorf("Buffer path must be set")
+	}
+	if d.Limit <= 0 {
+		return fmt.Errorf("Buffer limit must be positive number: %d", d.Limit)
+	}
+	if d.SegmentSize <= 0 {
+		return fmt.Errorf("Buffer segment size must be positive number: %d", d.SegmentSize)
+	}
+	if err := os.MkdirAll(d.Path, 0750); err != nil {
+		return err
+	}
+	return d.recover()
+}
+
+func (d *Disk) Len() int {
+	return len(d.entries)
+}
+
+func (d *Disk) Cap() int {
+	return d.Limit
+}
+
+// Append persists the events. Their delivery is kept in the index, and set on
+// the events read back by Slice, so it is resolved once they are written.
+func (d *Disk) Append(events ...optic.Event) {
+	for _, event := range events {
+		if err := d.append(event); err != nil {
+			log.Printf("ERROR [%s] failed to buffer event: %s", name, err)
+			optic.Nack(event)
+			continue
+		}
+	}
+
+	if over := len(d.entries) - d.Limit; over > 0 {
+		log.Printf("WARNING [%s] buffer limit reached, dropping %d oldest events", name, over)
+		// dropped events won't be delivered
+		for _, e := range d.entries[:over] {
+			if e.delivery != nil {
+				e.delivery.Nack()
+			}
+		}
+		d.RemoveRange(0, over)
+	}
+}
+
+func (d *Disk) append(event optic.Event) error {
+	rec, err := toRecord(d.nextSeq, event)
+	if err != nil {
+		return err
+	}
+	b, err := encodeRecord(kindEvent, rec)
+	if err != nil {
+		return err
+	}
+
+	seg, err := d.write(b)
+	if err != nil {
+		return err
+	}
+
+	d.entries = append(d.entries, entry{
+		seq:      d.nextSeq,
+		seg:      seg,
+		offset:   seg.size - int64(len(b)),
+		size:     len(b),
+		delivery: event.Delivery(),
+	})
+	seg.live++
+	d.nextSeq++
+	return nil
+}
+
+// write appends the record to the current segment, starting a new one if the
+// current segment is full.
+func (d *Disk) write(b []byte) (*segment, error) {
+	seg := d.current()
+	if seg == nil || seg.size >= d.SegmentSize {
+		var id uint64
+		if seg != nil {
+			id = seg.id + 1
+		}
+		var err error
+		if seg, err = d.createSegment(id); err != nil {
+			return nil, err
+		}
+		d.compact()
+	}
+
+	if _, err := seg.file.WriteAt(b, seg.size); err != nil {
+		return nil, err
+	}
+	seg.size += int64(len(b))
+
+	if d.Sync {
+		if err := seg.file.Sync(); err != nil {
+			return nil, err
+		}
+	}
+	return seg, nil
+}
+
+func (d *Disk) Slice(start int, end int) ([]optic.Event, error) {
+	if start < 0 || end <= start || start >= len(d.entries) {
+		return []optic.Event{}, nil
+	}
+	if end > len(d.entries) {
+		end = len(d.entries)
+	}
+
+	events := make([]optic.Event, 0, end-start)
+	for _, e := range d.entries[start:end] {
+		event, err := d.read(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read event %d from segment %d: %s",
+				e.seq, e.seg.id, err)
+		}
+		if e.delivery != nil {
+			event.SetDelivery(e.delivery)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (d *Disk) read(e entry) (optic.Event, error) {
+	b := make([]byte, e.size)
+	if _, err := e.seg.file.ReadAt(b, e.offset); err != nil {
+		return nil, err
+	}
+	kind, payload, err := decodePayload(b[:recordHeaderSize], b[recordHeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+	if kind != kindEvent {
+		return nil, fmt.Errorf("unexpected record kind: %d", kind)
+	}
+	var rec eventRecord
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+		return nil, err
+	}
+	return fromRecord(&rec)
+}
+
+func (d *Disk) RemoveRange(from int, to int) {
+	if from < 0 || to <= from || from >= len(d.entries) {
+		return
+	}
+	if to > len(d.entries) {
+		to = len(d.entries)
+	}
+
+	removed := d.entries[from:to]
+
+	// persist removal before changing the index, so a failure leaves the
+	// events in the buffer
+	rec := removeRecord{Ranges: seqRanges(removed)}
+	b, err := encodeRecord(kindRemove, rec)
+	if err == nil {
+		_, err = d.write(b)
+	}
+	if err != nil {
+		log.Printf("ERROR [%s] failed to remove events from buffer: %s", name, err)
+		return
+	}
+
+	for _, e := range removed {
+		e.seg.live--
+	}
+
+	n := copy(d.entries[from:], d.entries[to:])
+	d.entries = d.entries[:from+n]
+
+	d.compact()
+}
+
+func (d *Disk) Clear() {
+	for _, seg := range d.segments {
+		seg.file.Close()
+		os.Remove(seg.file.Name())
+	}
+	d.segments = nil
+	d.entries = nil
+}
+
+func (d *Disk) IsEmpty() bool {
+	return len(d.entries) == 0
+}
+
+func (d *Disk) Close() error {
+	var errS string
+	for _, seg := range d.segments {
+		if err := seg.file.Close(); err != nil {
+			errS += err.Error() + "\n"
+		}
+	}
+	d.segments = nil
+	d.entries = nil
+	if errS != "" {
+		return errors.New(errS)
+	}
+	return nil
+}
+
+func (d *Disk) current() *segment {
+	if len(d.segments) == 0 {
+		return nil
+	}
+	return d.segments[len(d.segments)-1]
+}
+
+func (d *Disk) segmentPath(id uint64) string {
+	return filepath.Join(d.Path, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+func (d *Disk) createSegment(id uint64) (*segment, error) {
+	f, err := os.OpenFile(d.segmentPath(id), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0640)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segment{id: id, file: f}
+	d.segments = append(d.segments, seg)
+	return seg, nil
+}
+
+// compact deletes the oldest segments that no longer hold any events.
+//
+// Segments are only deleted from the front: removal records are always
+// written to the newest segment, so a segment can't be deleted while any
+// older segment still depends on its removal records.
+func (d *Disk) compact() {
+	for len(d.segments) > 1 && d.segments[0].live == 0 {
+		seg := d.segments[0]
+		seg.file.Close()
+		if err := os.Remove(seg.file.Name()); err != nil {
+			log.Printf("ERROR [%s] failed to delete segment: %s", name, err)
+		}
+		d.segments[0] = nil
+		d.segments = d.segments[1:]
+	}
+}
+
+// recover rebuilds the index from segment files found on disk.
+func (d *Disk) recover() error {
+	d.entries = nil
+	d.segments = nil
+	d.nextSeq = 0
+
+	files, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		return err
+	}
+
+	var ids []uint64
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(fi.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	removed := make(map[uint64]bool)
+	for _, id := range ids {
+		f, err := os.OpenFile(d.segmentPath(id), os.O_RDWR, 0640)
+		if err != nil {
+			return err
+		}
+		seg := &segment{id: id, file: f}
+		d.segments = append(d.segments, seg)
+
+		if err := d.replay(seg, removed); err != nil {
+			return fmt.Errorf("failed to recover segment %s: %s", f.Name(), err)
+		}
+	}
+
+	live := d.entries[:0]
+	for _, e := range d.entries {
+		if removed[e.seq] {
+			continue
+		}
+		e.seg.live++
+		live = append(live, e)
+	}
+	d.entries = live
+
+	d.compact()
+
+	if len(d.entries) > 0 {
+		log.Printf("INFO [%s] recovered %d events from %s", name, len(d.entries), d.Path)
+	}
+	return nil
+}
+
+// replay reads all records of the segment. Anything after the first damaged
+// record, such as a torn write, is truncated away.
+func (d *Disk) replay(seg *segment, removed map[uint64]bool) error {
+	fi, err := seg.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, recordHeaderSize)
+	var offset int64
+	for {
+		n, err := seg.file.ReadAt(header, offset)
+		if err == io.EOF && n == 0 {
+			break
+		}
+		var (
+			kind    byte
+			payload []byte
+			size    int
+		)
+		if err == nil {
+			length := int64(binary.BigEndian.Uint32(header[0:4]))
+			if length > fi.Size()-offset-recordHeaderSize {
+				// the header is damaged, or the record was torn
+				err = errShortRecord
+			} else {
+				payload = make([]byte, length)
+				size = recordHeaderSize + int(length)
+				if _, err = seg.file.ReadAt(payload, offset+recordHeaderSize); err == nil {
+					kind, payload, err = decodePayload(header, payload)
+				}
+			}
+		}
+		if err != nil {
+			log.Printf("WARNING [%s] truncating damaged segment %s at offset %d: %s",
+				name, seg.file.Name(), offset, err)
+			if err := seg.file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+
+		switch kind {
+		case kindEvent:
+			var rec eventRecord
+			if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+				return err
+			}
+			d.entries = append(d.entries, entry{
+				seq:    rec.Seq,
+				seg:    seg,
+				offset: offset,
+				size:   size,
+			})
+			if rec.Seq >= d.nextSeq {
+				d.nextSeq = rec.Seq + 1
+			}
+		case kindRemove:
+			var rec removeRecord
+			if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+				return err
+			}
+			for _, r := range rec.Ranges {
+				for seq := r.From; seq <= r.To; seq++ {
+					removed[seq] = true
+				}
+			}
+		default:
+			return fmt.Errorf("unknown record kind: %d", kind)
+		}
+
+		offset += int64(size)
+	}
+	seg.size = offset
+	return nil
+}
+
+// seqRanges collapses sequence numbers of the entries into ranges.
+func seqRanges(entries []entry) []seqRange {
+	var ranges []seqRange
+	for _, e := range entries {
+		if n := len(ranges); n > 0 && ranges[n-1].To+1 == e.seq {
+			ranges[n-1].To = e.seq
+			continue
+		}
+		ranges = append(ranges, seqRange{From: e.seq, To: e.seq})
+	}
+	return ranges
+}
+
+func init() {
+	buffers.Add(name, NewDisk)
+}
@@ -0,0 +1,279 @@
+package disk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestDisk_impl(t *testing.T) {
+	var _ optic.Buffer = new(Disk)
+}
+
+func newTestDisk(t *testing.T, dir string) *Disk {
+	d := NewDisk().(*Disk)
+	d.Path = dir
+	require.NoError(t, d.Build())
+	return d
+}
+
+func contents(t *testing.T, d *Disk) []string {
+	events, err := d.Slice(0, d.Len())
+	require.NoError(t, err)
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		out = append(out, e.String())
+	}
+	return out
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	return files
+}
+
+func TestDiskBuildRequiresPath(t *testing.T) {
+	d := NewDisk()
+	assert.Error(t, d.Build())
+}
+
+func TestDiskAppendSliceRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir)
+	defer d.Close()
+
+	assert.True(t, d.IsEmpty())
+	for _, s := range []string{"a", "b", "c", "d"} {
+		d.Append(testutil.TestLogLine(s))
+	}
+	assert.Equal(t, 4, d.Len())
+	assert.Equal(t, []string{"a", "b", "c", "d"}, contents(t, d))
+
+	events, err := d.Slice(1, 3)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "b", events[0].String())
+
+	// out of range
+	events, err = d.Slice(10, 20)
+	require.NoError(t, err)
+	assert.Len(t, events, 0)
+
+	d.RemoveRange(1, 3)
+	assert.Equal(t, []string{"a", "d"}, contents(t, d))
+
+	d.Clear()
+	assert.True(t, d.IsEmpty())
+	assert.Len(t, segmentFiles(t, dir), 0)
+}
+
+func TestDiskEventTypes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir)
+	in := testutil.MockEvents()
+	d.Append(in...)
+	require.NoError(t, d.Close())
+
+	d = newTestDisk(t, dir)
+	defer d.Close()
+	out, err := d.Slice(0, d.Len())
+	require.NoError(t, err)
+	require.Len(t, out, len(in))
+
+	for i := range in {
+		assert.Equal(t, in[i].Type(), out[i].Type())
+		assert.Equal(t, in[i].Tags(), out[i].Tags())
+		assert.Equal(t, in[i].Fields(), out[i].Fields())
+		assert.True(t, in[i].Time().Equal(out[i].Time()))
+		assert.Equal(t, in[i].String(), out[i].String())
+	}
+	m := out[1].(optic.Metric)
+	assert.Equal(t, "test1", m.Name())
+	assert.Equal(t, optic.UntypedMetric, m.MetricType())
+}
+
+func TestDiskRecoversUnacknowledged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir)
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		d.Append(testutil.TestLogLine(s))
+	}
+	d.RemoveRange(0, 2)
+	d.RemoveRange(1, 2)
+	require.NoError(t, d.Close())
+
+	d = newTestDisk(t, dir)
+	assert.Equal(t, []string{"c", "e"}, contents(t, d))
+
+	// sequence numbers continue after recovery
+	d.Append(testutil.TestLogLine("f"))
+	d.RemoveRange(0, 1)
+	require.NoError(t, d.Close())
+
+	d = newTestDisk(t, dir)
+	defer d.Close()
+	assert.Equal(t, []string{"e", "f"}, contents(t, d))
+}
+
+func TestDiskSegmentsAreDeleted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := NewDisk().(*Disk)
+	d.Path = dir
+	d.SegmentSize = 1
+	require.NoError(t, d.Build())
+	defer d.Close()
+
+	for _, s := range []string{"a", "b", "c"} {
+		d.Append(testutil.TestLogLine(s))
+	}
+	assert.Len(t, segmentFiles(t, dir), 3)
+
+	d.RemoveRange(0, 2)
+	assert.Equal(t, []string{"c"}, contents(t, d))
+	// the removal record is written to a new segment
+	assert.Len(t, segmentFiles(t, dir), 2)
+
+	d.RemoveRange(0, 1)
+	assert.True(t, d.IsEmpty())
+	assert.Len(t, segmentFiles(t, dir), 1)
+}
+
+func TestDiskLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := NewDisk().(*Disk)
+	d.Path = dir
+	d.Limit = 2
+	require.NoError(t, d.Build())
+	defer d.Close()
+
+	d.Append(testutil.TestLogLine("a"), testutil.TestLogLine("b"), testutil.TestLogLine("c"))
+	assert.Equal(t, 2, d.Cap())
+	assert.Equal(t, []string{"b", "c"}, contents(t, d))
+}
+
+func TestDiskDelivery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := NewDisk().(*Disk)
+	d.Path = dir
+	d.Limit = 2
+	require.NoError(t, d.Build())
+	defer d.Close()
+
+	results := make(map[string]bool)
+	for _, s := range []string{"a", "b", "c"} {
+		content := s
+		event := testutil.TestLogLine(content)
+		event.SetDelivery(optic.NewDelivery(func(delivered bool) {
+			results[content] = delivered
+		}))
+		d.Append(event)
+	}
+	// persisting doesn't resolve the delivery, dropping does
+	assert.Equal(t, map[string]bool{"a": false}, results)
+
+	// the events read back resolve the delivery once written
+	events, err := d.Slice(0, d.Len())
+	require.NoError(t, err)
+	optic.Ack(events...)
+	assert.Equal(t, map[string]bool{"a": false, "b": true, "c": true}, results)
+}
+
+func TestDiskTornWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir)
+	d.Append(testutil.TestLogLine("a"), testutil.TestLogLine("b"))
+	require.NoError(t, d.Close())
+
+	// simulate a crash in the middle of writing the last record
+	files := segmentFiles(t, dir)
+	require.Len(t, files, 1)
+	fi, err := os.Stat(files[0])
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(files[0], fi.Size()-3))
+
+	d = newTestDisk(t, dir)
+	assert.Equal(t, []string{"a"}, contents(t, d))
+
+	d.Append(testutil.TestLogLine("c"))
+	require.NoError(t, d.Close())
+
+	d = newTestDisk(t, dir)
+	defer d.Close()
+	assert.Equal(t, []string{"a", "c"}, contents(t, d))
+}
+
+func TestDiskCorruptHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir)
+	d.Append(testutil.TestLogLine("a"))
+	offset := d.entries[0].size
+	d.Append(testutil.TestLogLine("b"))
+	require.NoError(t, d.Close())
+
+	// the length of the last record is damaged to claim nearly 4 GiB
+	files := segmentFiles(t, dir)
+	require.Len(t, files, 1)
+	f, err := os.OpenFile(files[0], os.O_RDWR, 0640)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff, 0xff, 0xff, 0xf0}, int64(offset))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	d = newTestDisk(t, dir)
+	defer d.Close()
+	assert.Equal(t, []string{"a"}, contents(t, d))
+	fi, err := os.Stat(files[0])
+	require.NoError(t, err)
+	assert.EqualValues(t, offset, fi.Size())
+}
+
+func TestDiskTimestamps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir)
+	defer d.Close()
+
+	m := testutil.TestMetric(int64(42))
+	d.Append(m)
+	events, err := d.Slice(0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC).UnixNano(),
+		events[0].Time().UnixNano())
+	assert.Equal(t, int64(42), events[0].Fields()["value"])
+}
@@ -0,0 +1,132 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/logline"
+	"github.com/zbiljic/optic/optic/metric"
+	"github.com/zbiljic/optic/optic/raw"
+)
+
+// Every record in a segment file is stored as:
+//
+//	| length (uint32) | crc32 of payload (uint32) | payload |
+//
+// where the first byte of the payload is the record kind.
+const recordHeaderSize = 8
+
+const (
+	_ byte = iota
+	kindEvent
+	kindRemove
+)
+
+var (
+	errShortRecord   = errors.New("short record")
+	errCorruptRecord = errors.New("record checksum mismatch")
+)
+
+// eventRecord is the persisted form of an event.
+type eventRecord struct {
+	Seq    uint64
+	Type   optic.EventType
+	Time   time.Time
+	Tags   map[string]string
+	Fields map[string]interface{}
+
+	// raw
+	Source string
+	Value  []byte
+	// metric
+	Name       string
+	MetricType optic.MetricType
+	// logline
+	Path    string
+	Content string
+}
+
+// removeRecord marks ranges of sequence numbers as removed.
+type removeRecord struct {
+	Ranges []seqRange
+}
+
+// seqRange is an inclusive range of sequence numbers.
+type seqRange struct {
+	From uint64
+	To   uint64
+}
+
+func encodeRecord(kind byte, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, recordHeaderSize))
+	buf.WriteByte(kind)
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	b := buf.Bytes()
+	payload := b[recordHeaderSize:]
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[4:8], crc32.ChecksumIEEE(payload))
+	return b, nil
+}
+
+// decodePayload verifies the payload and returns its kind and content.
+func decodePayload(header, payload []byte) (byte, []byte, error) {
+	if len(payload) == 0 || int(binary.BigEndian.Uint32(header[0:4])) != len(payload) {
+		return 0, nil, errShortRecord
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return 0, nil, errCorruptRecord
+	}
+	return payload[0], payload[1:], nil
+}
+
+func toRecord(seq uint64, event optic.Event) (*eventRecord, error) {
+	r := &eventRecord{
+		Seq:    seq,
+		Type:   event.Type(),
+		Time:   event.Time(),
+		Tags:   event.Tags(),
+		Fields: event.Fields(),
+	}
+	switch v := event.(type) {
+	case optic.Raw:
+		r.Source = v.Source()
+		r.Value = v.Value()
+	case optic.Metric:
+		r.Name = v.Name()
+		r.MetricType = v.MetricType()
+	case optic.LogLine:
+		r.Path = v.Path()
+		r.Content = v.Content()
+	default:
+		return nil, fmt.Errorf("unsupported event type: %s", event.Type())
+	}
+	return r, nil
+}
+
+func fromRecord(r *eventRecord) (optic.Event, error) {
+	if r.Tags == nil {
+		r.Tags = make(map[string]string)
+	}
+	if r.Fields == nil {
+		r.Fields = make(map[string]interface{})
+	}
+	switch r.Type {
+	case optic.RawEvent:
+		return raw.New(r.Source, r.Value, r.Tags, r.Fields, r.Time)
+	case optic.MetricEvent:
+		return metric.NewParsed(r.Name, r.Tags, r.Fields, r.Time, r.MetricType)
+	case optic.LogLineEvent:
+		return logline.New(r.Path, r.Content, r.Tags, r.Fields, r.Time)
+	}
+	return nil, fmt.Errorf("unsupported event type: %d", r.Type)
+}
@@ -67,14 +67,19 @@ func (m *Memory) Append(events ...optic.Event) {
 			// index for events array
 			ei := len(events) - m.Limit
 
+			// dropped events won't be delivered
+			optic.Nack(m.buffer...)
+			optic.Nack(events[:ei]...)
+
 			for i := range m.buffer {
-				// we must clear first
 				m.buffer[i] = nil
-				m.buffer[i] = events[ei+i]
 			}
+			m.buffer = append(m.buffer[:0], events[ei:]...)
 			return
 		}
 
+		// dropped events won't be delivered
+		optic.Nack(m.buffer[:-over]...)
 		m.RemoveRange(0, -over)
 	}
 
@@ -3,6 +3,10 @@ package memory
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
 	"github.com/zbiljic/optic/optic"
 )
 
@@ -10,3 +14,49 @@ import (
 func TestMemory_impl(t *testing.T) {
 	var _ optic.Buffer = new(Memory)
 }
+
+// trackedEvents returns log lines with the given contents, and the contents
+// of the ones which were nacked.
+func trackedEvents(contents ...string) ([]optic.Event, *[]string) {
+	nacked := &[]string{}
+	events := make([]optic.Event, len(contents))
+	for i, content := range contents {
+		content := content
+		events[i] = testutil.TestLogLine(content)
+		events[i].SetDelivery(optic.NewDelivery(func(delivered bool) {
+			if !delivered {
+				*nacked = append(*nacked, content)
+			}
+		}))
+	}
+	return events, nacked
+}
+
+func TestMemoryOverflowNack(t *testing.T) {
+	m := &Memory{Limit: 2}
+	require.NoError(t, m.Build())
+
+	events, nacked := trackedEvents("a", "b", "c")
+	m.Append(events[:2]...)
+	m.Append(events[2])
+
+	// the oldest event is evicted
+	assert.Equal(t, []string{"a"}, *nacked)
+	assert.Equal(t, 2, m.Len())
+}
+
+func TestMemoryOverflowSingleAppend(t *testing.T) {
+	m := &Memory{Limit: 2}
+	require.NoError(t, m.Build())
+
+	// more events than the limit, the last ones are kept
+	events, nacked := trackedEvents("a", "b", "c")
+	m.Append(events...)
+	assert.Equal(t, []string{"a"}, *nacked)
+
+	kept, err := m.Slice(0, m.Len())
+	require.NoError(t, err)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "b", kept[0].String())
+	assert.Equal(t, "c", kept[1].String())
+}
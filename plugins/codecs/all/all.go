@@ -1,5 +1,7 @@
 package all
 
 import (
+	_ "github.com/zbiljic/optic/plugins/codecs/influx"
+	_ "github.com/zbiljic/optic/plugins/codecs/json"
 	_ "github.com/zbiljic/optic/plugins/codecs/line"
 )
@@ -0,0 +1,131 @@
+package influx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+	"github.com/zbiljic/optic/plugins/codecs"
+)
+
+const (
+	name = "influx"
+)
+
+// InfluxCodec decodes and encodes metrics in InfluxDB line protocol.
+type InfluxCodec struct {
+	// EventType is the type of the event that will be produced by this codec.
+	EventType optic.EventType `mapstructure:"-"`
+
+	// Precision of the timestamps, one of "ns", "us", "ms" or "s".
+	Precision string `mapstructure:"precision"`
+
+	// DefaultTags will be added to every decoded event, unless the event
+	// already has a tag with the same key.
+	DefaultTags map[string]string `mapstructure:"tags"`
+}
+
+func NewInfluxCodec() optic.Codec {
+	return &InfluxCodec{
+		EventType:   optic.MetricEvent,
+		Precision:   "ns",
+		DefaultTags: make(map[string]string),
+	}
+}
+
+func (c *InfluxCodec) SetEventType(eventType optic.EventType) error {
+	switch eventType {
+	case optic.MetricEvent:
+		c.EventType = eventType
+	default:
+		return fmt.Errorf("%s codec does not support %s event type",
+			name, eventType)
+	}
+	return nil
+}
+
+func (c *InfluxCodec) Decode(src []byte) ([]optic.Event, error) {
+	precision, err := precisionDuration(c.Precision)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := newParser(src, precision).parse()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]optic.Event, 0, len(metrics))
+	for _, m := range metrics {
+		for k, v := range c.DefaultTags {
+			if !m.HasTag(k) {
+				m.AddTag(k, v)
+			}
+		}
+		events = append(events, m)
+	}
+	return events, nil
+}
+
+func (c *InfluxCodec) DecodeLine(line string) (optic.Event, error) {
+
+	events, err := c.Decode([]byte(line))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) < 1 {
+		return nil, fmt.Errorf("Can not decode line: [%s], for codec: %s", line, name)
+	}
+
+	return events[0], nil
+}
+
+func (c *InfluxCodec) Encode(event optic.Event) ([]byte, error) {
+	m, ok := event.(optic.Metric)
+	if !ok {
+		return nil, fmt.Errorf("%s codec does not support %s event type",
+			name, event.Type())
+	}
+
+	precision, err := precisionDuration(c.Precision)
+	if err != nil {
+		return nil, err
+	}
+
+	return metric.AppendLine(nil, m, precision)
+}
+
+func (c *InfluxCodec) EncodeTo(event optic.Event, dst []byte) error {
+	buf, err := c.Encode(event)
+	if err != nil {
+		return err
+	}
+	copy(dst, buf)
+	return nil
+}
+
+// ContentType returns the media type of the encoded events.
+func (c *InfluxCodec) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func precisionDuration(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "ns":
+		return time.Nanosecond, nil
+	case "us":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	}
+	return 0, fmt.Errorf("%s codec does not support precision: %s", name, precision)
+}
+
+func init() {
+	codecs.Add(name, NewInfluxCodec)
+}
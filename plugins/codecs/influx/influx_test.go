@@ -0,0 +1,231 @@
+package influx
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+)
+
+// Check the interfaces are satisfied
+func TestInfluxCodec_impl(t *testing.T) {
+	var _ optic.Codec = new(InfluxCodec)
+}
+
+func decodeMetric(t *testing.T, c optic.Codec, line string) optic.Metric {
+	event, err := c.DecodeLine(line)
+	require.NoError(t, err)
+	m, ok := event.(optic.Metric)
+	require.True(t, ok)
+	return m
+}
+
+func TestDecode(t *testing.T) {
+	c := NewInfluxCodec()
+
+	m := decodeMetric(t, c,
+		`cpu,host=localhost,region=us-west usage_idle=99.5,count=3i,bytes=18446744073709551615u,ok=true,msg="hello" 1257894000000000000`)
+	assert.Equal(t, "cpu", m.Name())
+	assert.Equal(t, map[string]string{"host": "localhost", "region": "us-west"}, m.Tags())
+	assert.Equal(t, map[string]interface{}{
+		"usage_idle": float64(99.5),
+		"count":      int64(3),
+		"bytes":      uint64(math.MaxUint64),
+		"ok":         true,
+		"msg":        "hello",
+	}, m.Fields())
+	assert.Equal(t, int64(1257894000000000000), m.Time().UnixNano())
+}
+
+func TestDecodeEscaping(t *testing.T) {
+	c := NewInfluxCodec()
+
+	m := decodeMetric(t, c,
+		`my\ measurement\,x,tag\ key=tag\,value\=1,path=C:\dir field\=key="quote \" backslash \\ comma, space" 1`)
+	assert.Equal(t, "my measurement,x", m.Name())
+	assert.Equal(t, map[string]string{"tag key": "tag,value=1", "path": `C:\dir`}, m.Tags())
+	assert.Equal(t, map[string]interface{}{
+		"field=key": `quote " backslash \ comma, space`,
+	}, m.Fields())
+}
+
+func TestDecodeMultipleLines(t *testing.T) {
+	c := NewInfluxCodec()
+
+	events, err := c.Decode([]byte(
+		"# comment\n" +
+			"\n" +
+			"a value=1 1\r\n" +
+			"b value=\"multi\nline\" 2\n" +
+			"c value=3i\n"))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, "a", events[0].(optic.Metric).Name())
+	assert.Equal(t, "multi\nline", events[1].Fields()["value"])
+	assert.Equal(t, int64(2), events[1].Time().UnixNano())
+	// missing timestamp is the current time
+	assert.WithinDuration(t, time.Now(), events[2].Time(), time.Minute)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	c := NewInfluxCodec()
+
+	for _, line := range []string{
+		`cpu`,
+		`cpu value`,
+		`cpu value=`,
+		`cpu,host value=1`,
+		`cpu,host= value=1`,
+		`cpu value=1i2`,
+		`cpu value=NaN`,
+		`cpu value=inf`,
+		`cpu value=abc`,
+		`cpu value=-1u`,
+		`cpu value="unterminated`,
+		`cpu value=1 abc`,
+		`cpu value=1 1 extra`,
+		`,host=a value=1`,
+	} {
+		_, err := c.DecodeLine(line)
+		assert.Error(t, err, line)
+	}
+
+	_, err := c.Decode([]byte("a value=1\nb value=\n"))
+	require.Error(t, err)
+	assert.Equal(t, 2, err.(*ParseError).Line)
+}
+
+func TestDecodePrecision(t *testing.T) {
+	c := NewInfluxCodec().(*InfluxCodec)
+	c.Precision = "s"
+
+	m := decodeMetric(t, c, `cpu value=1 1257894000`)
+	assert.Equal(t, int64(1257894000000000000), m.Time().UnixNano())
+
+	b, err := c.Encode(m)
+	require.NoError(t, err)
+	assert.Equal(t, "cpu value=1 1257894000\n", string(b))
+
+	c.Precision = "h"
+	_, err = c.DecodeLine(`cpu value=1 1`)
+	assert.Error(t, err)
+}
+
+func TestDecodeDefaultTags(t *testing.T) {
+	c := NewInfluxCodec().(*InfluxCodec)
+	c.DefaultTags = map[string]string{"host": "default", "dc": "eu"}
+
+	m := decodeMetric(t, c, `cpu,host=a value=1 1`)
+	assert.Equal(t, map[string]string{"host": "a", "dc": "eu"}, m.Tags())
+}
+
+func TestEncode(t *testing.T) {
+	c := NewInfluxCodec()
+
+	m, err := metric.NewParsed(
+		"cpu load",
+		map[string]string{"z": "1", "a": "x,y", "empty": ""},
+		map[string]interface{}{
+			"f":   float64(1),
+			"i":   int64(-2),
+			"u":   uint64(3),
+			"b":   false,
+			"s":   `a "b" \c`,
+			"nan": math.NaN(),
+		},
+		time.Unix(0, 1257894000000000000),
+	)
+	require.NoError(t, err)
+
+	b, err := c.Encode(m)
+	require.NoError(t, err)
+	assert.Equal(t,
+		`cpu\ load,a=x\,y,z=1 b=false,f=1,i=-2i,s="a \"b\" \\c",u=3u 1257894000000000000`+"\n",
+		string(b))
+
+	// only unsupported fields
+	m, err = metric.NewParsed("cpu", nil, map[string]interface{}{"nan": math.NaN()}, time.Now())
+	require.NoError(t, err)
+	_, err = c.Encode(m)
+	assert.Error(t, err)
+
+	// other event types
+	_, err = c.Encode(testutil.TestLogLine("line"))
+	assert.Error(t, err)
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	c := NewInfluxCodec()
+
+	in, err := metric.NewParsed(
+		"weird name,with=chars",
+		map[string]string{"k e=y,": "v a=l,ue", `back\slash`: `C:\dir`},
+		map[string]interface{}{
+			"float":  float64(1),
+			"small":  float64(0.000001),
+			"big":    float64(1e21),
+			"int":    int64(math.MinInt64),
+			"uint":   uint64(math.MaxUint64),
+			"bool":   true,
+			"string": "line\nbreak \"quoted\" \\",
+		},
+		time.Unix(0, 1257894000123456789),
+		optic.GaugeMetric,
+	)
+	require.NoError(t, err)
+
+	b, err := c.Encode(in)
+	require.NoError(t, err)
+
+	out := decodeMetric(t, c, string(b))
+	assert.Equal(t, in.Name(), out.Name())
+	assert.Equal(t, in.Tags(), out.Tags())
+	assert.Equal(t, in.Fields(), out.Fields())
+	assert.Equal(t, in.Time().UnixNano(), out.Time().UnixNano())
+
+	again, err := c.Encode(out)
+	require.NoError(t, err)
+	assert.Equal(t, b, again)
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+	c := NewInfluxCodec()
+
+	// the line protocol of the metric itself parses as well
+	in, err := metric.NewParsed(
+		"cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{
+			"bytes": []byte(`raw "data"`),
+			"uint":  uint64(math.MaxUint64),
+			"nan":   math.NaN(),
+			"inf":   math.Inf(1),
+			"value": float64(1.5),
+		},
+		time.Unix(0, 1257894000000000000),
+	)
+	require.NoError(t, err)
+
+	out := decodeMetric(t, c, string(in.Serialize()))
+	assert.Equal(t, map[string]interface{}{
+		"bytes": `raw "data"`,
+		"uint":  uint64(math.MaxUint64),
+		"value": float64(1.5),
+	}, out.Fields())
+
+	b, err := c.Encode(in)
+	require.NoError(t, err)
+	assert.Equal(t, string(in.Serialize())+"\n", string(b))
+}
+
+func TestSetEventType(t *testing.T) {
+	c := NewInfluxCodec()
+	assert.NoError(t, c.SetEventType(optic.MetricEvent))
+	assert.Error(t, c.SetEventType(optic.RawEvent))
+}
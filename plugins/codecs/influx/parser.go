@@ -0,0 +1,299 @@
+package influx
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+)
+
+// ParseError describes a line protocol syntax error.
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line protocol error on line %d: %s", e.Line, e.Msg)
+}
+
+// Characters which can be escaped with a backslash, per element of the line.
+const (
+	measurementEscapes = ", "
+	keyEscapes         = ",= "
+)
+
+// parser is a line protocol parser as described in
+// https://docs.influxdata.com/influxdb/latest/reference/syntax/line-protocol/
+type parser struct {
+	buf       []byte
+	pos       int
+	precision time.Duration
+	now       func() time.Time
+}
+
+func newParser(buf []byte, precision time.Duration) *parser {
+	return &parser{
+		buf:       buf,
+		precision: precision,
+		now:       time.Now,
+	}
+}
+
+// parse returns all metrics in the buffer. Empty lines and comments, lines
+// starting with '#', are skipped.
+func (p *parser) parse() ([]optic.Metric, error) {
+	metrics := make([]optic.Metric, 0)
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		switch p.buf[p.pos] {
+		case '\n', '\r':
+			p.pos++
+			continue
+		case '#':
+			p.skipLine()
+			continue
+		}
+
+		m, err := p.parseLine()
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+func (p *parser) parseLine() (optic.Metric, error) {
+	name, err := p.readToken(measurementEscapes, ", ")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, p.error("missing measurement")
+	}
+
+	tags := make(map[string]string)
+	for !p.eof() && p.buf[p.pos] == ',' {
+		p.pos++
+		key, err := p.readKey()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.readToken(keyEscapes, ", ")
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			return nil, p.error(fmt.Sprintf("missing tag value for key %q", key))
+		}
+		tags[key] = value
+	}
+
+	if p.eof() || p.buf[p.pos] != ' ' {
+		return nil, p.error("missing fields")
+	}
+	p.skipSpace()
+
+	fields := make(map[string]interface{})
+	for {
+		key, err := p.readKey()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.readFieldValue()
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = value
+
+		if p.eof() || p.buf[p.pos] != ',' {
+			break
+		}
+		p.pos++
+	}
+
+	ts, err := p.readTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	return metric.NewParsed(name, tags, fields, ts)
+}
+
+// readKey reads a tag or field key, including the '=' which follows it.
+func (p *parser) readKey() (string, error) {
+	key, err := p.readToken(keyEscapes, ",= ")
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", p.error("missing key")
+	}
+	if p.eof() || p.buf[p.pos] != '=' {
+		return "", p.error(fmt.Sprintf("missing '=' after key %q", key))
+	}
+	p.pos++
+	return key, nil
+}
+
+// readToken reads until one of the unescaped stop characters or the end of
+// the line. A backslash followed by one of escapes is replaced by that
+// character, any other backslash is kept as it is.
+func (p *parser) readToken(escapes, stops string) (string, error) {
+	var b []byte
+	for !p.eof() {
+		c := p.buf[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.buf) && bytes.IndexByte([]byte(escapes), p.buf[p.pos+1]) >= 0:
+			b = append(b, p.buf[p.pos+1])
+			p.pos += 2
+			continue
+		case c == '\n' || c == '\r':
+			return string(b), nil
+		case bytes.IndexByte([]byte(stops), c) >= 0:
+			return string(b), nil
+		}
+		b = append(b, c)
+		p.pos++
+	}
+	return string(b), nil
+}
+
+func (p *parser) readFieldValue() (interface{}, error) {
+	if p.eof() {
+		return nil, p.error("missing field value")
+	}
+
+	if p.buf[p.pos] == '"' {
+		return p.readString()
+	}
+
+	start := p.pos
+	for !p.eof() && bytes.IndexByte([]byte(", \r\n"), p.buf[p.pos]) < 0 {
+		p.pos++
+	}
+	token := string(p.buf[start:p.pos])
+	if token == "" {
+		return nil, p.error("missing field value")
+	}
+
+	switch token {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	switch token[len(token)-1] {
+	case 'i':
+		v, err := strconv.ParseInt(token[:len(token)-1], 10, 64)
+		if err != nil {
+			return nil, p.error(fmt.Sprintf("invalid integer %q", token))
+		}
+		return v, nil
+	case 'u':
+		v, err := strconv.ParseUint(token[:len(token)-1], 10, 64)
+		if err != nil {
+			return nil, p.error(fmt.Sprintf("invalid unsigned integer %q", token))
+		}
+		return v, nil
+	}
+
+	// ParseFloat also accepts special values which are not valid here
+	if c := token[0]; c != '-' && c != '+' && c != '.' && (c < '0' || c > '9') {
+		return nil, p.error(fmt.Sprintf("invalid field value %q", token))
+	}
+	v, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return nil, p.error(fmt.Sprintf("invalid float %q", token))
+	}
+	return v, nil
+}
+
+// readString reads a double quoted string, in which only '"' and '\' can be
+// escaped. Strings may span multiple lines.
+func (p *parser) readString() (string, error) {
+	p.pos++ // opening quote
+	var b []byte
+	for !p.eof() {
+		c := p.buf[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.buf) && (p.buf[p.pos+1] == '"' || p.buf[p.pos+1] == '\\'):
+			b = append(b, p.buf[p.pos+1])
+			p.pos += 2
+			continue
+		case c == '"':
+			p.pos++
+			return string(b), nil
+		}
+		b = append(b, c)
+		p.pos++
+	}
+	return "", p.error("unterminated string")
+}
+
+func (p *parser) readTimestamp() (time.Time, error) {
+	for !p.eof() && p.buf[p.pos] == ' ' {
+		p.pos++
+	}
+
+	start := p.pos
+	for !p.eof() && bytes.IndexByte([]byte(" \r\n"), p.buf[p.pos]) < 0 {
+		p.pos++
+	}
+	token := string(p.buf[start:p.pos])
+
+	p.skipSpace()
+	if !p.eof() && p.buf[p.pos] == '\r' {
+		p.pos++
+	}
+	if !p.eof() {
+		if p.buf[p.pos] != '\n' {
+			return time.Time{}, p.error("unexpected data after timestamp")
+		}
+		p.pos++
+	}
+
+	if token == "" {
+		return p.now(), nil
+	}
+	ts, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return time.Time{}, p.error(fmt.Sprintf("invalid timestamp %q", token))
+	}
+	return time.Unix(0, ts*int64(p.precision)), nil
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && (p.buf[p.pos] == ' ' || p.buf[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) skipLine() {
+	for !p.eof() && p.buf[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.buf)
+}
+
+func (p *parser) error(msg string) error {
+	pos := p.pos
+	if pos > len(p.buf) {
+		pos = len(p.buf)
+	}
+	return &ParseError{
+		Line: bytes.Count(p.buf[:pos], []byte{'\n'}) + 1,
+		Msg:  msg,
+	}
+}
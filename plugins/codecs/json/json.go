@@ -0,0 +1,480 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/logline"
+	"github.com/zbiljic/optic/optic/metric"
+	"github.com/zbiljic/optic/optic/raw"
+	"github.com/zbiljic/optic/plugins/codecs"
+)
+
+const (
+	name = "json"
+)
+
+// Supported non-layout values of the time format.
+const (
+	timeFormatUnix   = "unix"
+	timeFormatUnixMs = "unix_ms"
+	timeFormatUnixUs = "unix_us"
+	timeFormatUnixNs = "unix_ns"
+)
+
+// JSONCodec decodes JSON objects, arrays of objects and newline-delimited JSON
+// into events, and encodes events into JSON documents.
+//
+// All keys are paths into the document, with nested objects separated by a
+// dot, e.g. "request.host".
+type JSONCodec struct {
+	// EventType is the type of the event that will be produced by this codec.
+	EventType optic.EventType `mapstructure:"-"`
+
+	// NameKey is the path of the metric name.
+	NameKey string `mapstructure:"name_key"`
+	// Name is the metric name used if the document has no name.
+	Name string `mapstructure:"name"`
+
+	// MetricTypeKey is the path of the metric type.
+	MetricTypeKey string `mapstructure:"metric_type_key"`
+
+	// TimeKey is the path of the event timestamp.
+	TimeKey string `mapstructure:"time_key"`
+	// TimeFormat is either one of "unix", "unix_ms", "unix_us", "unix_ns" or a
+	// Go time layout.
+	TimeFormat string `mapstructure:"time_format"`
+
+	// TagKeys are paths of tags. A path pointing to an object adds all of its
+	// members as tags. When encoding, tags are written as an object under the
+	// first path.
+	TagKeys []string `mapstructure:"tag_keys"`
+
+	// FieldKeys are paths of fields. A path pointing to an object adds all of
+	// its members as fields. When encoding, fields are written as an object
+	// under the first path.
+	FieldKeys []string `mapstructure:"field_keys"`
+
+	// SourceKey and ValueKey are paths of the raw event source and value.
+	SourceKey string `mapstructure:"source_key"`
+	ValueKey  string `mapstructure:"value_key"`
+
+	// PathKey and ContentKey are paths of the log line path and content.
+	PathKey    string `mapstructure:"path_key"`
+	ContentKey string `mapstructure:"content_key"`
+
+	// DefaultTags will be added to every decoded event.
+	DefaultTags map[string]string `mapstructure:"tags"`
+}
+
+func NewJSONCodec() optic.Codec {
+	return &JSONCodec{
+		EventType:     optic.MetricEvent,
+		NameKey:       "name",
+		MetricTypeKey: "metric_type",
+		TimeKey:       "timestamp",
+		TimeFormat:    time.RFC3339Nano,
+		TagKeys:       []string{"tags"},
+		FieldKeys:     []string{"fields"},
+		SourceKey:     "source",
+		ValueKey:      "value",
+		PathKey:       "path",
+		ContentKey:    "content",
+		DefaultTags:   make(map[string]string),
+	}
+}
+
+func (c *JSONCodec) SetEventType(eventType optic.EventType) error {
+	switch eventType {
+	case optic.RawEvent, optic.MetricEvent, optic.LogLineEvent:
+		c.EventType = eventType
+	default:
+		return fmt.Errorf("%s codec does not support %s event type",
+			name, eventType)
+	}
+	return nil
+}
+
+func (c *JSONCodec) Decode(src []byte) ([]optic.Event, error) {
+	events := make([]optic.Event, 0)
+
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch doc := v.(type) {
+		case map[string]interface{}:
+			event, err := c.decodeObject(doc)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		case []interface{}:
+			for _, item := range doc {
+				obj, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("expected JSON object, got: %T", item)
+				}
+				event, err := c.decodeObject(obj)
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, event)
+			}
+		default:
+			return nil, fmt.Errorf("expected JSON object or array, got: %T", v)
+		}
+	}
+
+	return events, nil
+}
+
+func (c *JSONCodec) DecodeLine(line string) (optic.Event, error) {
+
+	events, err := c.Decode([]byte(line))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) < 1 {
+		return nil, fmt.Errorf("Can not decode line: [%s], for codec: %s", line, name)
+	}
+
+	return events[0], nil
+}
+
+func (c *JSONCodec) decodeObject(doc map[string]interface{}) (optic.Event, error) {
+	ts, err := c.decodeTime(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for k, v := range c.DefaultTags {
+		tags[k] = v
+	}
+	for _, key := range c.TagKeys {
+		v, ok := lookup(doc, key)
+		if !ok {
+			continue
+		}
+		if obj, ok := v.(map[string]interface{}); ok {
+			for k, v := range obj {
+				if s, ok := toTag(v); ok {
+					tags[k] = s
+				}
+			}
+			continue
+		}
+		if s, ok := toTag(v); ok {
+			tags[lastElement(key)] = s
+		}
+	}
+
+	fields := make(map[string]interface{})
+	for _, key := range c.FieldKeys {
+		v, ok := lookup(doc, key)
+		if !ok {
+			continue
+		}
+		if obj, ok := v.(map[string]interface{}); ok {
+			for k, v := range obj {
+				flatten(fields, k, v)
+			}
+			continue
+		}
+		flatten(fields, lastElement(key), v)
+	}
+
+	switch c.EventType {
+	case optic.RawEvent:
+		source := name
+		if v, ok := lookup(doc, c.SourceKey); ok {
+			source = fmt.Sprint(v)
+		}
+		var value []byte
+		if v, ok := lookup(doc, c.ValueKey); ok {
+			if s, ok := v.(string); ok {
+				value = []byte(s)
+			} else if value, err = json.Marshal(v); err != nil {
+				return nil, err
+			}
+		} else if value, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+		return raw.New(source, value, tags, fields, ts)
+	case optic.MetricEvent:
+		metricName := c.Name
+		if v, ok := lookup(doc, c.NameKey); ok {
+			metricName = fmt.Sprint(v)
+		}
+		metricType := optic.UntypedMetric
+		if v, ok := lookup(doc, c.MetricTypeKey); ok {
+			if metricType, err = parseMetricType(fmt.Sprint(v)); err != nil {
+				return nil, err
+			}
+		}
+		return metric.New(metricName, tags, fields, ts, metricType)
+	case optic.LogLineEvent:
+		var path string
+		if v, ok := lookup(doc, c.PathKey); ok {
+			path = fmt.Sprint(v)
+		}
+		var content string
+		if v, ok := lookup(doc, c.ContentKey); ok {
+			content = fmt.Sprint(v)
+		} else {
+			b, err := json.Marshal(doc)
+			if err != nil {
+				return nil, err
+			}
+			content = string(b)
+		}
+		return logline.New(path, content, tags, fields, ts)
+	}
+
+	return nil, fmt.Errorf("%s codec does not support %s event type",
+		name, c.EventType)
+}
+
+func (c *JSONCodec) decodeTime(doc map[string]interface{}) (time.Time, error) {
+	v, ok := lookup(doc, c.TimeKey)
+	if !ok {
+		return time.Now(), nil
+	}
+
+	switch c.TimeFormat {
+	case timeFormatUnix, timeFormatUnixMs, timeFormatUnixUs, timeFormatUnixNs:
+		unit := timeUnit(c.TimeFormat)
+		s := fmt.Sprint(v)
+		// integer timestamps are parsed separately to avoid float rounding
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(0, i*int64(unit)), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp: %v", v)
+		}
+		return time.Unix(0, int64(f*float64(unit))), nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %v", v)
+	}
+	return time.Parse(c.TimeFormat, s)
+}
+
+func (c *JSONCodec) Encode(event optic.Event) ([]byte, error) {
+	doc := make(map[string]interface{})
+
+	c.encodeTime(doc, event.Time())
+
+	tagKey, fieldKey := "tags", "fields"
+	if len(c.TagKeys) > 0 {
+		tagKey = c.TagKeys[0]
+	}
+	if len(c.FieldKeys) > 0 {
+		fieldKey = c.FieldKeys[0]
+	}
+	set(doc, tagKey, event.Tags())
+	set(doc, fieldKey, encodeFields(event.Fields()))
+
+	switch v := event.(type) {
+	case optic.Raw:
+		set(doc, c.SourceKey, v.Source())
+		set(doc, c.ValueKey, string(v.Value()))
+	case optic.Metric:
+		set(doc, c.NameKey, v.Name())
+		set(doc, c.MetricTypeKey, v.MetricType().String())
+	case optic.LogLine:
+		set(doc, c.PathKey, v.Path())
+		set(doc, c.ContentKey, v.Content())
+	default:
+		return nil, fmt.Errorf("%s codec does not support %s event type",
+			name, event.Type())
+	}
+
+	// maps are marshaled with sorted keys, which keeps the output stable
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func (c *JSONCodec) EncodeTo(event optic.Event, dst []byte) error {
+	buf, err := c.Encode(event)
+	if err != nil {
+		return err
+	}
+	if len(dst) < len(buf) {
+		return fmt.Errorf("%s codec needs %d bytes to encode the event, got %d",
+			name, len(buf), len(dst))
+	}
+	copy(dst, buf)
+	return nil
+}
+
+// ContentType returns the media type of the encoded events. Events are
+// encoded one per line, so several of them are newline delimited JSON.
+func (c *JSONCodec) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (c *JSONCodec) encodeTime(doc map[string]interface{}, t time.Time) {
+	switch c.TimeFormat {
+	case timeFormatUnix, timeFormatUnixMs, timeFormatUnixUs, timeFormatUnixNs:
+		set(doc, c.TimeKey, t.UnixNano()/int64(timeUnit(c.TimeFormat)))
+	default:
+		set(doc, c.TimeKey, t.UTC().Format(c.TimeFormat))
+	}
+}
+
+// encodeFields keeps integral floats distinguishable from integers, so the
+// type of the field survives a round trip.
+func encodeFields(fields map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		var f float64
+		switch v := v.(type) {
+		case float64:
+			f = v
+		case float32:
+			f = float64(v)
+		default:
+			out[k] = v
+			continue
+		}
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEnN") {
+			s += ".0"
+		}
+		out[k] = json.Number(s)
+	}
+	return out
+}
+
+func timeUnit(format string) time.Duration {
+	switch format {
+	case timeFormatUnix:
+		return time.Second
+	case timeFormatUnixMs:
+		return time.Millisecond
+	case timeFormatUnixUs:
+		return time.Microsecond
+	}
+	return time.Nanosecond
+}
+
+func parseMetricType(s string) (optic.MetricType, error) {
+	for _, mt := range []optic.MetricType{
+		optic.CounterMetric,
+		optic.GaugeMetric,
+		optic.UntypedMetric,
+		optic.HistogramMetric,
+		optic.SummaryMetric,
+	} {
+		if mt.String() == s {
+			return mt, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid metric type: %s", s)
+}
+
+// lookup returns the value at the dot separated path.
+func lookup(doc map[string]interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return nil, false
+	}
+	var cur interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, cur != nil
+}
+
+// set stores the value at the dot separated path, creating intermediate
+// objects as needed.
+func set(doc map[string]interface{}, path string, value interface{}) {
+	if path == "" {
+		return
+	}
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		obj, ok := doc[key].(map[string]interface{})
+		if !ok {
+			obj = make(map[string]interface{})
+			doc[key] = obj
+		}
+		doc = obj
+	}
+	doc[keys[len(keys)-1]] = value
+}
+
+func lastElement(path string) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+func toTag(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// flatten adds the value as a field, joining keys of nested objects and
+// arrays with an underscore.
+func flatten(fields map[string]interface{}, key string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			flatten(fields, key+"_"+k, item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flatten(fields, key+"_"+strconv.Itoa(i), item)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			fields[key] = i
+		} else if f, err := v.Float64(); err == nil {
+			fields[key] = f
+		}
+	case string, bool:
+		fields[key] = v
+	}
+}
+
+func init() {
+	codecs.Add(name, NewJSONCodec)
+}
@@ -0,0 +1,180 @@
+package json
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestJSONCodec_impl(t *testing.T) {
+	var _ optic.Codec = new(JSONCodec)
+}
+
+func TestDecodeMetric(t *testing.T) {
+	c := NewJSONCodec()
+
+	events, err := c.Decode([]byte(`{
+		"name": "cpu",
+		"metric_type": "gauge",
+		"timestamp": "2009-11-10T23:00:00Z",
+		"tags": {"host": "localhost"},
+		"fields": {"usage_idle": 99.5, "count": 3, "ok": true}
+	}`))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	m, ok := events[0].(optic.Metric)
+	require.True(t, ok)
+	assert.Equal(t, "cpu", m.Name())
+	assert.Equal(t, optic.GaugeMetric, m.MetricType())
+	assert.Equal(t, time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC), m.Time())
+	assert.Equal(t, map[string]string{"host": "localhost"}, m.Tags())
+	assert.Equal(t, map[string]interface{}{
+		"usage_idle": float64(99.5),
+		"count":      int64(3),
+		"ok":         true,
+	}, m.Fields())
+}
+
+func TestDecodeNewlineDelimitedAndArray(t *testing.T) {
+	c := NewJSONCodec()
+
+	events, err := c.Decode([]byte(
+		`{"name": "a", "fields": {"value": 1}}` + "\n" +
+			`[{"name": "b", "fields": {"value": 2}}, {"name": "c", "fields": {"value": 3}}]` + "\n"))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	for i, n := range []string{"a", "b", "c"} {
+		assert.Equal(t, n, events[i].(optic.Metric).Name())
+	}
+
+	_, err = c.Decode([]byte(`{"name": "a", "fields": {"value": 1}`))
+	assert.Error(t, err)
+	_, err = c.Decode([]byte(`"string"`))
+	assert.Error(t, err)
+}
+
+func TestDecodeCustomPaths(t *testing.T) {
+	c := &JSONCodec{
+		EventType:  optic.MetricEvent,
+		Name:       "http",
+		NameKey:    "measurement",
+		TimeKey:    "meta.ts",
+		TimeFormat: "unix_ms",
+		TagKeys:    []string{"request.host", "request.method"},
+		FieldKeys:  []string{"response.status", "response.timing"},
+	}
+
+	event, err := c.DecodeLine(`{
+		"meta": {"ts": 1257894000123},
+		"request": {"host": "example.com", "method": "GET", "path": "/"},
+		"response": {"status": 200, "timing": {"dns": 0.5, "total": 12.25}}
+	}`)
+	require.NoError(t, err)
+
+	m := event.(optic.Metric)
+	assert.Equal(t, "http", m.Name())
+	assert.Equal(t, int64(1257894000123000000), m.Time().UnixNano())
+	assert.Equal(t, map[string]string{"host": "example.com", "method": "GET"}, m.Tags())
+	assert.Equal(t, map[string]interface{}{
+		"status": int64(200),
+		"dns":    float64(0.5),
+		"total":  float64(12.25),
+	}, m.Fields())
+}
+
+func TestDecodeLogLineAndRaw(t *testing.T) {
+	c := NewJSONCodec()
+	require.NoError(t, c.SetEventType(optic.LogLineEvent))
+
+	event, err := c.DecodeLine(`{"path": "/var/log/app.log", "content": "hello", "tags": {"app": "x"}}`)
+	require.NoError(t, err)
+	ll := event.(optic.LogLine)
+	assert.Equal(t, "/var/log/app.log", ll.Path())
+	assert.Equal(t, "hello", ll.Content())
+	assert.Equal(t, map[string]string{"app": "x"}, ll.Tags())
+
+	require.NoError(t, c.SetEventType(optic.RawEvent))
+	event, err = c.DecodeLine(`{"source": "app", "value": "payload"}`)
+	require.NoError(t, err)
+	r := event.(optic.Raw)
+	assert.Equal(t, "app", r.Source())
+	assert.Equal(t, []byte("payload"), r.Value())
+
+	// without a value key the whole document is the value
+	event, err = c.DecodeLine(`{"a":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"a":1}`), event.(optic.Raw).Value())
+}
+
+func TestEncode(t *testing.T) {
+	c := NewJSONCodec()
+
+	m := testutil.TestMetric(float64(1))
+	b, err := c.Encode(m)
+	require.NoError(t, err)
+	assert.Equal(t,
+		`{"fields":{"value":1.0},"metric_type":"untyped","name":"test1","tags":{"tag1":"value1"},"timestamp":"2009-11-10T23:00:00Z"}`+"\n",
+		string(b))
+
+	// encoding is stable
+	for i := 0; i < 10; i++ {
+		again, err := c.Encode(m)
+		require.NoError(t, err)
+		assert.Equal(t, b, again)
+	}
+}
+
+func TestEncodeTo(t *testing.T) {
+	c := NewJSONCodec()
+
+	m := testutil.TestMetric(float64(1))
+	b, err := c.Encode(m)
+	require.NoError(t, err)
+
+	dst := make([]byte, len(b))
+	require.NoError(t, c.EncodeTo(m, dst))
+	assert.Equal(t, b, dst)
+
+	// not cut short
+	err = c.EncodeTo(m, make([]byte, len(b)-1))
+	assert.Error(t, err)
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, eventType := range []optic.EventType{
+		optic.RawEvent,
+		optic.MetricEvent,
+		optic.LogLineEvent,
+	} {
+		c := NewJSONCodec()
+		require.NoError(t, c.SetEventType(eventType))
+
+		for _, in := range testutil.MockEvents() {
+			if in.Type() != eventType {
+				continue
+			}
+			b, err := c.Encode(in)
+			require.NoError(t, err)
+
+			out, err := c.DecodeLine(string(b))
+			require.NoError(t, err)
+			assert.Equal(t, in.Type(), out.Type())
+			assert.Equal(t, in.Tags(), out.Tags())
+			assert.Equal(t, in.String(), out.String())
+			assert.Equal(t, in.Time().UnixNano(), out.Time().UnixNano())
+		}
+	}
+}
+
+func TestSetEventType(t *testing.T) {
+	c := NewJSONCodec()
+	assert.NoError(t, c.SetEventType(optic.LogLineEvent))
+	assert.Error(t, c.SetEventType(optic.EventType(0)))
+}
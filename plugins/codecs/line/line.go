@@ -124,6 +124,11 @@ func (c *LineCodec) EncodeTo(event optic.Event, dst []byte) error {
 	return err
 }
 
+// ContentType returns the media type of the encoded events.
+func (c *LineCodec) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
 func init() {
 	codecs.Add(name, NewLineCodec)
 }
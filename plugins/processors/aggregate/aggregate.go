@@ -0,0 +1,246 @@
+package aggregate
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+	"github.com/zbiljic/optic/plugins/processors"
+)
+
+const (
+	name        = "aggregate"
+	description = `Aggregate metric fields over a period.`
+)
+
+const (
+	defaultPeriod = 30 * time.Second
+
+	// DefaultPercentileLimit is the default number of values of a field kept
+	// for calculating percentiles.
+	DefaultPercentileLimit = 1000
+)
+
+// Aggregate groups metrics by name and tags, and emits statistics of their
+// numeric fields on the first flush after every period.
+type Aggregate struct {
+	// Period over which metrics are aggregated.
+	Period time.Duration `mapstructure:"period"`
+
+	// Percentiles calculated for every field, e.g. 90 or 99.9.
+	Percentiles []float64 `mapstructure:"percentiles"`
+
+	// PercentileLimit is the maximum number of values of a single field kept
+	// for calculating percentiles. Once reached, values are sampled.
+	PercentileLimit int `mapstructure:"percentile_limit"`
+
+	// DropOriginal drops the aggregated metrics, only the aggregates are
+	// passed on.
+	DropOriginal bool `mapstructure:"drop_original"`
+
+	mu     sync.Mutex
+	series map[uint64]*series
+	start  time.Time
+	now    func() time.Time
+}
+
+// series is a metric name and tag set with the statistics of its fields.
+type series struct {
+	name   string
+	tags   map[string]string
+	fields map[string]*stats
+}
+
+// stats keeps running statistics over all values of a field, and a sample of
+// the values for percentiles.
+type stats struct {
+	count  int64
+	sum    float64
+	sumSq  float64
+	min    float64
+	max    float64
+	values []float64
+}
+
+func NewAggregate() optic.Processor {
+	return &Aggregate{
+		Period:          defaultPeriod,
+		PercentileLimit: DefaultPercentileLimit,
+		now:             time.Now,
+	}
+}
+
+func (*Aggregate) Kind() string {
+	return name
+}
+
+func (*Aggregate) Description() string {
+	return description
+}
+
+func (a *Aggregate) Init() error {
+	if a.Period < 0 {
+		return fmt.Errorf("%s: invalid period: %s", name, a.Period)
+	}
+	for _, p := range a.Percentiles {
+		if p <= 0 || p > 100 {
+			return fmt.Errorf("%s: invalid percentile: %v", name, p)
+		}
+	}
+	if a.PercentileLimit <= 0 {
+		a.PercentileLimit = DefaultPercentileLimit
+	}
+	if a.now == nil {
+		a.now = time.Now
+	}
+
+	a.series = make(map[uint64]*series)
+	a.start = a.now()
+	return nil
+}
+
+// Apply adds metrics to the aggregation. Called with no events, as it is on
+// every flush, it emits the aggregates once the period has passed.
+func (a *Aggregate) Apply(in ...optic.Event) []optic.Event {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(in) == 0 {
+		return a.flush()
+	}
+
+	out := in[:0]
+	for _, event := range in {
+		m, ok := event.(optic.Metric)
+		if !ok {
+			out = append(out, event)
+			continue
+		}
+		a.add(m)
+		if !a.DropOriginal {
+			out = append(out, event)
+		}
+	}
+	return out
+}
+
+func (a *Aggregate) add(m optic.Metric) {
+	id := m.HashID()
+	s, ok := a.series[id]
+	if !ok {
+		tags := make(map[string]string, len(m.Tags()))
+		for k, v := range m.Tags() {
+			tags[k] = v
+		}
+		s = &series{
+			name:   m.Name(),
+			tags:   tags,
+			fields: make(map[string]*stats),
+		}
+		a.series[id] = s
+	}
+
+	for k, v := range m.Fields() {
+		value, ok := metric.ToFloat(v)
+		if !ok {
+			continue
+		}
+		st, ok := s.fields[k]
+		if !ok {
+			st = &stats{min: value, max: value}
+			s.fields[k] = st
+		}
+		st.add(value, a.PercentileLimit)
+	}
+}
+
+func (a *Aggregate) flush() []optic.Event {
+	now := a.now()
+	if now.Sub(a.start) < a.Period {
+		return nil
+	}
+	a.start = now
+
+	var out []optic.Event
+	for _, s := range a.series {
+		fields := make(map[string]interface{})
+		for k, st := range s.fields {
+			st.fields(k, a.Percentiles, fields)
+		}
+		if len(fields) == 0 {
+			// no numeric fields
+			continue
+		}
+		m, err := metric.New(s.name, s.tags, fields, now, optic.GaugeMetric)
+		if err != nil {
+			log.Printf("ERROR [%s] failed to create metric: %s", name, err)
+			continue
+		}
+		out = append(out, m)
+	}
+	a.series = make(map[uint64]*series)
+	return out
+}
+
+func (st *stats) add(value float64, limit int) {
+	st.count++
+	st.sum += value
+	st.sumSq += value * value
+	st.min = math.Min(st.min, value)
+	st.max = math.Max(st.max, value)
+
+	// reservoir sampling keeps a uniform sample once the limit is reached
+	if len(st.values) < limit {
+		st.values = append(st.values, value)
+	} else if i := rand.Int63n(st.count); i < int64(limit) {
+		st.values[i] = value
+	}
+}
+
+// fields adds the statistics of the field to fields, named like "value_min".
+func (st *stats) fields(field string, percentiles []float64, fields map[string]interface{}) {
+	mean := st.sum / float64(st.count)
+	fields[field+"_count"] = st.count
+	fields[field+"_sum"] = st.sum
+	fields[field+"_min"] = st.min
+	fields[field+"_max"] = st.max
+	fields[field+"_mean"] = mean
+	fields[field+"_stddev"] = math.Sqrt(math.Max(st.sumSq/float64(st.count)-mean*mean, 0))
+
+	if len(percentiles) == 0 {
+		return
+	}
+	values := make([]float64, len(st.values))
+	copy(values, st.values)
+	sort.Float64s(values)
+	for _, p := range percentiles {
+		fields[field+"_"+percentileField(p)] = percentile(values, p)
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(values []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	return values[rank-1]
+}
+
+// percentileField returns the field suffix for a percentile, e.g. "p90" or
+// "p99_9".
+func percentileField(p float64) string {
+	return "p" + strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "_", 1)
+}
+
+func init() {
+	processors.Add(name, NewAggregate)
+}
@@ -0,0 +1,131 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/logline"
+	"github.com/zbiljic/optic/optic/metric"
+)
+
+// Check the interfaces are satisfied
+func TestAggregate_impl(t *testing.T) {
+	var _ optic.Processor = new(Aggregate)
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newAggregate(t *testing.T, c *clock) *Aggregate {
+	a := NewAggregate().(*Aggregate)
+	a.Period = time.Minute
+	a.now = c.Now
+	require.NoError(t, a.Init())
+	return a
+}
+
+func newMetric(t *testing.T, tags map[string]string, fields map[string]interface{}) optic.Event {
+	m, err := metric.New("requests", tags, fields, time.Now())
+	require.NoError(t, err)
+	return m
+}
+
+func TestApply(t *testing.T) {
+	c := &clock{now: time.Unix(1500000000, 0)}
+	a := newAggregate(t, c)
+	a.Percentiles = []float64{50, 99.9}
+
+	for _, v := range []int64{1, 2, 3, 4} {
+		out := a.Apply(newMetric(t,
+			map[string]string{"host": "a"},
+			map[string]interface{}{"latency": v, "status": "ok"}))
+		assert.Len(t, out, 1)
+	}
+	a.Apply(newMetric(t, map[string]string{"host": "b"}, map[string]interface{}{"latency": 10.0}))
+
+	// the period hasn't passed yet
+	c.now = c.now.Add(30 * time.Second)
+	assert.Empty(t, a.Apply())
+
+	c.now = c.now.Add(30 * time.Second)
+	out := a.Apply()
+	require.Len(t, out, 2)
+
+	byHost := make(map[string]optic.Metric)
+	for _, event := range out {
+		m := event.(optic.Metric)
+		assert.Equal(t, "requests", m.Name())
+		assert.Equal(t, optic.GaugeMetric, m.MetricType())
+		assert.Equal(t, c.now, m.Time())
+		byHost[m.Tags()["host"]] = m
+	}
+
+	fields := byHost["a"].Fields()
+	assert.Equal(t, int64(4), fields["latency_count"])
+	assert.Equal(t, 10.0, fields["latency_sum"])
+	assert.Equal(t, 1.0, fields["latency_min"])
+	assert.Equal(t, 4.0, fields["latency_max"])
+	assert.Equal(t, 2.5, fields["latency_mean"])
+	assert.InDelta(t, 1.118, fields["latency_stddev"], 0.001)
+	assert.Equal(t, 2.0, fields["latency_p50"])
+	assert.Equal(t, 4.0, fields["latency_p99_9"])
+	assert.NotContains(t, fields, "status_count")
+
+	assert.Equal(t, int64(1), byHost["b"].Fields()["latency_count"])
+
+	// the aggregation is reset
+	c.now = c.now.Add(time.Minute)
+	assert.Empty(t, a.Apply())
+}
+
+func TestApplyDropOriginal(t *testing.T) {
+	c := &clock{now: time.Unix(1500000000, 0)}
+	a := newAggregate(t, c)
+	a.DropOriginal = true
+
+	ll, err := logline.New("/var/log/test.log", "line", nil, nil)
+	require.NoError(t, err)
+
+	out := a.Apply(newMetric(t, nil, map[string]interface{}{"value": 1.0}), ll)
+	require.Len(t, out, 1)
+	assert.Equal(t, optic.LogLineEvent, out[0].Type())
+
+	c.now = c.now.Add(time.Minute)
+	out = a.Apply()
+	require.Len(t, out, 1)
+	assert.Equal(t, 1.0, out[0].Fields()["value_sum"])
+}
+
+func TestPercentileLimit(t *testing.T) {
+	c := &clock{now: time.Unix(1500000000, 0)}
+	a := newAggregate(t, c)
+	a.Percentiles = []float64{50}
+	a.PercentileLimit = 10
+
+	for i := 0; i < 100; i++ {
+		a.Apply(newMetric(t, nil, map[string]interface{}{"value": float64(i)}))
+	}
+	for _, s := range a.series {
+		assert.Len(t, s.fields["value"].values, 10)
+		assert.Equal(t, int64(100), s.fields["value"].count)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	a := NewAggregate().(*Aggregate)
+	a.Period = -time.Second
+	assert.Error(t, a.Init())
+
+	a = NewAggregate().(*Aggregate)
+	a.Percentiles = []float64{0}
+	assert.Error(t, a.Init())
+}
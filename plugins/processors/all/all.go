@@ -1,6 +1,10 @@
 package all
 
 import (
+	_ "github.com/zbiljic/optic/plugins/processors/aggregate"
+	_ "github.com/zbiljic/optic/plugins/processors/derivative"
+	_ "github.com/zbiljic/optic/plugins/processors/filter"
+	_ "github.com/zbiljic/optic/plugins/processors/grok"
 	_ "github.com/zbiljic/optic/plugins/processors/noop"
 	_ "github.com/zbiljic/optic/plugins/processors/printer"
 )
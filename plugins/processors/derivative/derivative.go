@@ -0,0 +1,199 @@
+package derivative
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+	"github.com/zbiljic/optic/plugins/processors"
+)
+
+const (
+	name        = "derivative"
+	description = `Calculate rates and deltas of counter metrics.`
+)
+
+// Counters wrap around at the maximum of their width.
+const (
+	maxUint32 = float64(math.MaxUint32) + 1
+	maxUint64 = float64(math.MaxUint64) + 1
+)
+
+// Derivative keeps the previous value of every counter field per series, and
+// emits per-second rates and deltas of the counters.
+type Derivative struct {
+	// MaxGap resets the state of a series when more time than this passed
+	// since its previous value. Zero never resets it.
+	MaxGap time.Duration `mapstructure:"max_gap"`
+
+	// DropOriginal drops the counter metrics, only the rates and deltas are
+	// passed on.
+	DropOriginal bool `mapstructure:"drop_original"`
+
+	// CounterWidth is the width in bits, 32 or 64, at which the counters wrap
+	// around. Zero takes every decrease as a reset.
+	CounterWidth int `mapstructure:"counter_width"`
+
+	wrap   float64
+	mu     sync.Mutex
+	series map[uint64]*series
+	now    func() time.Time
+}
+
+// series is the previous value of the counter fields of a metric name and
+// tag set.
+type series struct {
+	ts     time.Time
+	fields map[string]float64
+}
+
+func NewDerivative() optic.Processor {
+	return &Derivative{
+		now: time.Now,
+	}
+}
+
+func (*Derivative) Kind() string {
+	return name
+}
+
+func (*Derivative) Description() string {
+	return description
+}
+
+func (d *Derivative) Init() error {
+	if d.MaxGap < 0 {
+		return fmt.Errorf("%s: invalid max_gap: %s", name, d.MaxGap)
+	}
+	switch d.CounterWidth {
+	case 0:
+		d.wrap = 0
+	case 32:
+		d.wrap = maxUint32
+	case 64:
+		d.wrap = maxUint64
+	default:
+		return fmt.Errorf("%s: invalid counter_width: %d", name, d.CounterWidth)
+	}
+	if d.now == nil {
+		d.now = time.Now
+	}
+	d.series = make(map[uint64]*series)
+	return nil
+}
+
+// Apply emits the rates and deltas of counter metrics, as gauge metrics with
+// the same name and tags. Called with no events, as it is on every flush, it
+// removes series which exceeded the max gap.
+func (d *Derivative) Apply(in ...optic.Event) []optic.Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(in) == 0 {
+		d.expire()
+		return nil
+	}
+
+	out := make([]optic.Event, 0, len(in))
+	for _, event := range in {
+		m, ok := event.(optic.Metric)
+		if !ok || m.MetricType() != optic.CounterMetric {
+			out = append(out, event)
+			continue
+		}
+
+		if derived := d.derive(m); derived != nil {
+			out = append(out, derived)
+		}
+		if !d.DropOriginal {
+			out = append(out, event)
+		}
+	}
+	return out
+}
+
+// derive updates the state of the series of the metric, and returns the
+// metric with its rates and deltas, if there was a previous value.
+func (d *Derivative) derive(m optic.Metric) optic.Metric {
+	values := make(map[string]float64)
+	for k, v := range m.Fields() {
+		if value, ok := metric.ToFloat(v); ok {
+			values[k] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	id := m.HashID()
+	prev, ok := d.series[id]
+	if !ok || (d.MaxGap > 0 && m.Time().Sub(prev.ts) > d.MaxGap) {
+		d.series[id] = &series{ts: m.Time(), fields: values}
+		return nil
+	}
+
+	elapsed := m.Time().Sub(prev.ts).Seconds()
+	if elapsed <= 0 {
+		// duplicate or out of order
+		return nil
+	}
+
+	fields := make(map[string]interface{})
+	for k, cur := range values {
+		last, ok := prev.fields[k]
+		if !ok {
+			continue
+		}
+		delta := counterDelta(last, cur, d.wrap)
+		fields[k+"_delta"] = delta
+		fields[k+"_rate"] = delta / elapsed
+	}
+	d.series[id] = &series{ts: m.Time(), fields: values}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	derived, err := metric.New(m.Name(), m.Tags(), fields, m.Time(), optic.GaugeMetric)
+	if err != nil {
+		log.Printf("ERROR [%s] failed to create metric: %s", name, err)
+		return nil
+	}
+	return derived
+}
+
+// expire removes the series without a value within the max gap.
+func (d *Derivative) expire() {
+	if d.MaxGap == 0 {
+		return
+	}
+	now := d.now()
+	for id, s := range d.series {
+		if now.Sub(s.ts) > d.MaxGap {
+			delete(d.series, id)
+		}
+	}
+}
+
+// counterDelta returns the increase of a counter from last to cur, which
+// wraps around at wrap, if set. A decrease is taken as a wraparound when the
+// wrapped delta is less than half of the range, and as a reset otherwise.
+func counterDelta(last, cur, wrap float64) float64 {
+	if cur >= last {
+		return cur - last
+	}
+	if wrap > 0 && last < wrap {
+		if delta := wrap - last + cur; delta < wrap/2 {
+			return delta
+		}
+	}
+	// reset, counted from zero
+	return cur
+}
+
+func init() {
+	processors.Add(name, NewDerivative)
+}
@@ -0,0 +1,125 @@
+package derivative
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+)
+
+// Check the interfaces are satisfied
+func TestDerivative_impl(t *testing.T) {
+	var _ optic.Processor = new(Derivative)
+}
+
+var start = time.Unix(1500000000, 0)
+
+func newDerivative(t *testing.T) *Derivative {
+	d := NewDerivative().(*Derivative)
+	require.NoError(t, d.Init())
+	return d
+}
+
+func newCounter(t *testing.T, offset time.Duration, fields map[string]interface{}) optic.Metric {
+	m, err := metric.New("net", map[string]string{"interface": "eth0"}, fields, start.Add(offset), optic.CounterMetric)
+	require.NoError(t, err)
+	return m
+}
+
+func TestApply(t *testing.T) {
+	d := newDerivative(t)
+
+	out := d.Apply(newCounter(t, 0, map[string]interface{}{"bytes": uint64(1000), "state": "up"}))
+	require.Len(t, out, 1)
+	assert.Equal(t, optic.CounterMetric, out[0].(optic.Metric).MetricType())
+
+	out = d.Apply(newCounter(t, 10*time.Second, map[string]interface{}{"bytes": uint64(1500)}))
+	require.Len(t, out, 2)
+	m := out[0].(optic.Metric)
+	assert.Equal(t, "net", m.Name())
+	assert.Equal(t, optic.GaugeMetric, m.MetricType())
+	assert.Equal(t, start.Add(10*time.Second), m.Time())
+	assert.Equal(t, map[string]string{"interface": "eth0"}, m.Tags())
+	assert.Equal(t, map[string]interface{}{"bytes_delta": 500.0, "bytes_rate": 50.0}, m.Fields())
+}
+
+func TestApplyOtherEvents(t *testing.T) {
+	d := newDerivative(t)
+	d.DropOriginal = true
+
+	gauge, err := metric.New("mem", nil, map[string]interface{}{"used": 1}, start, optic.GaugeMetric)
+	require.NoError(t, err)
+	out := d.Apply(gauge)
+	require.Len(t, out, 1)
+	assert.Equal(t, gauge, out[0])
+
+	// the counters are dropped, the first one has no previous value
+	assert.Empty(t, d.Apply(newCounter(t, 0, map[string]interface{}{"bytes": 1})))
+	assert.Len(t, d.Apply(newCounter(t, time.Second, map[string]interface{}{"bytes": 2})), 1)
+	// out of order
+	assert.Empty(t, d.Apply(newCounter(t, 0, map[string]interface{}{"bytes": 3})))
+}
+
+func TestCounterDelta(t *testing.T) {
+	tests := []struct {
+		last, cur, wrap, delta float64
+	}{
+		{10, 15, 0, 5},
+		// reset
+		{1000, 10, 0, 10},
+		{1000, 10, maxUint32, 10},
+		// reset from a value within the 32-bit range
+		{3e9, 10, 0, 10},
+		{3e9, 10, maxUint64, 10},
+		// 32-bit wraparound
+		{maxUint32 - 10, 5, maxUint32, 15},
+		// 64-bit wraparound
+		{maxUint64 - 4096, 4096, maxUint64, 8192},
+		// reset of a counter larger than the width
+		{maxUint32 + 1000, 10, maxUint32, 10},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.delta, counterDelta(tt.last, tt.cur, tt.wrap),
+			"%v -> %v (%v)", tt.last, tt.cur, tt.wrap)
+	}
+}
+
+func TestMaxGap(t *testing.T) {
+	d := newDerivative(t)
+	d.MaxGap = time.Minute
+	now := start
+	d.now = func() time.Time { return now }
+
+	d.Apply(newCounter(t, 0, map[string]interface{}{"bytes": 1}))
+	// the state is reset
+	assert.Len(t, d.Apply(newCounter(t, 2*time.Minute, map[string]interface{}{"bytes": 2})), 1)
+	assert.Len(t, d.Apply(newCounter(t, 3*time.Minute, map[string]interface{}{"bytes": 3})), 2)
+
+	// flushes expire the state
+	now = start.Add(3*time.Minute + 30*time.Second)
+	assert.Empty(t, d.Apply())
+	assert.Len(t, d.series, 1)
+	now = start.Add(5 * time.Minute)
+	assert.Empty(t, d.Apply())
+	assert.Empty(t, d.series)
+}
+
+func TestInitError(t *testing.T) {
+	d := NewDerivative().(*Derivative)
+	d.MaxGap = -time.Second
+	assert.Error(t, d.Init())
+}
+
+func TestInitCounterWidth(t *testing.T) {
+	d := NewDerivative().(*Derivative)
+	d.CounterWidth = 32
+	assert.NoError(t, d.Init())
+	assert.Equal(t, maxUint32, d.wrap)
+
+	d.CounterWidth = 16
+	assert.Error(t, d.Init())
+}
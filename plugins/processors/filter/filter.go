@@ -0,0 +1,191 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/zbiljic/optic/internal/filter"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/processors"
+)
+
+const (
+	name        = "filter"
+	description = `Drop events and remove tags and fields by name.`
+)
+
+// Filter selects events by metric name and tags, and removes fields and tags
+// from the selected ones. Patterns are globs, or regular expressions enclosed
+// in slashes.
+type Filter struct {
+	// NamePass passes only metrics whose name matches.
+	NamePass []string `mapstructure:"namepass"`
+	// NameDrop drops metrics whose name matches.
+	NameDrop []string `mapstructure:"namedrop"`
+
+	// TagPass passes only events with a tag matching the patterns of its key.
+	TagPass map[string][]string `mapstructure:"tagpass"`
+	// TagDrop drops events with a tag matching the patterns of its key.
+	TagDrop map[string][]string `mapstructure:"tagdrop"`
+
+	// FieldPass keeps only the fields whose key matches.
+	FieldPass []string `mapstructure:"fieldpass"`
+	// FieldDrop removes the fields whose key matches.
+	FieldDrop []string `mapstructure:"fielddrop"`
+
+	// TagInclude keeps only the tags whose key matches.
+	TagInclude []string `mapstructure:"taginclude"`
+	// TagExclude removes the tags whose key matches.
+	TagExclude []string `mapstructure:"tagexclude"`
+
+	namePass   filter.Filter
+	nameDrop   filter.Filter
+	tagPass    map[string]filter.Filter
+	tagDrop    map[string]filter.Filter
+	fieldPass  filter.Filter
+	fieldDrop  filter.Filter
+	tagInclude filter.Filter
+	tagExclude filter.Filter
+}
+
+func NewFilter() optic.Processor {
+	return &Filter{}
+}
+
+func (*Filter) Kind() string {
+	return name
+}
+
+func (*Filter) Description() string {
+	return description
+}
+
+func (f *Filter) Init() error {
+	var err error
+	compile := func(option string, patterns []string) filter.Filter {
+		if err != nil {
+			return nil
+		}
+		var compiled filter.Filter
+		compiled, err = filter.Compile(patterns...)
+		if err != nil {
+			err = fmt.Errorf("%s: %s: %s", name, option, err)
+		}
+		return compiled
+	}
+	compileTags := func(option string, tags map[string][]string) map[string]filter.Filter {
+		compiled := make(map[string]filter.Filter, len(tags))
+		for key, patterns := range tags {
+			if c := compile(option, patterns); c != nil {
+				compiled[key] = c
+			}
+		}
+		return compiled
+	}
+
+	f.namePass = compile("namepass", f.NamePass)
+	f.nameDrop = compile("namedrop", f.NameDrop)
+	f.tagPass = compileTags("tagpass", f.TagPass)
+	f.tagDrop = compileTags("tagdrop", f.TagDrop)
+	f.fieldPass = compile("fieldpass", f.FieldPass)
+	f.fieldDrop = compile("fielddrop", f.FieldDrop)
+	f.tagInclude = compile("taginclude", f.TagInclude)
+	f.tagExclude = compile("tagexclude", f.TagExclude)
+	return err
+}
+
+func (f *Filter) Apply(in ...optic.Event) []optic.Event {
+	out := in[:0]
+	for _, event := range in {
+		if !f.selects(event) {
+			continue
+		}
+
+		f.filterFields(event)
+		// metrics must have fields
+		if _, ok := event.(optic.Metric); ok && len(event.Fields()) == 0 {
+			continue
+		}
+		f.filterTags(event)
+
+		out = append(out, event)
+	}
+	return out
+}
+
+// selects reports whether the event passes the name and tag selectors. Name
+// selectors apply to metrics only.
+func (f *Filter) selects(event optic.Event) bool {
+	if m, ok := event.(optic.Metric); ok {
+		if f.namePass != nil && !f.namePass.Match(m.Name()) {
+			return false
+		}
+		if f.nameDrop != nil && f.nameDrop.Match(m.Name()) {
+			return false
+		}
+	}
+
+	tags := event.Tags()
+	if len(f.tagPass) > 0 && !matchTags(f.tagPass, tags) {
+		return false
+	}
+	if len(f.tagDrop) > 0 && matchTags(f.tagDrop, tags) {
+		return false
+	}
+	return true
+}
+
+// matchTags reports whether any of the tags matches the filter of its key.
+func matchTags(filters map[string]filter.Filter, tags map[string]string) bool {
+	for key, f := range filters {
+		if value, ok := tags[key]; ok && f.Match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) filterFields(event optic.Event) {
+	if f.fieldPass == nil && f.fieldDrop == nil {
+		return
+	}
+	var remove []string
+	for key := range event.Fields() {
+		if !keep(key, f.fieldPass, f.fieldDrop) {
+			remove = append(remove, key)
+		}
+	}
+	for _, key := range remove {
+		event.RemoveField(key)
+	}
+}
+
+func (f *Filter) filterTags(event optic.Event) {
+	if f.tagInclude == nil && f.tagExclude == nil {
+		return
+	}
+	var remove []string
+	for key := range event.Tags() {
+		if !keep(key, f.tagInclude, f.tagExclude) {
+			remove = append(remove, key)
+		}
+	}
+	for _, key := range remove {
+		event.RemoveTag(key)
+	}
+}
+
+// keep reports whether the key matches the pass filter, if any, and doesn't
+// match the drop filter.
+func keep(key string, pass, drop filter.Filter) bool {
+	if pass != nil && !pass.Match(key) {
+		return false
+	}
+	if drop != nil && drop.Match(key) {
+		return false
+	}
+	return true
+}
+
+func init() {
+	processors.Add(name, NewFilter)
+}
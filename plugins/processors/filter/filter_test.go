@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/logline"
+	"github.com/zbiljic/optic/optic/metric"
+)
+
+// Check the interfaces are satisfied
+func TestFilter_impl(t *testing.T) {
+	var _ optic.Processor = new(Filter)
+}
+
+func newMetric(t *testing.T, name string, tags map[string]string, fields map[string]interface{}) optic.Event {
+	m, err := metric.New(name, tags, fields, time.Now())
+	require.NoError(t, err)
+	return m
+}
+
+func names(events []optic.Event) []string {
+	var names []string
+	for _, event := range events {
+		names = append(names, event.(optic.Metric).Name())
+	}
+	return names
+}
+
+func testEvents(t *testing.T) []optic.Event {
+	fields := map[string]interface{}{"value": 1}
+	return []optic.Event{
+		newMetric(t, "cpu", map[string]string{"host": "a"}, fields),
+		newMetric(t, "cpu_total", map[string]string{"host": "b"}, fields),
+		newMetric(t, "mem", map[string]string{"host": "c", "env": "prod"}, fields),
+		newMetric(t, "disk", map[string]string{"host": "d", "env": "dev"}, fields),
+	}
+}
+
+func TestApplyNames(t *testing.T) {
+	f := &Filter{NamePass: []string{"cpu*", "mem"}, NameDrop: []string{"/_total$/"}}
+	require.NoError(t, f.Init())
+	assert.Equal(t, []string{"cpu", "mem"}, names(f.Apply(testEvents(t)...)))
+
+	// other events aren't selected by name
+	ll, err := logline.New("/var/log/test.log", "line", nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, f.Apply(ll), 1)
+}
+
+func TestApplyTags(t *testing.T) {
+	f := &Filter{TagPass: map[string][]string{"host": {"a", "c", "d"}, "env": {"prod"}}}
+	require.NoError(t, f.Init())
+	assert.Equal(t, []string{"cpu", "mem", "disk"}, names(f.Apply(testEvents(t)...)))
+
+	f = &Filter{TagDrop: map[string][]string{"env": {"*"}}}
+	require.NoError(t, f.Init())
+	assert.Equal(t, []string{"cpu", "cpu_total"}, names(f.Apply(testEvents(t)...)))
+}
+
+func TestApplyFields(t *testing.T) {
+	f := &Filter{FieldPass: []string{"usage_*", "time"}, FieldDrop: []string{"usage_guest*"}}
+	require.NoError(t, f.Init())
+
+	out := f.Apply(
+		newMetric(t, "cpu", nil, map[string]interface{}{
+			"usage_user":  1.0,
+			"usage_guest": 2.0,
+			"time":        3.0,
+			"other":       4.0,
+		}),
+		newMetric(t, "mem", nil, map[string]interface{}{"other": 1.0}),
+	)
+	require.Len(t, out, 1)
+	assert.Equal(t, map[string]interface{}{"usage_user": 1.0, "time": 3.0}, out[0].Fields())
+
+	// log lines without fields are kept
+	ll, err := logline.New("/var/log/test.log", "line", nil, map[string]interface{}{"other": 1})
+	require.NoError(t, err)
+	out = f.Apply(ll)
+	require.Len(t, out, 1)
+	assert.Empty(t, out[0].Fields())
+}
+
+func TestApplyTagKeys(t *testing.T) {
+	f := &Filter{TagInclude: []string{"host", "env"}, TagExclude: []string{"env"}}
+	require.NoError(t, f.Init())
+
+	out := f.Apply(newMetric(t, "cpu",
+		map[string]string{"host": "a", "env": "prod", "dc": "eu"},
+		map[string]interface{}{"value": 1}))
+	require.Len(t, out, 1)
+	assert.Equal(t, map[string]string{"host": "a"}, out[0].Tags())
+}
+
+func TestInitError(t *testing.T) {
+	f := &Filter{TagPass: map[string][]string{"host": {"/(/"}}}
+	assert.Error(t, f.Init())
+}
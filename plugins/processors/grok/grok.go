@@ -0,0 +1,339 @@
+package grok
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+	"github.com/zbiljic/optic/plugins/processors"
+)
+
+const (
+	name        = "grok"
+	description = `Extract fields from log lines with grok patterns.`
+)
+
+const (
+	defaultNoMatchTag = "grok_no_match"
+
+	// maxDepth limits how deep patterns may reference other patterns, to
+	// catch recursive definitions.
+	maxDepth = 32
+)
+
+// What to do with events that match none of the patterns.
+const (
+	noMatchTag  = "tag"
+	noMatchDrop = "drop"
+	noMatchPass = "pass"
+)
+
+// Types of captures, given as the third part of %{PATTERN:name:type}.
+const (
+	typeString = "string"
+	typeInt    = "int"
+	typeFloat  = "float"
+	typeBool   = "bool"
+	typeTag    = "tag"
+	typeDrop   = "drop"
+)
+
+// grokRef matches a pattern reference, %{PATTERN}, %{PATTERN:name} or
+// %{PATTERN:name:type}
+var grokRef = regexp.MustCompile(`%\{(\w+)(?::([^:}]+))?(?::(\w+))?\}`)
+
+type Grok struct {
+	// Patterns are tried in order, the first one that matches is used.
+	Patterns []string `mapstructure:"patterns"`
+
+	// CustomPatterns defines additional patterns, one per line, as the name
+	// followed by the expression.
+	CustomPatterns string `mapstructure:"custom_patterns"`
+
+	// MetricName converts matching events to metrics with this name. Empty
+	// adds the captures to the event itself.
+	MetricName string `mapstructure:"metric_name"`
+
+	// OnNoMatch is what happens to events that match none of the patterns:
+	// "tag" adds the NoMatchTag, "drop" drops them and "pass" keeps them as
+	// they are.
+	OnNoMatch string `mapstructure:"on_no_match"`
+
+	// NoMatchTag is the tag added to events that match none of the patterns.
+	NoMatchTag string `mapstructure:"no_match_tag"`
+
+	library  map[string]string
+	compiled []*pattern
+}
+
+// pattern is a compiled pattern with the captures of its groups.
+type pattern struct {
+	re *regexp.Regexp
+	// captures by index of the group, empty for unnamed groups
+	captures []capture
+}
+
+type capture struct {
+	name string
+	typ  string
+}
+
+func NewGrok() optic.Processor {
+	return &Grok{
+		OnNoMatch:  noMatchTag,
+		NoMatchTag: defaultNoMatchTag,
+	}
+}
+
+func (*Grok) Kind() string {
+	return name
+}
+
+func (*Grok) Description() string {
+	return description
+}
+
+func (g *Grok) Init() error {
+	if len(g.Patterns) == 0 {
+		return fmt.Errorf("%s: no patterns configured", name)
+	}
+	switch g.OnNoMatch {
+	case noMatchTag, noMatchDrop, noMatchPass:
+	case "":
+		g.OnNoMatch = noMatchTag
+	default:
+		return fmt.Errorf("%s: invalid on_no_match: %s", name, g.OnNoMatch)
+	}
+	if g.NoMatchTag == "" {
+		g.NoMatchTag = defaultNoMatchTag
+	}
+
+	g.library = make(map[string]string)
+	if err := addPatterns(g.library, defaultPatterns); err != nil {
+		return err
+	}
+	if err := addPatterns(g.library, g.CustomPatterns); err != nil {
+		return fmt.Errorf("%s: invalid custom patterns: %s", name, err)
+	}
+
+	g.compiled = nil
+	for _, p := range g.Patterns {
+		compiled, err := g.compile(p)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		g.compiled = append(g.compiled, compiled)
+	}
+	return nil
+}
+
+func (g *Grok) Apply(in ...optic.Event) []optic.Event {
+	out := in[:0]
+	for _, event := range in {
+		var content string
+		switch e := event.(type) {
+		case optic.LogLine:
+			content = e.Content()
+		case optic.Raw:
+			content = string(e.Value())
+		default:
+			out = append(out, event)
+			continue
+		}
+
+		tags, fields, ok := g.match(content)
+		if !ok {
+			switch g.OnNoMatch {
+			case noMatchDrop:
+				continue
+			case noMatchTag:
+				event.AddTag(g.NoMatchTag, "true")
+			}
+			out = append(out, event)
+			continue
+		}
+
+		if g.MetricName == "" {
+			for k, v := range tags {
+				event.AddTag(k, v)
+			}
+			for k, v := range fields {
+				event.AddField(k, v)
+			}
+			out = append(out, event)
+			continue
+		}
+
+		for k, v := range event.Tags() {
+			if _, ok := tags[k]; !ok {
+				tags[k] = v
+			}
+		}
+		m, err := metric.New(g.MetricName, tags, fields, event.Time())
+		if err != nil {
+			log.Printf("DEBUG [%s] failed to create metric: %s", name, err)
+			if g.OnNoMatch == noMatchDrop {
+				continue
+			}
+			if g.OnNoMatch == noMatchTag {
+				event.AddTag(g.NoMatchTag, "true")
+			}
+			out = append(out, event)
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// match matches the content against the patterns in order, and returns the
+// captures of the first one that matches.
+func (g *Grok) match(content string) (map[string]string, map[string]interface{}, bool) {
+	for _, p := range g.compiled {
+		values := p.re.FindStringSubmatch(content)
+		if values == nil {
+			continue
+		}
+
+		tags := make(map[string]string)
+		fields := make(map[string]interface{})
+		for i, c := range p.captures {
+			// optional groups that didn't participate are empty
+			if c.name == "" || values[i] == "" {
+				continue
+			}
+			v := values[i]
+			switch c.typ {
+			case typeDrop:
+			case typeTag:
+				tags[c.name] = v
+			case typeInt:
+				if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+					fields[c.name] = n
+				} else {
+					log.Printf("DEBUG [%s] %s: %q is not an int", name, c.name, v)
+				}
+			case typeFloat:
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					fields[c.name] = f
+				} else {
+					log.Printf("DEBUG [%s] %s: %q is not a float", name, c.name, v)
+				}
+			case typeBool:
+				if b, err := strconv.ParseBool(v); err == nil {
+					fields[c.name] = b
+				} else {
+					log.Printf("DEBUG [%s] %s: %q is not a bool", name, c.name, v)
+				}
+			default:
+				fields[c.name] = v
+			}
+		}
+		return tags, fields, true
+	}
+	return nil, nil, false
+}
+
+// compile expands all pattern references and compiles the expression.
+func (g *Grok) compile(p string) (*pattern, error) {
+	var captures []capture
+	expanded, err := g.expand(p, &captures, 0)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %s", p, err)
+	}
+
+	// named groups written directly in the patterns are captured as strings
+	compiled := &pattern{
+		re:       re,
+		captures: make([]capture, len(re.SubexpNames())),
+	}
+	for i, groupName := range re.SubexpNames() {
+		if groupName == "" {
+			continue
+		}
+		if strings.HasPrefix(groupName, "grok") {
+			n, err := strconv.Atoi(groupName[len("grok"):])
+			if err == nil && n < len(captures) {
+				compiled.captures[i] = captures[n]
+				continue
+			}
+		}
+		compiled.captures[i] = capture{name: groupName, typ: typeString}
+	}
+	return compiled, nil
+}
+
+// expand replaces pattern references with their expressions. Named references
+// become groups named "grok<n>", where n is the index in captures.
+func (g *Grok) expand(p string, captures *[]capture, depth int) (string, error) {
+	if depth > maxDepth {
+		return "", fmt.Errorf("patterns nested too deep, probably recursive")
+	}
+
+	var err error
+	expanded := grokRef.ReplaceAllStringFunc(p, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		parts := grokRef.FindStringSubmatch(ref)
+		patternName, captureName, typ := parts[1], parts[2], parts[3]
+
+		def, ok := g.library[patternName]
+		if !ok {
+			err = fmt.Errorf("undefined pattern: %s", patternName)
+			return ""
+		}
+		switch typ {
+		case "":
+			typ = typeString
+		case typeString, typeInt, typeFloat, typeBool, typeTag, typeDrop:
+		default:
+			err = fmt.Errorf("invalid type %q of %s", typ, captureName)
+			return ""
+		}
+
+		var inner string
+		inner, err = g.expand(def, captures, depth+1)
+		if err != nil {
+			return ""
+		}
+		if captureName == "" {
+			return "(?:" + inner + ")"
+		}
+		group := fmt.Sprintf("(?P<grok%d>%s)", len(*captures), inner)
+		*captures = append(*captures, capture{name: captureName, typ: typ})
+		return group
+	})
+	return expanded, err
+}
+
+// addPatterns adds the patterns defined one per line, as the name followed by
+// the expression. Empty lines and lines starting with '#' are skipped.
+func addPatterns(library map[string]string, defs string) error {
+	scanner := bufio.NewScanner(strings.NewReader(defs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return fmt.Errorf("missing expression of pattern %s", line)
+		}
+		library[line[:i]] = strings.TrimSpace(line[i+1:])
+	}
+	return scanner.Err()
+}
+
+func init() {
+	processors.Add(name, NewGrok)
+}
@@ -0,0 +1,165 @@
+package grok
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/logline"
+	"github.com/zbiljic/optic/optic/raw"
+)
+
+// Check the interfaces are satisfied
+func TestGrok_impl(t *testing.T) {
+	var _ optic.Processor = new(Grok)
+}
+
+func newGrok(t *testing.T, patterns ...string) *Grok {
+	g := NewGrok().(*Grok)
+	g.Patterns = patterns
+	require.NoError(t, g.Init())
+	return g
+}
+
+func newLogLine(t *testing.T, content string) optic.LogLine {
+	ll, err := logline.New("/var/log/test.log", content, map[string]string{"host": "a"}, nil)
+	require.NoError(t, err)
+	return ll
+}
+
+const accessLog = `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`
+
+func TestApplyApacheLog(t *testing.T) {
+	g := newGrok(t, "%{COMBINEDAPACHELOG}")
+
+	out := g.Apply(newLogLine(t, accessLog))
+	require.Len(t, out, 1)
+	assert.Equal(t, map[string]interface{}{
+		"clientip":    "127.0.0.1",
+		"ident":       "-",
+		"auth":        "frank",
+		"timestamp":   "10/Oct/2000:13:55:36 -0700",
+		"verb":        "GET",
+		"request":     "/apache_pb.gif",
+		"httpversion": "1.0",
+		"response":    int64(200),
+		"bytes":       int64(2326),
+		"referrer":    `"http://www.example.com/start.html"`,
+		"agent":       `"Mozilla/4.08"`,
+	}, out[0].Fields())
+	assert.Equal(t, map[string]string{"host": "a"}, out[0].Tags())
+}
+
+func TestApplyRaw(t *testing.T) {
+	g := newGrok(t, `%{WORD:level:tag} took %{NUMBER:took:float}s ok=%{WORD:ok:bool}`)
+
+	r, err := raw.New("test", []byte("INFO took 1.5s ok=true"), nil, nil)
+	require.NoError(t, err)
+
+	out := g.Apply(r)
+	require.Len(t, out, 1)
+	assert.Equal(t, map[string]string{"level": "INFO"}, out[0].Tags())
+	assert.Equal(t, map[string]interface{}{"took": 1.5, "ok": true}, out[0].Fields())
+}
+
+func TestApplyOrder(t *testing.T) {
+	g := newGrok(t,
+		`^%{INT:code:int} %{GREEDYDATA:message}`,
+		`^%{WORD:word} %{GREEDYDATA:message}`,
+	)
+
+	out := g.Apply(newLogLine(t, "404 not found"), newLogLine(t, "error not found"))
+	require.Len(t, out, 2)
+	assert.Equal(t, map[string]interface{}{"code": int64(404), "message": "not found"}, out[0].Fields())
+	assert.Equal(t, map[string]interface{}{"word": "error", "message": "not found"}, out[1].Fields())
+}
+
+func TestApplyCustomPatterns(t *testing.T) {
+	g := NewGrok().(*Grok)
+	g.Patterns = []string{`%{REQUEST}`}
+	g.CustomPatterns = `
+# a comment
+DURATION %{NUMBER:duration:float}ms
+REQUEST %{WORD:method} in %{DURATION}
+`
+	require.NoError(t, g.Init())
+
+	out := g.Apply(newLogLine(t, "GET in 12.5ms"))
+	require.Len(t, out, 1)
+	assert.Equal(t, map[string]interface{}{"method": "GET", "duration": 12.5}, out[0].Fields())
+}
+
+func TestApplyMetric(t *testing.T) {
+	g := NewGrok().(*Grok)
+	g.Patterns = []string{`%{WORD:path:tag} %{INT:status:int} %{INT:skip:drop}`}
+	g.MetricName = "requests"
+	require.NoError(t, g.Init())
+
+	ts := time.Unix(1500000000, 0)
+	ll, err := logline.New("/var/log/test.log", "index 200 1", map[string]string{"host": "a"}, nil, ts)
+	require.NoError(t, err)
+
+	out := g.Apply(ll)
+	require.Len(t, out, 1)
+	m, ok := out[0].(optic.Metric)
+	require.True(t, ok)
+	assert.Equal(t, "requests", m.Name())
+	assert.Equal(t, ts, m.Time())
+	assert.Equal(t, map[string]string{"host": "a", "path": "index"}, m.Tags())
+	assert.Equal(t, map[string]interface{}{"status": int64(200)}, m.Fields())
+}
+
+func TestApplyNoMatch(t *testing.T) {
+	g := newGrok(t, `^%{INT:code:int}$`)
+	out := g.Apply(newLogLine(t, "not a number"))
+	require.Len(t, out, 1)
+	assert.Equal(t, map[string]string{"host": "a", "grok_no_match": "true"}, out[0].Tags())
+
+	g.OnNoMatch = "drop"
+	out = g.Apply(newLogLine(t, "not a number"), newLogLine(t, "42"))
+	require.Len(t, out, 1)
+	assert.Equal(t, int64(42), out[0].Fields()["code"])
+
+	g.OnNoMatch = "pass"
+	out = g.Apply(newLogLine(t, "not a number"))
+	require.Len(t, out, 1)
+	assert.Equal(t, map[string]string{"host": "a"}, out[0].Tags())
+
+	// no fields for the metric
+	g = NewGrok().(*Grok)
+	g.Patterns = []string{`%{WORD:word:tag}`}
+	g.MetricName = "words"
+	require.NoError(t, g.Init())
+	out = g.Apply(newLogLine(t, "word"))
+	require.Len(t, out, 1)
+	assert.Equal(t, optic.LogLineEvent, out[0].Type())
+	assert.True(t, out[0].HasTag("grok_no_match"))
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		custom   string
+		onNo     string
+	}{
+		{patterns: nil},
+		{patterns: []string{`%{MISSING}`}},
+		{patterns: []string{`%{INT:n:complex}`}},
+		{patterns: []string{`%{LOOP}`}, custom: "LOOP %{LOOP}"},
+		{patterns: []string{`%{INT}`}, custom: "NOEXPRESSION"},
+		{patterns: []string{`(`}},
+		{patterns: []string{`%{INT}`}, onNo: "ignore"},
+	}
+	for _, tt := range tests {
+		g := NewGrok().(*Grok)
+		g.Patterns = tt.patterns
+		g.CustomPatterns = tt.custom
+		if tt.onNo != "" {
+			g.OnNoMatch = tt.onNo
+		}
+		assert.Error(t, g.Init(), "%v", tt)
+	}
+}
@@ -3,4 +3,6 @@ package all
 import (
 	_ "github.com/zbiljic/optic/plugins/sinks/discard"
 	_ "github.com/zbiljic/optic/plugins/sinks/file"
+	_ "github.com/zbiljic/optic/plugins/sinks/http"
+	_ "github.com/zbiljic/optic/plugins/sinks/prometheus_client"
 )
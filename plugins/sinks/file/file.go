@@ -86,17 +86,29 @@ func (f *File) Close() error {
 	return nil
 }
 
+// Write writes the events. Events which can not be encoded are skipped and
+// reported through an `optic.PartialWriteError`.
 func (f *File) Write(events []optic.Event) error {
-	for _, event := range events {
+	var perr *optic.PartialWriteError
+	for i, event := range events {
 		b, err := f.encoder.Encode(event)
 		if err != nil {
-			return fmt.Errorf("failed to encode event: %s", err)
+			if perr == nil {
+				perr = &optic.PartialWriteError{
+					Err: fmt.Errorf("failed to encode event: %s", err),
+				}
+			}
+			perr.Rejected = append(perr.Rejected, i)
+			continue
 		}
 		_, err = f.writer.Write(b)
 		if err != nil {
 			return fmt.Errorf("failed to write event: %s, %s", event.String(), err)
 		}
 	}
+	if perr != nil {
+		return perr
+	}
 	return nil
 }
 
@@ -1,12 +1,36 @@
 package file
 
 import (
+	"bytes"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
 	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/codecs/influx"
 )
 
 // Check the interfaces are satisfied
 func TestFile_impl(t *testing.T) {
 	var _ optic.Sink = new(File)
 }
+
+func TestWriteRejectsUnencodableEvents(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFile().(*File)
+	f.SetEncoder(influx.NewInfluxCodec())
+	f.writer = &buf
+
+	err := f.Write([]optic.Event{
+		testutil.TestLogLine("not a metric"),
+		testutil.TestMetric(int64(1)),
+	})
+	require.Error(t, err)
+
+	perr, ok := err.(*optic.PartialWriteError)
+	require.True(t, ok)
+	assert.Equal(t, []int{0}, perr.Rejected)
+	assert.Equal(t, "test1,tag1=value1 value=1i 1257894000000000000\n", buf.String())
+}
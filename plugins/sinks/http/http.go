@@ -0,0 +1,235 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/codecs/line"
+	"github.com/zbiljic/optic/plugins/sinks"
+)
+
+const (
+	name        = "http"
+	description = `Send events to an HTTP endpoint.`
+)
+
+const (
+	defaultMethod  = http.MethodPost
+	defaultTimeout = 5 * time.Second
+
+	// used when the encoder doesn't know its content type
+	defaultContentType = "application/octet-stream"
+
+	// modeBatch sends all events of a write in a single request.
+	modeBatch = "batch"
+	// modeEvent sends one request for every event.
+	modeEvent = "event"
+
+	// maximum number of bytes of the response body included in errors
+	maxErrorBody = 512
+)
+
+type HTTP struct {
+	URL     string            `mapstructure:"url"`
+	Method  string            `mapstructure:"method"`
+	Headers map[string]string `mapstructure:"headers"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+
+	// ContentEncoding is either empty for no compression or "gzip".
+	ContentEncoding string `mapstructure:"content_encoding"`
+
+	// Mode is either "batch" or "event".
+	Mode string `mapstructure:"mode"`
+
+	encoder optic.Encoder
+
+	client *http.Client
+}
+
+func NewHTTP() optic.Sink {
+	return &HTTP{
+		Method:  defaultMethod,
+		Timeout: defaultTimeout,
+		Mode:    modeBatch,
+		encoder: line.NewLineCodec(),
+	}
+}
+
+func (*HTTP) Kind() string {
+	return name
+}
+
+func (*HTTP) Description() string {
+	return description
+}
+
+func (h *HTTP) Connect() error {
+	if h.URL == "" {
+		return fmt.Errorf("url must be set")
+	}
+	if _, err := url.Parse(h.URL); err != nil {
+		return fmt.Errorf("invalid url: %s", err)
+	}
+
+	switch h.Method = strings.ToUpper(h.Method); h.Method {
+	case "":
+		h.Method = defaultMethod
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("unsupported method: %s", h.Method)
+	}
+
+	switch h.ContentEncoding {
+	case "", "identity", "gzip":
+	default:
+		return fmt.Errorf("unsupported content encoding: %s", h.ContentEncoding)
+	}
+
+	switch h.Mode {
+	case "":
+		h.Mode = modeBatch
+	case modeBatch, modeEvent:
+	default:
+		return fmt.Errorf("unsupported mode: %s", h.Mode)
+	}
+
+	if h.Timeout <= 0 {
+		h.Timeout = defaultTimeout
+	}
+
+	h.client = &http.Client{
+		Timeout: h.Timeout,
+	}
+	return nil
+}
+
+func (h *HTTP) Close() error {
+	if h.client != nil {
+		h.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// Write sends the events, either all in a single request or one request per
+// event. Any failure, including a non-2xx response, is returned as an error,
+// so the events stay buffered and are sent again on the next write. When
+// sending one request per event, the events sent before the failure are
+// reported through an `optic.IncompleteWriteError`, so they aren't sent again.
+// Events which can not be encoded are skipped and reported through an
+// `optic.PartialWriteError`.
+func (h *HTTP) Write(events []optic.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var (
+		body []byte
+		perr *optic.PartialWriteError
+	)
+	for i, event := range events {
+		b, err := h.encoder.Encode(event)
+		if err != nil {
+			if perr == nil {
+				perr = &optic.PartialWriteError{
+					Err: fmt.Errorf("failed to encode event: %s", err),
+				}
+			}
+			perr.Rejected = append(perr.Rejected, i)
+			continue
+		}
+
+		if h.Mode == modeEvent {
+			if err := h.send(b); err != nil {
+				if i == 0 {
+					return err
+				}
+				ierr := &optic.IncompleteWriteError{Written: i, Err: err}
+				if perr != nil {
+					ierr.Rejected = perr.Rejected
+				}
+				return ierr
+			}
+			continue
+		}
+		body = append(body, b...)
+	}
+
+	if len(body) > 0 {
+		if err := h.send(body); err != nil {
+			return err
+		}
+	}
+	if perr != nil {
+		return perr
+	}
+	return nil
+}
+
+func (h *HTTP) send(body []byte) error {
+	var reader io.Reader = bytes.NewReader(body)
+	if h.ContentEncoding == "gzip" {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		reader = &buf
+	}
+
+	req, err := http.NewRequest(h.Method, h.URL, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", h.contentType())
+	if h.ContentEncoding == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range h.Headers {
+		if strings.EqualFold(k, "host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send events to %s: %s", h.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("request to %s failed with status %s: %s",
+			h.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	// drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+func (h *HTTP) contentType() string {
+	if ct, ok := h.encoder.(optic.ContentTypeEncoder); ok {
+		return ct.ContentType()
+	}
+	return defaultContentType
+}
+
+func (h *HTTP) SetEncoder(encoder optic.Encoder) {
+	h.encoder = encoder
+}
+
+func init() {
+	sinks.Add(name, NewHTTP)
+}
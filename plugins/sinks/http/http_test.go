@@ -0,0 +1,253 @@
+package http
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/codecs/influx"
+	"github.com/zbiljic/optic/plugins/codecs/json"
+)
+
+// Check the interfaces are satisfied
+func TestHTTP_impl(t *testing.T) {
+	var _ optic.Sink = new(HTTP)
+	var _ optic.EncoderOutput = new(HTTP)
+}
+
+type request struct {
+	method  string
+	headers http.Header
+	body    string
+}
+
+type recorder struct {
+	sync.Mutex
+	status int
+	// fail the requests after the first ones, if set
+	failAfter int
+	requests  []request
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	b, _ := ioutil.ReadAll(body)
+
+	rec.Lock()
+	defer rec.Unlock()
+	rec.requests = append(rec.requests, request{
+		method:  r.Method,
+		headers: r.Header,
+		body:    string(b),
+	})
+	if rec.status != 0 || (rec.failAfter > 0 && len(rec.requests) > rec.failAfter) {
+		status := rec.status
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("collector unavailable"))
+	}
+}
+
+func newTestHTTP(t *testing.T, url string) *HTTP {
+	h := NewHTTP().(*HTTP)
+	h.URL = url
+	return h
+}
+
+func TestWriteBatch(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	h := newTestHTTP(t, ts.URL)
+	h.Headers = map[string]string{"X-Api-Key": "secret"}
+	require.NoError(t, h.Connect())
+	defer h.Close()
+
+	err := h.Write([]optic.Event{
+		testutil.TestLogLine("a"),
+		testutil.TestLogLine("b"),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, rec.requests, 1)
+	assert.Equal(t, http.MethodPost, rec.requests[0].method)
+	assert.Equal(t, "secret", rec.requests[0].headers.Get("X-Api-Key"))
+	assert.Equal(t, "a\nb\n", rec.requests[0].body)
+}
+
+func TestWritePerEvent(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	h := newTestHTTP(t, ts.URL)
+	h.Mode = modeEvent
+	h.Method = "put"
+	require.NoError(t, h.Connect())
+	defer h.Close()
+
+	err := h.Write([]optic.Event{
+		testutil.TestLogLine("a"),
+		testutil.TestLogLine("b"),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, rec.requests, 2)
+	assert.Equal(t, http.MethodPut, rec.requests[0].method)
+	assert.Equal(t, "a\n", rec.requests[0].body)
+	assert.Equal(t, "b\n", rec.requests[1].body)
+}
+
+func TestWritePerEventIncomplete(t *testing.T) {
+	rec := &recorder{failAfter: 1}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	h := newTestHTTP(t, ts.URL)
+	h.Mode = modeEvent
+	require.NoError(t, h.Connect())
+	defer h.Close()
+
+	err := h.Write([]optic.Event{
+		testutil.TestLogLine("a"),
+		testutil.TestLogLine("b"),
+		testutil.TestLogLine("c"),
+	})
+	require.Error(t, err)
+	// the first event was sent, and isn't sent again
+	ierr, ok := err.(*optic.IncompleteWriteError)
+	require.True(t, ok)
+	assert.Equal(t, 1, ierr.Written)
+	assert.Contains(t, ierr.Err.Error(), "503")
+	assert.Len(t, rec.requests, 2)
+}
+
+func TestWriteContentType(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	h := newTestHTTP(t, ts.URL)
+	require.NoError(t, h.Connect())
+	defer h.Close()
+
+	require.NoError(t, h.Write([]optic.Event{testutil.TestLogLine("a")}))
+	h.SetEncoder(json.NewJSONCodec())
+	require.NoError(t, h.Write([]optic.Event{testutil.TestLogLine("b")}))
+
+	require.Len(t, rec.requests, 2)
+	assert.Equal(t, "text/plain; charset=utf-8", rec.requests[0].headers.Get("Content-Type"))
+	assert.Equal(t, "application/x-ndjson", rec.requests[1].headers.Get("Content-Type"))
+}
+
+func TestWriteGzipWithEncoder(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	h := newTestHTTP(t, ts.URL)
+	h.ContentEncoding = "gzip"
+	h.Headers = map[string]string{"Content-Type": "application/json"}
+	h.SetEncoder(json.NewJSONCodec())
+	require.NoError(t, h.Connect())
+	defer h.Close()
+
+	require.NoError(t, h.Write([]optic.Event{testutil.TestMetric(int64(1))}))
+
+	require.Len(t, rec.requests, 1)
+	assert.Equal(t, "gzip", rec.requests[0].headers.Get("Content-Encoding"))
+	assert.Equal(t, "application/json", rec.requests[0].headers.Get("Content-Type"))
+	assert.Equal(t,
+		`{"fields":{"value":1},"metric_type":"untyped","name":"test1","tags":{"tag1":"value1"},"timestamp":"2009-11-10T23:00:00Z"}`+"\n",
+		rec.requests[0].body)
+}
+
+func TestWriteRejectsUnencodableEvents(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	h := newTestHTTP(t, ts.URL)
+	h.SetEncoder(influx.NewInfluxCodec())
+	require.NoError(t, h.Connect())
+	defer h.Close()
+
+	err := h.Write([]optic.Event{
+		testutil.TestMetric(int64(1)),
+		testutil.TestLogLine("not a metric"),
+	})
+	require.Error(t, err)
+	perr, ok := err.(*optic.PartialWriteError)
+	require.True(t, ok)
+	assert.Equal(t, []int{1}, perr.Rejected)
+
+	require.Len(t, rec.requests, 1)
+	assert.Equal(t, "test1,tag1=value1 value=1i 1257894000000000000\n", rec.requests[0].body)
+}
+
+func TestWriteErrorStatus(t *testing.T) {
+	rec := &recorder{status: http.StatusServiceUnavailable}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	h := newTestHTTP(t, ts.URL)
+	require.NoError(t, h.Connect())
+	defer h.Close()
+
+	err := h.Write([]optic.Event{testutil.TestLogLine("a")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "503")
+	assert.Contains(t, err.Error(), "collector unavailable")
+}
+
+func TestWriteTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	h := newTestHTTP(t, ts.URL)
+	h.Timeout = 50 * time.Millisecond
+	require.NoError(t, h.Connect())
+	defer h.Close()
+
+	assert.Error(t, h.Write([]optic.Event{testutil.TestLogLine("a")}))
+}
+
+func TestConnectValidation(t *testing.T) {
+	h := NewHTTP().(*HTTP)
+	assert.Error(t, h.Connect())
+
+	for _, modify := range []func(h *HTTP){
+		func(h *HTTP) { h.Method = "GET" },
+		func(h *HTTP) { h.ContentEncoding = "br" },
+		func(h *HTTP) { h.Mode = "stream" },
+		func(h *HTTP) { h.URL = "://bad" },
+	} {
+		h := newTestHTTP(t, "http://localhost")
+		modify(h)
+		assert.Error(t, h.Connect())
+	}
+}
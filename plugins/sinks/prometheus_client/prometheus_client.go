@@ -0,0 +1,430 @@
+package prometheus_client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zbiljic/optic/internal/prometheus"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+	"github.com/zbiljic/optic/plugins/sinks"
+)
+
+const (
+	name        = "prometheus_client"
+	description = `Expose metrics on an HTTP endpoint for Prometheus to scrape.`
+)
+
+const (
+	defaultListen = ":9273"
+	defaultPath   = "/metrics"
+	defaultTTL    = 60 * time.Second
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	invalidNameChars  = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+	labelValueEscaper = strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+	)
+)
+
+type PrometheusClient struct {
+	// Listen is the address of the HTTP listener.
+	Listen string `mapstructure:"listen"`
+
+	// Path on which the metrics are exposed.
+	Path string `mapstructure:"path"`
+
+	// TTL is the time after which a series which was not written again is
+	// removed. Zero keeps series forever.
+	TTL time.Duration `mapstructure:"ttl"`
+
+	server   *http.Server
+	listener net.Listener
+
+	mu     sync.Mutex
+	series map[string]*series
+	now    func() time.Time
+}
+
+// series is a single exposed series. Histograms and summaries keep all of
+// their buckets or quantiles in one series.
+type series struct {
+	family   string
+	typ      optic.MetricType
+	labels   map[string]string
+	value    float64
+	sum      float64
+	count    float64
+	buckets  map[float64]float64
+	lastSeen time.Time
+}
+
+func NewPrometheusClient() optic.Sink {
+	return &PrometheusClient{
+		Listen: defaultListen,
+		Path:   defaultPath,
+		TTL:    defaultTTL,
+		series: make(map[string]*series),
+		now:    time.Now,
+	}
+}
+
+func (*PrometheusClient) Kind() string {
+	return name
+}
+
+func (*PrometheusClient) Description() string {
+	return description
+}
+
+func (p *PrometheusClient) Start() error {
+	if p.Path == "" {
+		p.Path = defaultPath
+	}
+
+	listener, err := net.Listen("tcp", p.Listen)
+	if err != nil {
+		return err
+	}
+	p.listener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(p.Path, p.serveHTTP)
+	p.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := p.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("ERROR [%s] listener failed: %s", name, err)
+		}
+	}()
+
+	log.Printf("INFO [%s] exposing metrics on %s%s", name, listener.Addr(), p.Path)
+	return nil
+}
+
+func (p *PrometheusClient) Stop() {
+	if p.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.server.Shutdown(ctx); err != nil {
+		log.Printf("ERROR [%s] failed to stop listener: %s", name, err)
+	}
+}
+
+func (*PrometheusClient) Connect() error {
+	return nil
+}
+
+func (*PrometheusClient) Close() error {
+	return nil
+}
+
+// Write stores the metrics until they are scraped. Other event types and
+// non-numeric fields are ignored.
+func (p *PrometheusClient) Write(events []optic.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for _, event := range events {
+		m, ok := event.(optic.Metric)
+		if !ok {
+			continue
+		}
+
+		labels := make(map[string]string, len(m.Tags()))
+		for k, v := range m.Tags() {
+			labels[sanitizeLabel(k)] = v
+		}
+		metricName := sanitizeName(m.Name())
+
+		switch m.MetricType() {
+		case optic.HistogramMetric, optic.SummaryMetric:
+			p.addDistribution(metricName, m.MetricType(), labels, m.Fields(), now)
+		default:
+			for field, v := range m.Fields() {
+				value, ok := sampleValue(v)
+				if !ok {
+					continue
+				}
+				s := p.get(familyName(metricName, field), m.MetricType(), labels)
+				if s == nil {
+					continue
+				}
+				s.value = value
+				s.lastSeen = now
+			}
+		}
+	}
+
+	p.expire(now)
+	return nil
+}
+
+func (p *PrometheusClient) addDistribution(
+	metricName string,
+	typ optic.MetricType,
+	labels map[string]string,
+	fields map[string]interface{},
+	now time.Time,
+) {
+	s := p.get(metricName, typ, labels)
+	if s == nil {
+		return
+	}
+	s.buckets = make(map[float64]float64)
+	for field, v := range fields {
+		value, ok := sampleValue(v)
+		if !ok {
+			continue
+		}
+		switch field {
+		case prometheus.SumField:
+			s.sum = value
+			continue
+		case prometheus.CountField:
+			s.count = value
+			continue
+		}
+		parse := prometheus.ParseBucketField
+		if typ == optic.SummaryMetric {
+			parse = prometheus.ParseQuantileField
+		}
+		if bound, ok := parse(field); ok {
+			s.buckets[bound] = value
+		}
+	}
+	s.lastSeen = now
+}
+
+// get returns the series, creating it if needed. Returns nil if the family
+// already exists with a different type.
+func (p *PrometheusClient) get(family string, typ optic.MetricType, labels map[string]string) *series {
+	key := seriesKey(family, labels)
+	if s, ok := p.series[key]; ok {
+		if s.typ != typ {
+			log.Printf("DEBUG [%s] dropping %s, type %s does not match type %s",
+				name, family, typ, s.typ)
+			return nil
+		}
+		return s
+	}
+	for _, s := range p.series {
+		if s.family == family && s.typ != typ {
+			log.Printf("DEBUG [%s] dropping %s, type %s does not match type %s",
+				name, family, typ, s.typ)
+			return nil
+		}
+	}
+	s := &series{
+		family: family,
+		typ:    typ,
+		labels: labels,
+	}
+	p.series[key] = s
+	return s
+}
+
+func (p *PrometheusClient) expire(now time.Time) {
+	if p.TTL <= 0 {
+		return
+	}
+	for key, s := range p.series {
+		if now.Sub(s.lastSeen) > p.TTL {
+			delete(p.series, key)
+		}
+	}
+}
+
+func (p *PrometheusClient) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	w.Write(p.expose())
+}
+
+// expose returns all series in the Prometheus text format.
+func (p *PrometheusClient) expose() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire(p.now())
+
+	families := make(map[string][]*series)
+	for _, s := range p.series {
+		families[s.family] = append(families[s.family], s)
+	}
+	names := make([]string, 0, len(families))
+	for family := range families {
+		names = append(names, family)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, family := range names {
+		all := families[family]
+		sort.Slice(all, func(i, j int) bool {
+			return seriesKey("", all[i].labels) < seriesKey("", all[j].labels)
+		})
+
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", family, typeName(all[0].typ))
+		for _, s := range all {
+			switch s.typ {
+			case optic.HistogramMetric:
+				writeDistribution(&buf, s, "le", family+"_bucket")
+			case optic.SummaryMetric:
+				writeDistribution(&buf, s, "quantile", family)
+			default:
+				writeSample(&buf, family, s.labels, "", 0, s.value)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+func writeDistribution(buf *bytes.Buffer, s *series, boundLabel, sampleName string) {
+	bounds := make([]float64, 0, len(s.buckets))
+	for bound := range s.buckets {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+	for _, bound := range bounds {
+		writeSample(buf, sampleName, s.labels, boundLabel, bound, s.buckets[bound])
+	}
+	// histograms must always have a +Inf bucket
+	if s.typ == optic.HistogramMetric && (len(bounds) == 0 || !math.IsInf(bounds[len(bounds)-1], 1)) {
+		writeSample(buf, sampleName, s.labels, boundLabel, math.Inf(1), s.count)
+	}
+	writeSample(buf, s.family+"_sum", s.labels, "", 0, s.sum)
+	writeSample(buf, s.family+"_count", s.labels, "", 0, s.count)
+}
+
+// writeSample writes a single sample line. If extraLabel is set, it is added
+// with the bound as its value.
+func writeSample(buf *bytes.Buffer, sampleName string, labels map[string]string, extraLabel string, bound, value float64) {
+	buf.WriteString(sampleName)
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if len(keys) > 0 || extraLabel != "" {
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, `%s="%s"`, k, labelValueEscaper.Replace(labels[k]))
+		}
+		if extraLabel != "" {
+			if len(keys) > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, `%s="%s"`, extraLabel, formatFloat(bound))
+		}
+		buf.WriteByte('}')
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+	buf.WriteByte('\n')
+}
+
+// familyName returns the name of the family for a field. Fields produced by
+// the prometheus source map back to the plain metric name.
+func familyName(metricName, field string) string {
+	switch field {
+	case prometheus.CounterField, prometheus.GaugeField, prometheus.UntypedField:
+		return metricName
+	}
+	return metricName + "_" + sanitizeName(field)
+}
+
+func typeName(typ optic.MetricType) string {
+	switch typ {
+	case optic.CounterMetric, optic.GaugeMetric, optic.HistogramMetric, optic.SummaryMetric:
+		return typ.String()
+	}
+	return "untyped"
+}
+
+func seriesKey(family string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(family)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
+
+func sanitizeName(s string) string {
+	s = invalidNameChars.ReplaceAllString(s, "_")
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
+
+func sanitizeLabel(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "_")
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sampleValue returns a field value as a sample value, booleans are 1 or 0.
+func sampleValue(v interface{}) (float64, bool) {
+	if b, ok := v.(bool); ok {
+		if b {
+			return 1, true
+		}
+		return 0, true
+	}
+	return metric.ToFloat(v)
+}
+
+func init() {
+	sinks.Add(name, NewPrometheusClient)
+}
@@ -0,0 +1,142 @@
+package prometheus_client
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/prometheus"
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/metric"
+)
+
+// Check the interfaces are satisfied
+func TestPrometheusClient_impl(t *testing.T) {
+	var _ optic.ServiceSink = new(PrometheusClient)
+}
+
+func newMetric(t *testing.T, name string, tags map[string]string, fields map[string]interface{}, typ optic.MetricType) optic.Metric {
+	m, err := metric.New(name, tags, fields, time.Now(), typ)
+	require.NoError(t, err)
+	return m
+}
+
+func TestExpose(t *testing.T) {
+	p := NewPrometheusClient().(*PrometheusClient)
+
+	err := p.Write([]optic.Event{
+		newMetric(t, "cpu", map[string]string{"host": "a", "cpu-id": `"0"`},
+			map[string]interface{}{"usage_idle": float64(99.5), "ok": true, "state": "up"},
+			optic.GaugeMetric),
+		newMetric(t, "requests_total", nil,
+			map[string]interface{}{"counter": int64(7)},
+			optic.CounterMetric),
+		newMetric(t, "latency", map[string]string{"path": "/"},
+			map[string]interface{}{
+				prometheus.BucketField(0.5): float64(1),
+				prometheus.BucketField(0.1): float64(0),
+				"sum":                       float64(1.2),
+				"count":                     float64(2),
+			},
+			optic.HistogramMetric),
+		newMetric(t, "rpc", nil,
+			map[string]interface{}{
+				prometheus.QuantileField(0.99): float64(3),
+				prometheus.QuantileField(0.5):  float64(1),
+				"sum":                          float64(10),
+				"count":                        float64(4),
+			},
+			optic.SummaryMetric),
+		testutil.TestLogLine("ignored"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `# TYPE cpu_ok gauge
+cpu_ok{cpu_id="\"0\"",host="a"} 1
+# TYPE cpu_usage_idle gauge
+cpu_usage_idle{cpu_id="\"0\"",host="a"} 99.5
+# TYPE latency histogram
+latency_bucket{path="/",le="0.1"} 0
+latency_bucket{path="/",le="0.5"} 1
+latency_bucket{path="/",le="+Inf"} 2
+latency_sum{path="/"} 1.2
+latency_count{path="/"} 2
+# TYPE requests_total counter
+requests_total 7
+# TYPE rpc summary
+rpc{quantile="0.5"} 1
+rpc{quantile="0.99"} 3
+rpc_sum 10
+rpc_count 4
+`, string(p.expose()))
+}
+
+func TestTypeConflict(t *testing.T) {
+	p := NewPrometheusClient().(*PrometheusClient)
+
+	require.NoError(t, p.Write([]optic.Event{
+		newMetric(t, "x", map[string]string{"a": "1"}, map[string]interface{}{"value": 1}, optic.CounterMetric),
+		newMetric(t, "x", map[string]string{"a": "2"}, map[string]interface{}{"value": 2}, optic.GaugeMetric),
+	}))
+	assert.Equal(t, "# TYPE x counter\nx{a=\"1\"} 1\n", string(p.expose()))
+}
+
+func TestExpire(t *testing.T) {
+	p := NewPrometheusClient().(*PrometheusClient)
+	p.TTL = time.Minute
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	require.NoError(t, p.Write([]optic.Event{
+		newMetric(t, "old", nil, map[string]interface{}{"value": 1}, optic.UntypedMetric),
+	}))
+	now = now.Add(30 * time.Second)
+	require.NoError(t, p.Write([]optic.Event{
+		newMetric(t, "new", nil, map[string]interface{}{"value": 2}, optic.UntypedMetric),
+	}))
+	assert.Contains(t, string(p.expose()), "old 1")
+
+	now = now.Add(45 * time.Second)
+	assert.Equal(t, "# TYPE new untyped\nnew 2\n", string(p.expose()))
+}
+
+func TestServe(t *testing.T) {
+	p := NewPrometheusClient().(*PrometheusClient)
+	p.Listen = "127.0.0.1:0"
+	require.NoError(t, p.Start())
+	defer p.Stop()
+
+	hist := newMetric(t, "latency", map[string]string{"path": "/"},
+		map[string]interface{}{
+			prometheus.BucketField(0.5):         float64(1),
+			prometheus.BucketField(math.Inf(1)): float64(3),
+			"sum":                               float64(1.2),
+			"count":                             float64(3),
+		},
+		optic.HistogramMetric)
+	require.NoError(t, p.Write([]optic.Event{hist}))
+
+	resp, err := http.Get("http://" + p.listener.Addr().String() + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, contentType, resp.Header.Get("Content-Type"))
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	// exposed metrics can be scraped back by the prometheus source
+	metrics, err := prometheus.Parse(body, false, time.Now())
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "latency", metrics[0].Name())
+	assert.Equal(t, optic.HistogramMetric, metrics[0].MetricType())
+	assert.Equal(t, hist.Tags(), metrics[0].Tags())
+	assert.Equal(t, hist.Fields(), metrics[0].Fields())
+}
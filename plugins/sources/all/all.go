@@ -1,5 +1,19 @@
 package all
 
 import (
+	_ "github.com/zbiljic/optic/plugins/sources/cpu"
+	_ "github.com/zbiljic/optic/plugins/sources/disk"
+	_ "github.com/zbiljic/optic/plugins/sources/diskio"
+	_ "github.com/zbiljic/optic/plugins/sources/exec"
+	_ "github.com/zbiljic/optic/plugins/sources/http_listener"
 	_ "github.com/zbiljic/optic/plugins/sources/internal"
+	_ "github.com/zbiljic/optic/plugins/sources/load"
+	_ "github.com/zbiljic/optic/plugins/sources/mem"
+	_ "github.com/zbiljic/optic/plugins/sources/net"
+	_ "github.com/zbiljic/optic/plugins/sources/processes"
+	_ "github.com/zbiljic/optic/plugins/sources/prometheus"
+	_ "github.com/zbiljic/optic/plugins/sources/socket_listener"
+	_ "github.com/zbiljic/optic/plugins/sources/statsd"
+	_ "github.com/zbiljic/optic/plugins/sources/syslog"
+	_ "github.com/zbiljic/optic/plugins/sources/tail"
 )
@@ -0,0 +1,128 @@
+package cpu
+
+import (
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/pkg/procfs"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "cpu"
+	description = `Collect CPU usage from /proc/stat.`
+)
+
+const totalCPU = "cpu-total"
+
+type CPU struct {
+	// PerCPU collects the usage of every CPU.
+	PerCPU bool `mapstructure:"percpu"`
+	// TotalCPU collects the usage summed over all CPUs.
+	TotalCPU bool `mapstructure:"totalcpu"`
+	// CollectCPUTime collects the time spent in each mode as counters.
+	CollectCPUTime bool `mapstructure:"collect_cpu_time"`
+
+	// ProcRoot is the mount point of the proc filesystem.
+	ProcRoot string `mapstructure:"proc_root"`
+
+	// statistics of the previous gather, by CPU
+	last map[string]procfs.CPUStat
+}
+
+func NewCPU() optic.Source {
+	return &CPU{
+		PerCPU:   true,
+		TotalCPU: true,
+		ProcRoot: procfs.DefaultProcRoot,
+	}
+}
+
+func (*CPU) Kind() string {
+	return name
+}
+
+func (*CPU) Description() string {
+	return description
+}
+
+// Gather emits the usage since the previous gather, in percent. The first
+// gather only records the current statistics.
+func (c *CPU) Gather(acc optic.Accumulator) error {
+	stat, err := procfs.NewFS(c.ProcRoot, "").Stat()
+	if err != nil {
+		return err
+	}
+
+	var cpus []procfs.CPUStat
+	if c.TotalCPU {
+		total := stat.CPUTotal
+		total.CPU = totalCPU
+		cpus = append(cpus, total)
+	}
+	if c.PerCPU {
+		cpus = append(cpus, stat.CPUs...)
+	}
+
+	last := c.last
+	c.last = make(map[string]procfs.CPUStat, len(cpus))
+	for _, cur := range cpus {
+		c.last[cur.CPU] = cur
+		tags := map[string]string{"cpu": cur.CPU}
+
+		if c.CollectCPUTime {
+			acc.AddMetricType(name, tags, timeFields(cur), optic.CounterMetric)
+		}
+
+		prev, ok := last[cur.CPU]
+		if !ok {
+			continue
+		}
+		total := cur.Total() - prev.Total()
+		if total <= 0 {
+			// no time passed, or the counters were reset
+			continue
+		}
+		acc.AddMetricType(name, tags, usageFields(prev, cur, total), optic.GaugeMetric)
+	}
+	return nil
+}
+
+func timeFields(s procfs.CPUStat) map[string]interface{} {
+	return map[string]interface{}{
+		"time_user":       s.User,
+		"time_nice":       s.Nice,
+		"time_system":     s.System,
+		"time_idle":       s.Idle,
+		"time_iowait":     s.IOWait,
+		"time_irq":        s.IRQ,
+		"time_softirq":    s.SoftIRQ,
+		"time_steal":      s.Steal,
+		"time_guest":      s.Guest,
+		"time_guest_nice": s.GuestNice,
+	}
+}
+
+func usageFields(prev, cur procfs.CPUStat, total float64) map[string]interface{} {
+	percent := func(prev, cur float64) float64 {
+		delta := cur - prev
+		if delta < 0 {
+			return 0
+		}
+		return 100 * delta / total
+	}
+	return map[string]interface{}{
+		"usage_user":       percent(prev.User, cur.User),
+		"usage_nice":       percent(prev.Nice, cur.Nice),
+		"usage_system":     percent(prev.System, cur.System),
+		"usage_idle":       percent(prev.Idle, cur.Idle),
+		"usage_iowait":     percent(prev.IOWait, cur.IOWait),
+		"usage_irq":        percent(prev.IRQ, cur.IRQ),
+		"usage_softirq":    percent(prev.SoftIRQ, cur.SoftIRQ),
+		"usage_steal":      percent(prev.Steal, cur.Steal),
+		"usage_guest":      percent(prev.Guest, cur.Guest),
+		"usage_guest_nice": percent(prev.GuestNice, cur.GuestNice),
+	}
+}
+
+func init() {
+	sources.Add(name, NewCPU)
+}
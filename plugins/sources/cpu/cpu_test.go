@@ -0,0 +1,88 @@
+package cpu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestCPU_impl(t *testing.T) {
+	var _ optic.Source = new(CPU)
+}
+
+func TestGather(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cpu")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeStat := func(stat string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644))
+	}
+
+	c := NewCPU().(*CPU)
+	c.ProcRoot = dir
+	acc := &testutil.Accumulator{}
+
+	writeStat("cpu  100 0 100 800 0 0 0 0 0 0\n" +
+		"cpu0 50 0 50 400 0 0 0 0 0 0\n" +
+		"cpu1 50 0 50 400 0 0 0 0 0 0\n")
+	require.NoError(t, c.Gather(acc))
+	// usage needs two gathers
+	assert.Empty(t, acc.Events)
+
+	writeStat("cpu  200 0 150 850 0 0 0 0 0 0\n" +
+		"cpu0 140 0 60 400 0 0 0 0 0 0\n" +
+		"cpu1 60 0 90 450 0 0 0 0 0 0\n")
+	require.NoError(t, c.Gather(acc))
+	require.Len(t, acc.Events, 3)
+
+	for _, e := range acc.Events {
+		assert.Equal(t, optic.GaugeMetric, e.MetricType)
+	}
+	assertUsage(t, acc, "cpu-total", 50, 25, 25)
+	assertUsage(t, acc, "cpu0", 90, 10, 0)
+	assertUsage(t, acc, "cpu1", 10, 40, 50)
+}
+
+func TestGatherCPUTime(t *testing.T) {
+	c := NewCPU().(*CPU)
+	c.ProcRoot = "../../../pkg/procfs/testdata/proc"
+	c.PerCPU = false
+	c.CollectCPUTime = true
+	acc := &testutil.Accumulator{}
+
+	require.NoError(t, c.Gather(acc))
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, optic.CounterMetric, acc.Events[0].MetricType)
+	assert.Equal(t, "cpu-total", acc.Events[0].Tags["cpu"])
+	assert.Equal(t, 101.32, acc.Events[0].Fields["time_user"])
+}
+
+func TestGatherMissing(t *testing.T) {
+	c := NewCPU().(*CPU)
+	c.ProcRoot = "testdata/missing"
+	assert.Error(t, c.Gather(&testutil.Accumulator{}))
+}
+
+func assertUsage(t *testing.T, acc *testutil.Accumulator, cpu string, user, system, idle float64) {
+	for _, e := range acc.Events {
+		if e.Tags["cpu"] != cpu {
+			continue
+		}
+		assert.InDelta(t, user, e.Fields["usage_user"], 1e-9, cpu)
+		assert.InDelta(t, system, e.Fields["usage_system"], 1e-9, cpu)
+		assert.InDelta(t, idle, e.Fields["usage_idle"], 1e-9, cpu)
+		assert.Equal(t, 0.0, e.Fields["usage_iowait"], cpu)
+		assert.Len(t, e.Fields, 10, cpu)
+		return
+	}
+	t.Errorf("no usage of %s", cpu)
+}
@@ -0,0 +1,140 @@
+package disk
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/pkg/procfs"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "disk"
+	description = `Collect disk usage of mounted filesystems.`
+)
+
+var defaultIgnoreFS = []string{
+	"tmpfs", "devtmpfs", "devfs", "iso9660", "overlay", "aufs", "squashfs",
+}
+
+type Disk struct {
+	// MountPoints limits the filesystems to the ones mounted at these paths.
+	// Empty collects all filesystems.
+	MountPoints []string `mapstructure:"mount_points"`
+	// IgnoreFS is a list of filesystem types which are skipped.
+	IgnoreFS []string `mapstructure:"ignore_fs"`
+
+	// ProcRoot is the mount point of the proc filesystem.
+	ProcRoot string `mapstructure:"proc_root"`
+	// HostMountPrefix is prepended to mount points, for reading the usage of
+	// host filesystems mounted in a container.
+	HostMountPrefix string `mapstructure:"host_mount_prefix"`
+}
+
+// usage is the usage of a filesystem in bytes and inodes.
+type usage struct {
+	total       uint64
+	free        uint64
+	used        uint64
+	inodesTotal uint64
+	inodesFree  uint64
+}
+
+func NewDisk() optic.Source {
+	return &Disk{
+		IgnoreFS: defaultIgnoreFS,
+		ProcRoot: procfs.DefaultProcRoot,
+	}
+}
+
+func (*Disk) Kind() string {
+	return name
+}
+
+func (*Disk) Description() string {
+	return description
+}
+
+func (d *Disk) Gather(acc optic.Accumulator) error {
+	mounts, err := procfs.NewFS(d.ProcRoot, "").Mounts()
+	if err != nil {
+		return err
+	}
+
+	for _, mount := range d.filter(mounts) {
+		u, err := statUsage(filepath.Join(d.HostMountPrefix, mount.MountPoint))
+		if err != nil {
+			acc.AddError(fmt.Errorf("%s: %s", mount.MountPoint, err))
+			continue
+		}
+		// pseudo filesystems have no blocks
+		if u.total == 0 {
+			continue
+		}
+
+		mode := "rw"
+		if mount.ReadOnly() {
+			mode = "ro"
+		}
+		tags := map[string]string{
+			"path":   mount.MountPoint,
+			"device": filepath.Base(mount.Device),
+			"fstype": mount.FSType,
+			"mode":   mode,
+		}
+
+		// the used percentage is relative to the space available to
+		// unprivileged users, as reported by df
+		var usedPercent float64
+		if u.used+u.free > 0 {
+			usedPercent = 100 * float64(u.used) / float64(u.used+u.free)
+		}
+		fields := map[string]interface{}{
+			"total":        u.total,
+			"free":         u.free,
+			"used":         u.used,
+			"used_percent": usedPercent,
+			"inodes_total": u.inodesTotal,
+			"inodes_free":  u.inodesFree,
+			"inodes_used":  u.inodesTotal - u.inodesFree,
+		}
+		acc.AddMetricType(name, tags, fields, optic.GaugeMetric)
+	}
+	return nil
+}
+
+// filter returns the mounts to collect. When a mount point is mounted over,
+// only the last mount is kept.
+func (d *Disk) filter(mounts []procfs.Mount) []procfs.Mount {
+	ignoreFS := make(map[string]bool, len(d.IgnoreFS))
+	for _, fs := range d.IgnoreFS {
+		ignoreFS[fs] = true
+	}
+	mountPoints := make(map[string]bool, len(d.MountPoints))
+	for _, mp := range d.MountPoints {
+		mountPoints[mp] = true
+	}
+
+	var filtered []procfs.Mount
+	index := make(map[string]int)
+	for _, mount := range mounts {
+		if ignoreFS[mount.FSType] {
+			continue
+		}
+		if len(mountPoints) > 0 && !mountPoints[mount.MountPoint] {
+			continue
+		}
+		if i, ok := index[mount.MountPoint]; ok {
+			filtered[i] = mount
+			continue
+		}
+		index[mount.MountPoint] = len(filtered)
+		filtered = append(filtered, mount)
+	}
+	return filtered
+}
+
+func init() {
+	sources.Add(name, NewDisk)
+}
@@ -0,0 +1,86 @@
+package disk
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/pkg/procfs"
+)
+
+// Check the interfaces are satisfied
+func TestDisk_impl(t *testing.T) {
+	var _ optic.Source = new(Disk)
+}
+
+func TestFilter(t *testing.T) {
+	mounts := []procfs.Mount{
+		{Device: "/dev/sda1", MountPoint: "/", FSType: "ext4"},
+		{Device: "tmpfs", MountPoint: "/run", FSType: "tmpfs"},
+		{Device: "/dev/sdb1", MountPoint: "/data", FSType: "xfs"},
+		{Device: "/dev/sdc1", MountPoint: "/data", FSType: "ext4"},
+	}
+
+	d := NewDisk().(*Disk)
+	assert.Equal(t, []procfs.Mount{mounts[0], mounts[3]}, d.filter(mounts))
+
+	d.MountPoints = []string{"/", "/run"}
+	d.IgnoreFS = nil
+	assert.Equal(t, []procfs.Mount{mounts[0], mounts[1]}, d.filter(mounts))
+}
+
+func TestGather(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("disk usage is not supported on windows")
+	}
+
+	// the fixture mounts are looked up in an empty directory
+	dir, err := ioutil.TempDir("", "disk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(dir+"/mnt/with space", 0755))
+
+	d := NewDisk().(*Disk)
+	d.ProcRoot = "../../../pkg/procfs/testdata/proc"
+	d.HostMountPrefix = dir
+	// the fixture proc filesystem would be on the same filesystem here
+	d.IgnoreFS = append(d.IgnoreFS, "proc")
+	acc := &testutil.Accumulator{}
+
+	require.NoError(t, d.Gather(acc))
+	assert.Empty(t, acc.Errors)
+	require.Len(t, acc.Events, 2)
+
+	e := acc.Events[0]
+	assert.Equal(t, optic.GaugeMetric, e.MetricType)
+	assert.Equal(t, map[string]string{
+		"path":   "/",
+		"device": "sda1",
+		"fstype": "ext4",
+		"mode":   "rw",
+	}, e.Tags)
+	for _, field := range []string{"total", "free", "used", "used_percent", "inodes_total", "inodes_free", "inodes_used"} {
+		assert.Contains(t, e.Fields, field)
+	}
+	assert.True(t, e.Fields["total"].(uint64) > 0)
+
+	assert.Equal(t, "/mnt/with space", acc.Events[1].Tags["path"])
+	assert.Equal(t, "ro", acc.Events[1].Tags["mode"])
+}
+
+func TestGatherMissingMount(t *testing.T) {
+	d := NewDisk().(*Disk)
+	d.ProcRoot = "../../../pkg/procfs/testdata/proc"
+	d.HostMountPrefix = "testdata/missing"
+	acc := &testutil.Accumulator{}
+
+	require.NoError(t, d.Gather(acc))
+	assert.Len(t, acc.Errors, 3)
+	assert.Empty(t, acc.Events)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package disk
+
+import "syscall"
+
+// statUsage returns the usage of the filesystem the path is on.
+func statUsage(path string) (usage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return usage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return usage{
+		total:       uint64(st.Blocks) * bsize,
+		free:        uint64(st.Bavail) * bsize,
+		used:        (uint64(st.Blocks) - uint64(st.Bfree)) * bsize,
+		inodesTotal: uint64(st.Files),
+		inodesFree:  uint64(st.Ffree),
+	}, nil
+}
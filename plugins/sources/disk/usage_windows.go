@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package disk
+
+import "fmt"
+
+// statUsage is not supported on Windows, which has no mounts in /proc.
+func statUsage(path string) (usage, error) {
+	return usage{}, fmt.Errorf("disk usage is not supported on windows")
+}
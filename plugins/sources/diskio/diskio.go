@@ -0,0 +1,92 @@
+package diskio
+
+import (
+	"path/filepath"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/pkg/procfs"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "diskio"
+	description = `Collect disk I/O statistics from /proc/diskstats.`
+)
+
+type DiskIO struct {
+	// Devices is a list of glob patterns of devices to collect. Empty
+	// collects all devices.
+	Devices []string `mapstructure:"devices"`
+	// SkipPartitions skips devices which are not listed in /sys/block.
+	SkipPartitions bool `mapstructure:"skip_partitions"`
+
+	// ProcRoot is the mount point of the proc filesystem.
+	ProcRoot string `mapstructure:"proc_root"`
+	// SysRoot is the mount point of the sys filesystem.
+	SysRoot string `mapstructure:"sys_root"`
+}
+
+func NewDiskIO() optic.Source {
+	return &DiskIO{
+		ProcRoot: procfs.DefaultProcRoot,
+		SysRoot:  procfs.DefaultSysRoot,
+	}
+}
+
+func (*DiskIO) Kind() string {
+	return name
+}
+
+func (*DiskIO) Description() string {
+	return description
+}
+
+func (d *DiskIO) Gather(acc optic.Accumulator) error {
+	fs := procfs.NewFS(d.ProcRoot, d.SysRoot)
+	stats, err := fs.DiskStats()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		if !d.match(s.Name) {
+			continue
+		}
+		if d.SkipPartitions && !fs.IsBlockDevice(s.Name) {
+			continue
+		}
+
+		fields := map[string]interface{}{
+			"reads":            s.Reads,
+			"writes":           s.Writes,
+			"merged_reads":     s.ReadsMerged,
+			"merged_writes":    s.WritesMerged,
+			"read_bytes":       s.ReadBytes,
+			"write_bytes":      s.WriteBytes,
+			"read_time":        s.ReadTime,
+			"write_time":       s.WriteTime,
+			"io_time":          s.IOTime,
+			"weighted_io_time": s.WeightedIOTime,
+			"iops_in_progress": s.IOInProgress,
+		}
+		tags := map[string]string{"name": s.Name}
+		acc.AddMetricType(name, tags, fields, optic.CounterMetric)
+	}
+	return nil
+}
+
+func (d *DiskIO) match(device string) bool {
+	if len(d.Devices) == 0 {
+		return true
+	}
+	for _, pattern := range d.Devices {
+		if ok, _ := filepath.Match(pattern, device); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	sources.Add(name, NewDiskIO)
+}
@@ -0,0 +1,61 @@
+package diskio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestDiskIO_impl(t *testing.T) {
+	var _ optic.Source = new(DiskIO)
+}
+
+func newDiskIO() *DiskIO {
+	d := NewDiskIO().(*DiskIO)
+	d.ProcRoot = "../../../pkg/procfs/testdata/proc"
+	d.SysRoot = "../../../pkg/procfs/testdata/sys"
+	return d
+}
+
+func TestGather(t *testing.T) {
+	d := newDiskIO()
+	acc := &testutil.Accumulator{}
+
+	require.NoError(t, d.Gather(acc))
+	require.Len(t, acc.Events, 3)
+	assert.Equal(t, optic.CounterMetric, acc.Events[0].MetricType)
+
+	acc.AssertContainsMetricWithTaggedFields(t, "diskio", map[string]string{"name": "sda"},
+		map[string]interface{}{
+			"reads":            uint64(12000),
+			"writes":           uint64(24000),
+			"merged_reads":     uint64(3000),
+			"merged_writes":    uint64(9000),
+			"read_bytes":       uint64(800000 * 512),
+			"write_bytes":      uint64(1600000 * 512),
+			"read_time":        uint64(6000),
+			"write_time":       uint64(30000),
+			"io_time":          uint64(18000),
+			"weighted_io_time": uint64(36000),
+			"iops_in_progress": uint64(2),
+		})
+}
+
+func TestGatherFilter(t *testing.T) {
+	d := newDiskIO()
+	d.Devices = []string{"sd*"}
+	acc := &testutil.Accumulator{}
+	require.NoError(t, d.Gather(acc))
+	assert.Len(t, acc.Events, 2)
+
+	d.SkipPartitions = true
+	acc = &testutil.Accumulator{}
+	require.NoError(t, d.Gather(acc))
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, "sda", acc.Events[0].Tags["name"])
+}
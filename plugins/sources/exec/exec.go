@@ -0,0 +1,203 @@
+package exec
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/codecs/line"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "exec"
+	description = `Run commands on every gather and decode their output with the configured codec.`
+)
+
+const (
+	// DefaultTimeout is the default time after which a command is killed.
+	DefaultTimeout = 5 * time.Second
+
+	// maxStderr is the maximum length of stderr output included in errors.
+	maxStderr = 512
+)
+
+type Exec struct {
+	// Commands to run. Arguments are split on whitespace, except within
+	// single or double quotes.
+	Commands []string `mapstructure:"commands"`
+
+	// Timeout after which a command is killed, with all processes it started.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	decoder optic.Decoder
+}
+
+func NewExec() optic.Source {
+	return &Exec{
+		Timeout: DefaultTimeout,
+		decoder: line.NewLineCodec(),
+	}
+}
+
+func (*Exec) Kind() string {
+	return name
+}
+
+func (*Exec) Description() string {
+	return description
+}
+
+func (e *Exec) SetDecoder(decoder optic.Decoder) {
+	e.decoder = decoder
+}
+
+// Gather runs all commands concurrently. It returns once every command has
+// exited or was killed, so a gather never takes much longer than Timeout.
+func (e *Exec) Gather(acc optic.Accumulator) error {
+	var wg sync.WaitGroup
+	for _, command := range e.Commands {
+		wg.Add(1)
+		go func(command string) {
+			defer wg.Done()
+			e.gather(acc, command)
+		}(command)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (e *Exec) gather(acc optic.Accumulator, command string) {
+	stdout, stderr, err := e.run(command)
+	if err != nil {
+		acc.AddError(fmt.Errorf("%s: %s%s", command, err, formatStderr(stderr)))
+	} else if len(stderr) > 0 {
+		acc.AddError(fmt.Errorf("%s: output on stderr%s", command, formatStderr(stderr)))
+	}
+
+	// the output of failed commands is still decoded, as checks commonly
+	// report problems with a non-zero exit code
+	stdout = bytes.TrimRight(stdout, "\r\n")
+	if len(stdout) == 0 {
+		return
+	}
+	events, err := e.decoder.Decode(stdout)
+	if err != nil {
+		acc.AddError(fmt.Errorf("%s: failed to decode output: %s", command, err))
+		return
+	}
+	for _, event := range events {
+		acc.AddEvent(event)
+	}
+}
+
+// run runs the command and returns its output. The command is killed once
+// the timeout is reached.
+func (e *Exec) run(command string) ([]byte, []byte, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(args) == 0 {
+		return nil, nil, fmt.Errorf("empty command")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		timedOut bool
+	)
+	if e.Timeout > 0 {
+		timer := time.AfterFunc(e.Timeout, func() {
+			mu.Lock()
+			timedOut = true
+			mu.Unlock()
+			kill(cmd)
+		})
+		defer timer.Stop()
+	}
+
+	err = cmd.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if timedOut {
+		err = fmt.Errorf("killed after timeout of %s", e.Timeout)
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// formatStderr formats stderr output for appending to an error message.
+func formatStderr(stderr []byte) string {
+	s := strings.TrimSpace(string(stderr))
+	if s == "" {
+		return ""
+	}
+	if len(s) > maxStderr {
+		s = s[:maxStderr] + "..."
+	}
+	return ": " + s
+}
+
+// splitCommand splits the command into arguments on whitespace. Single and
+// double quotes group arguments, and a backslash escapes the next character
+// outside of single quotes.
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, c := range command {
+		switch {
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if escaped || quote != 0 {
+		return nil, fmt.Errorf("unterminated quote or escape in command")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func init() {
+	sources.Add(name, NewExec)
+}
@@ -0,0 +1,128 @@
+package exec
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/codecs/influx"
+)
+
+// Check the interfaces are satisfied
+func TestExec_impl(t *testing.T) {
+	var _ optic.Source = new(Exec)
+	var _ optic.DecoderInput = new(Exec)
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+	}{
+		{"check", []string{"check"}},
+		{"  check  -a   b ", []string{"check", "-a", "b"}},
+		{`check "a b" 'c "d"'`, []string{"check", "a b", `c "d"`}},
+		{`check a\ b "\"" '\'`, []string{"check", "a b", `"`, `\`}},
+		{`check ""`, []string{"check", ""}},
+	}
+	for _, tt := range tests {
+		args, err := splitCommand(tt.command)
+		require.NoError(t, err, tt.command)
+		assert.Equal(t, tt.args, args, tt.command)
+	}
+
+	for _, command := range []string{`check "a`, `check 'a`, `check a\`} {
+		_, err := splitCommand(command)
+		assert.Error(t, err, command)
+	}
+}
+
+func newExec(commands ...string) *Exec {
+	e := NewExec().(*Exec)
+	e.Commands = commands
+	return e
+}
+
+func skipWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands depend on sh")
+	}
+}
+
+func TestGather(t *testing.T) {
+	skipWindows(t)
+
+	e := newExec(`sh -c "echo first; echo second"`)
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Gather(acc))
+
+	assert.Empty(t, acc.Errors)
+	require.Len(t, acc.Events, 2)
+	assert.Equal(t, "first", string(acc.Events[0].Value))
+	assert.Equal(t, "second", string(acc.Events[1].Value))
+}
+
+func TestGatherDecoder(t *testing.T) {
+	skipWindows(t)
+
+	e := newExec(
+		`echo cpu,check=a usage=1 1`,
+		`echo cpu,check=b usage=2 1`,
+		`echo not line protocol`,
+	)
+	e.SetDecoder(influx.NewInfluxCodec())
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Gather(acc))
+
+	require.Len(t, acc.Errors, 1)
+	assert.Contains(t, acc.Errors[0].Error(), "failed to decode output")
+	assert.True(t, acc.HasMetric("cpu"))
+	assert.Len(t, acc.Events, 2)
+}
+
+func TestGatherErrors(t *testing.T) {
+	skipWindows(t)
+
+	e := newExec(
+		`sh -c "echo critical; exit 2"`,
+		`sh -c "echo warning >&2"`,
+		`/nonexistent/command`,
+	)
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Gather(acc))
+
+	require.Len(t, acc.Errors, 3)
+	var messages []string
+	for _, err := range acc.Errors {
+		messages = append(messages, err.Error())
+	}
+	assert.Contains(t, messages, `sh -c "echo critical; exit 2": exit status 2`)
+	assert.Contains(t, messages, `sh -c "echo warning >&2": output on stderr: warning`)
+
+	// the output of the failed command is still decoded
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, "critical", string(acc.Events[0].Value))
+}
+
+func TestGatherTimeout(t *testing.T) {
+	skipWindows(t)
+
+	// the child process holds on to stdout, so it must be killed as well
+	e := newExec(`sh -c "echo partial; sleep 10 & wait"`)
+	e.Timeout = 100 * time.Millisecond
+	acc := &testutil.Accumulator{}
+
+	start := time.Now()
+	require.NoError(t, e.Gather(acc))
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	require.Len(t, acc.Errors, 1)
+	assert.Contains(t, acc.Errors[0].Error(), "killed after timeout of 100ms")
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, "partial", string(acc.Events[0].Value))
+}
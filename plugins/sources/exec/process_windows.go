@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package exec
+
+import "os/exec"
+
+// setProcessGroup does nothing, process groups are not used on Windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// kill kills the command. Processes started by the command are left running.
+func kill(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,264 @@
+package http_listener
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/codecs/line"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "http_listener"
+	description = `Receive events pushed with HTTP POST requests, decoded with the configured codec.`
+)
+
+const (
+	defaultListen       = ":8080"
+	defaultPath         = "/events"
+	defaultMaxBodySize  = 10 * 1024 * 1024
+	defaultReadTimeout  = 10 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+
+	// acceptedEventsHeader tells how many events of a rejected request were
+	// kept, so clients can retry only the rest
+	acceptedEventsHeader = "X-Accepted-Events"
+)
+
+type HTTPListener struct {
+	// Listen is the address of the HTTP listener.
+	Listen string `mapstructure:"listen"`
+
+	// Paths on which events are accepted.
+	Paths []string `mapstructure:"paths"`
+
+	// MaxBodySize is the maximum size of a request body, after decompression.
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+
+	// BasicUsername and BasicPassword require HTTP basic authentication.
+	BasicUsername string `mapstructure:"basic_username"`
+	BasicPassword string `mapstructure:"basic_password"`
+
+	// BearerToken requires an "Authorization: Bearer <token>" header.
+	BearerToken string `mapstructure:"bearer_token"`
+
+	decoder optic.Decoder
+	acc     optic.Accumulator
+
+	server   *http.Server
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+func NewHTTPListener() optic.Source {
+	return &HTTPListener{
+		Listen:       defaultListen,
+		Paths:        []string{defaultPath},
+		MaxBodySize:  defaultMaxBodySize,
+		ReadTimeout:  defaultReadTimeout,
+		WriteTimeout: defaultWriteTimeout,
+		decoder:      line.NewLineCodec(),
+	}
+}
+
+func (*HTTPListener) Kind() string {
+	return name
+}
+
+func (*HTTPListener) Description() string {
+	return description
+}
+
+func (h *HTTPListener) SetDecoder(decoder optic.Decoder) {
+	h.decoder = decoder
+}
+
+func (*HTTPListener) Gather(acc optic.Accumulator) error {
+	// events are emitted by the request handlers
+	return nil
+}
+
+func (h *HTTPListener) Start(acc optic.Accumulator) error {
+	if len(h.Paths) == 0 {
+		return fmt.Errorf("no paths configured")
+	}
+	if h.MaxBodySize <= 0 {
+		h.MaxBodySize = defaultMaxBodySize
+	}
+	h.acc = acc
+
+	listener, err := net.Listen("tcp", h.Listen)
+	if err != nil {
+		return err
+	}
+	h.listener = listener
+
+	mux := http.NewServeMux()
+	for _, path := range h.Paths {
+		mux.HandleFunc(path, h.serveHTTP)
+	}
+	h.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  h.ReadTimeout,
+		WriteTimeout: h.WriteTimeout,
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		if err := h.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			acc.AddError(fmt.Errorf("listener failed: %s", err))
+		}
+	}()
+
+	log.Printf("INFO [%s] listening on %s", name, listener.Addr())
+	return nil
+}
+
+func (h *HTTPListener) Stop() {
+	if h.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.server.Shutdown(ctx); err != nil {
+		log.Printf("ERROR [%s] failed to stop listener: %s", name, err)
+	}
+	h.wg.Wait()
+}
+
+func (h *HTTPListener) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		if h.BasicUsername != "" || h.BasicPassword != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="optic"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, status, err := h.readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	body = bytes.TrimRight(body, "\r\n")
+	if len(body) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	events, err := h.decoder.Decode(body)
+	if err != nil {
+		log.Printf("DEBUG [%s] failed to decode request from %s: %s", name, r.RemoteAddr, err)
+		http.Error(w, fmt.Sprintf("failed to decode: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// events are added without blocking if possible, so clients get an
+	// error when the agent can't keep up, instead of piling up requests. The
+	// events added before the channel filled up are kept, so the response
+	// tells how many, for clients to retry only the rest.
+	nb, nonBlocking := h.acc.(optic.NonBlockingAccumulator)
+	for i, event := range events {
+		if !nonBlocking {
+			h.acc.AddEvent(event)
+			continue
+		}
+		if !nb.TryAddEvent(event) {
+			log.Printf("WARNING [%s] event channel full, rejected %d of %d events",
+				name, len(events)-i, len(events))
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set(acceptedEventsHeader, strconv.Itoa(i))
+			http.Error(w, fmt.Sprintf("event channel full, accepted %d of %d events", i, len(events)),
+				http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readBody returns the request body, decompressed if needed. On error, it also
+// returns the status code of the response.
+func (h *HTTPListener) readBody(r *http.Request) ([]byte, int, error) {
+	var body io.Reader = http.MaxBytesReader(nil, r.Body, h.MaxBodySize)
+
+	switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid gzip body: %s", err)
+		}
+		defer gz.Close()
+		// limit the decompressed size as well
+		body = io.LimitReader(gz, h.MaxBodySize+1)
+	default:
+		return nil, http.StatusUnsupportedMediaType,
+			fmt.Errorf("unsupported content encoding: %s", r.Header.Get("Content-Encoding"))
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds %d bytes", h.MaxBodySize)
+		}
+		return nil, http.StatusBadRequest, fmt.Errorf("failed to read body: %s", err)
+	}
+	if int64(len(b)) > h.MaxBodySize {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds %d bytes", h.MaxBodySize)
+	}
+	return b, 0, nil
+}
+
+// authorized reports whether the request has valid credentials. If both basic
+// authentication and a bearer token are configured, either one is accepted.
+func (h *HTTPListener) authorized(r *http.Request) bool {
+	basic := h.BasicUsername != "" || h.BasicPassword != ""
+	if !basic && h.BearerToken == "" {
+		return true
+	}
+
+	if basic {
+		username, password, ok := r.BasicAuth()
+		if ok &&
+			subtle.ConstantTimeCompare([]byte(username), []byte(h.BasicUsername)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(h.BasicPassword)) == 1 {
+			return true
+		}
+	}
+	if h.BearerToken != "" {
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") &&
+			subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(h.BearerToken)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	sources.Add(name, NewHTTPListener)
+}
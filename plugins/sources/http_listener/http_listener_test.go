@@ -0,0 +1,184 @@
+package http_listener
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/codecs/influx"
+)
+
+// Check the interfaces are satisfied
+func TestHTTPListener_impl(t *testing.T) {
+	var _ optic.ServiceSource = new(HTTPListener)
+	var _ optic.DecoderInput = new(HTTPListener)
+}
+
+// fullAccumulator refuses the events added without blocking, once it added
+// free events.
+type fullAccumulator struct {
+	testutil.Accumulator
+	free int
+}
+
+func (a *fullAccumulator) TryAddEvent(event optic.Event) bool {
+	if a.free <= 0 {
+		return false
+	}
+	a.free--
+	a.AddEvent(event)
+	return true
+}
+
+func newListener(acc optic.Accumulator) *HTTPListener {
+	h := NewHTTPListener().(*HTTPListener)
+	h.SetDecoder(influx.NewInfluxCodec())
+	h.acc = acc
+	return h
+}
+
+func post(h *HTTPListener, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, defaultPath, strings.NewReader(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.serveHTTP(w, r)
+	return w
+}
+
+func TestServe(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	h := newListener(acc)
+
+	w := post(h, "cpu,host=a usage=1 1\ncpu,host=b usage=2 2\n", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	require.Len(t, acc.Events, 2)
+	assert.Equal(t, "cpu", acc.Events[0].Name)
+	assert.Equal(t, "b", acc.Events[1].Tags["host"])
+}
+
+func TestServeGzip(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	h := newListener(acc)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("cpu usage=1 1\n"))
+	gz.Close()
+
+	w := post(h, buf.String(), map[string]string{"Content-Encoding": "gzip"})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Len(t, acc.Events, 1)
+
+	w = post(h, "not gzip", map[string]string{"Content-Encoding": "gzip"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = post(h, "cpu usage=1 1\n", map[string]string{"Content-Encoding": "br"})
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestServeErrors(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	h := newListener(acc)
+	h.MaxBodySize = 16
+
+	w := post(h, "not line protocol", nil)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	h.MaxBodySize = defaultMaxBodySize
+	w = post(h, "not line protocol", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "failed to decode")
+
+	r := httptest.NewRequest(http.MethodGet, defaultPath, nil)
+	w = httptest.NewRecorder()
+	h.serveHTTP(w, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	assert.Empty(t, acc.Events)
+}
+
+func TestServeFull(t *testing.T) {
+	acc := &fullAccumulator{}
+	h := newListener(acc)
+
+	w := post(h, "cpu usage=1 1\n", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("X-Accepted-Events"))
+	assert.Empty(t, acc.Events)
+}
+
+func TestServePartiallyFull(t *testing.T) {
+	acc := &fullAccumulator{free: 1}
+	h := newListener(acc)
+
+	w := post(h, "cpu,host=a usage=1 1\ncpu,host=b usage=2 2\n", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	// the client is told which events not to send again
+	assert.Equal(t, "1", w.Header().Get("X-Accepted-Events"))
+	assert.Contains(t, w.Body.String(), "accepted 1 of 2 events")
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, "a", acc.Events[0].Tags["host"])
+}
+
+func TestAuth(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	h := newListener(acc)
+	h.BasicUsername = "user"
+	h.BasicPassword = "secret"
+	h.BearerToken = "token"
+
+	w := post(h, "cpu usage=1 1\n", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+	r := httptest.NewRequest(http.MethodPost, defaultPath, strings.NewReader("cpu usage=1 1\n"))
+	r.SetBasicAuth("user", "wrong")
+	w = httptest.NewRecorder()
+	h.serveHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, defaultPath, strings.NewReader("cpu usage=1 1\n"))
+	r.SetBasicAuth("user", "secret")
+	w = httptest.NewRecorder()
+	h.serveHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = post(h, "cpu usage=1 1\n", map[string]string{"Authorization": "Bearer token"})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	assert.Len(t, acc.Events, 2)
+}
+
+func TestStartStop(t *testing.T) {
+	h := NewHTTPListener().(*HTTPListener)
+	h.Listen = "127.0.0.1:0"
+	h.Paths = []string{"/a", "/b"}
+	acc := &testutil.Accumulator{}
+	require.NoError(t, h.Start(acc))
+	defer h.Stop()
+
+	url := "http://" + h.listener.Addr().String()
+	resp, err := http.Post(url+"/b", "text/plain", strings.NewReader("hello\n"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = http.Post(url+"/c", "text/plain", strings.NewReader("hello\n"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, "hello", string(acc.Events[0].Value))
+}
@@ -0,0 +1,62 @@
+package load
+
+import (
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/pkg/procfs"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "load"
+	description = `Collect the system load average and uptime from /proc.`
+)
+
+type Load struct {
+	// ProcRoot is the mount point of the proc filesystem.
+	ProcRoot string `mapstructure:"proc_root"`
+}
+
+func NewLoad() optic.Source {
+	return &Load{
+		ProcRoot: procfs.DefaultProcRoot,
+	}
+}
+
+func (*Load) Kind() string {
+	return name
+}
+
+func (*Load) Description() string {
+	return description
+}
+
+func (l *Load) Gather(acc optic.Accumulator) error {
+	fs := procfs.NewFS(l.ProcRoot, "")
+
+	load, err := fs.LoadAvg()
+	if err != nil {
+		return err
+	}
+	uptime, err := fs.Uptime()
+	if err != nil {
+		return err
+	}
+	stat, err := fs.Stat()
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{
+		"load1":  load.Load1,
+		"load5":  load.Load5,
+		"load15": load.Load15,
+		"n_cpus": int64(len(stat.CPUs)),
+		"uptime": int64(uptime),
+	}
+	acc.AddMetricType(name, map[string]string{}, fields, optic.GaugeMetric)
+	return nil
+}
+
+func init() {
+	sources.Add(name, NewLoad)
+}
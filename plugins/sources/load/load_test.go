@@ -0,0 +1,38 @@
+package load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestLoad_impl(t *testing.T) {
+	var _ optic.Source = new(Load)
+}
+
+func TestGather(t *testing.T) {
+	l := NewLoad().(*Load)
+	l.ProcRoot = "../../../pkg/procfs/testdata/proc"
+	acc := &testutil.Accumulator{}
+
+	require.NoError(t, l.Gather(acc))
+	acc.AssertContainsMetricWithTaggedFields(t, "load", map[string]string{},
+		map[string]interface{}{
+			"load1":  0.25,
+			"load5":  0.5,
+			"load15": 1.0,
+			"n_cpus": int64(2),
+			"uptime": int64(12345),
+		})
+}
+
+func TestGatherMissing(t *testing.T) {
+	l := NewLoad().(*Load)
+	l.ProcRoot = "testdata/missing"
+	assert.Error(t, l.Gather(&testutil.Accumulator{}))
+}
@@ -0,0 +1,85 @@
+package mem
+
+import (
+	"fmt"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/pkg/procfs"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "mem"
+	description = `Collect memory and swap usage from /proc/meminfo.`
+)
+
+type Mem struct {
+	// ProcRoot is the mount point of the proc filesystem.
+	ProcRoot string `mapstructure:"proc_root"`
+}
+
+func NewMem() optic.Source {
+	return &Mem{
+		ProcRoot: procfs.DefaultProcRoot,
+	}
+}
+
+func (*Mem) Kind() string {
+	return name
+}
+
+func (*Mem) Description() string {
+	return description
+}
+
+func (m *Mem) Gather(acc optic.Accumulator) error {
+	info, err := procfs.NewFS(m.ProcRoot, "").MemInfo()
+	if err != nil {
+		return err
+	}
+
+	total := info["MemTotal"]
+	if total == 0 {
+		return fmt.Errorf("missing MemTotal in meminfo")
+	}
+	free := info["MemFree"]
+	buffers := info["Buffers"]
+	cached := info["Cached"] + info["SReclaimable"]
+
+	available, ok := info["MemAvailable"]
+	if !ok {
+		// kernels before 3.14 don't estimate the available memory
+		available = free + buffers + cached
+	}
+	used := total - free - buffers - cached
+	if free+buffers+cached > total {
+		used = 0
+	}
+
+	swapTotal, swapFree := info["SwapTotal"], info["SwapFree"]
+
+	fields := map[string]interface{}{
+		"total":             total,
+		"free":              free,
+		"available":         available,
+		"used":              used,
+		"buffers":           buffers,
+		"cached":            cached,
+		"shared":            info["Shmem"],
+		"slab":              info["Slab"],
+		"dirty":             info["Dirty"],
+		"writeback":         info["Writeback"],
+		"used_percent":      100 * float64(used) / float64(total),
+		"available_percent": 100 * float64(available) / float64(total),
+		"swap_total":        swapTotal,
+		"swap_free":         swapFree,
+		"swap_used":         swapTotal - swapFree,
+		"swap_cached":       info["SwapCached"],
+	}
+	acc.AddMetricType(name, map[string]string{}, fields, optic.GaugeMetric)
+	return nil
+}
+
+func init() {
+	sources.Add(name, NewMem)
+}
@@ -0,0 +1,44 @@
+package mem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestMem_impl(t *testing.T) {
+	var _ optic.Source = new(Mem)
+}
+
+func TestGather(t *testing.T) {
+	m := NewMem().(*Mem)
+	m.ProcRoot = "../../../pkg/procfs/testdata/proc"
+	acc := &testutil.Accumulator{}
+
+	require.NoError(t, m.Gather(acc))
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, optic.GaugeMetric, acc.Events[0].MetricType)
+
+	kB := func(v uint64) uint64 { return v * 1024 }
+	fields := acc.Events[0].Fields
+	assert.Equal(t, kB(8046916), fields["total"])
+	assert.Equal(t, kB(1529544), fields["free"])
+	assert.Equal(t, kB(5402132), fields["available"])
+	assert.Equal(t, kB(3449072+297816), fields["cached"])
+	assert.Equal(t, kB(8046916-1529544-310332-3449072-297816), fields["used"])
+	assert.InDelta(t, 30.57, fields["used_percent"], 0.01)
+	assert.InDelta(t, 67.13, fields["available_percent"], 0.01)
+	assert.Equal(t, kB(2097148), fields["swap_total"])
+	assert.Equal(t, uint64(0), fields["swap_used"])
+}
+
+func TestGatherMissing(t *testing.T) {
+	m := NewMem().(*Mem)
+	m.ProcRoot = "testdata/missing"
+	assert.Error(t, m.Gather(&testutil.Accumulator{}))
+}
@@ -0,0 +1,81 @@
+package net
+
+import (
+	"path/filepath"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/pkg/procfs"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "net"
+	description = `Collect network interface statistics from /proc/net/dev.`
+)
+
+const loopback = "lo"
+
+type Net struct {
+	// Interfaces is a list of glob patterns of interfaces to collect. Empty
+	// collects all interfaces except the loopback.
+	Interfaces []string `mapstructure:"interfaces"`
+
+	// ProcRoot is the mount point of the proc filesystem.
+	ProcRoot string `mapstructure:"proc_root"`
+}
+
+func NewNet() optic.Source {
+	return &Net{
+		ProcRoot: procfs.DefaultProcRoot,
+	}
+}
+
+func (*Net) Kind() string {
+	return name
+}
+
+func (*Net) Description() string {
+	return description
+}
+
+func (n *Net) Gather(acc optic.Accumulator) error {
+	stats, err := procfs.NewFS(n.ProcRoot, "").NetDev()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		if !n.match(s.Interface) {
+			continue
+		}
+		fields := map[string]interface{}{
+			"bytes_recv":   s.RxBytes,
+			"bytes_sent":   s.TxBytes,
+			"packets_recv": s.RxPackets,
+			"packets_sent": s.TxPackets,
+			"err_in":       s.RxErrors,
+			"err_out":      s.TxErrors,
+			"drop_in":      s.RxDropped,
+			"drop_out":     s.TxDropped,
+		}
+		tags := map[string]string{"interface": s.Interface}
+		acc.AddMetricType(name, tags, fields, optic.CounterMetric)
+	}
+	return nil
+}
+
+func (n *Net) match(iface string) bool {
+	if len(n.Interfaces) == 0 {
+		return iface != loopback
+	}
+	for _, pattern := range n.Interfaces {
+		if ok, _ := filepath.Match(pattern, iface); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	sources.Add(name, NewNet)
+}
@@ -0,0 +1,45 @@
+package net
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestNet_impl(t *testing.T) {
+	var _ optic.Source = new(Net)
+}
+
+func TestGather(t *testing.T) {
+	n := NewNet().(*Net)
+	n.ProcRoot = "../../../pkg/procfs/testdata/proc"
+	acc := &testutil.Accumulator{}
+
+	require.NoError(t, n.Gather(acc))
+	// the loopback is skipped by default
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, optic.CounterMetric, acc.Events[0].MetricType)
+
+	acc.AssertContainsMetricWithTaggedFields(t, "net", map[string]string{"interface": "eth0"},
+		map[string]interface{}{
+			"bytes_recv":   uint64(123456789),
+			"bytes_sent":   uint64(98765432),
+			"packets_recv": uint64(100000),
+			"packets_sent": uint64(90000),
+			"err_in":       uint64(1),
+			"err_out":      uint64(3),
+			"drop_in":      uint64(2),
+			"drop_out":     uint64(4),
+		})
+
+	n.Interfaces = []string{"lo", "wlan*"}
+	acc = &testutil.Accumulator{}
+	require.NoError(t, n.Gather(acc))
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, "lo", acc.Events[0].Tags["interface"])
+}
@@ -0,0 +1,79 @@
+package processes
+
+import (
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/pkg/procfs"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "processes"
+	description = `Collect the number of processes in each state from /proc.`
+)
+
+// fields by process state, as in the third field of /proc/[pid]/stat
+var stateFields = map[byte]string{
+	'R': "running",
+	'S': "sleeping",
+	'D': "blocked",
+	'Z': "zombies",
+	'T': "stopped",
+	't': "stopped",
+	'I': "idle",
+	'X': "dead",
+	'x': "dead",
+	'W': "paging",
+}
+
+type Processes struct {
+	// ProcRoot is the mount point of the proc filesystem.
+	ProcRoot string `mapstructure:"proc_root"`
+}
+
+func NewProcesses() optic.Source {
+	return &Processes{
+		ProcRoot: procfs.DefaultProcRoot,
+	}
+}
+
+func (*Processes) Kind() string {
+	return name
+}
+
+func (*Processes) Description() string {
+	return description
+}
+
+func (p *Processes) Gather(acc optic.Accumulator) error {
+	stats, err := procfs.NewFS(p.ProcRoot, "").Processes()
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{
+		"total":         int64(len(stats)),
+		"total_threads": int64(0),
+		"unknown":       int64(0),
+	}
+	for _, field := range stateFields {
+		fields[field] = int64(0)
+	}
+
+	var threads int64
+	for _, stat := range stats {
+		threads += int64(stat.Threads)
+		field, ok := stateFields[stat.State]
+		if !ok {
+			field = "unknown"
+		}
+		fields[field] = fields[field].(int64) + 1
+	}
+	fields["total_threads"] = threads
+
+	acc.AddMetricType(name, map[string]string{}, fields, optic.GaugeMetric)
+	return nil
+}
+
+func init() {
+	sources.Add(name, NewProcesses)
+}
@@ -0,0 +1,44 @@
+package processes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestProcesses_impl(t *testing.T) {
+	var _ optic.Source = new(Processes)
+}
+
+func TestGather(t *testing.T) {
+	p := NewProcesses().(*Processes)
+	p.ProcRoot = "../../../pkg/procfs/testdata/proc"
+	acc := &testutil.Accumulator{}
+
+	require.NoError(t, p.Gather(acc))
+	acc.AssertContainsMetricWithTaggedFields(t, "processes", map[string]string{},
+		map[string]interface{}{
+			"total":         int64(3),
+			"total_threads": int64(6),
+			"running":       int64(1),
+			"sleeping":      int64(1),
+			"blocked":       int64(0),
+			"zombies":       int64(1),
+			"stopped":       int64(0),
+			"idle":          int64(0),
+			"dead":          int64(0),
+			"paging":        int64(0),
+			"unknown":       int64(0),
+		})
+}
+
+func TestGatherMissing(t *testing.T) {
+	p := NewProcesses().(*Processes)
+	p.ProcRoot = "testdata/missing"
+	assert.Error(t, p.Gather(&testutil.Accumulator{}))
+}
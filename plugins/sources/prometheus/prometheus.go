@@ -0,0 +1,125 @@
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zbiljic/optic/internal/prometheus"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "prometheus"
+	description = `Scrape metrics from Prometheus endpoints.`
+)
+
+const (
+	acceptHeader = `application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1`
+
+	defaultTimeout = 5 * time.Second
+
+	// maximum size of a scraped response
+	maxBodySize = 64 * 1024 * 1024
+)
+
+type Prometheus struct {
+	// URLs of the endpoints to scrape.
+	URLs []string `mapstructure:"urls"`
+
+	// Timeout of a single scrape.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Headers are added to every scrape request.
+	Headers map[string]string `mapstructure:"headers"`
+
+	client *http.Client
+}
+
+func NewPrometheus() optic.Source {
+	return &Prometheus{
+		Timeout: defaultTimeout,
+	}
+}
+
+func (*Prometheus) Kind() string {
+	return name
+}
+
+func (*Prometheus) Description() string {
+	return description
+}
+
+func (p *Prometheus) Gather(acc optic.Accumulator) error {
+	if p.client == nil {
+		timeout := p.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		p.client = &http.Client{Timeout: timeout}
+	}
+
+	var wg sync.WaitGroup
+	for _, u := range p.URLs {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			if err := p.scrape(acc, url); err != nil {
+				acc.AddError(fmt.Errorf("failed to scrape %s: %s", url, err))
+			}
+		}(u)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (p *Prometheus) scrape(acc optic.Accumulator, url string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", acceptHeader)
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	openMetrics := mediaType == "application/openmetrics-text"
+
+	metrics, err := prometheus.Parse(body, openMetrics, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, m := range metrics {
+		tags := m.Tags()
+		tags["url"] = url
+		acc.AddMetricType(m.Name(), tags, m.Fields(), m.MetricType(), m.Time())
+	}
+	return nil
+}
+
+func init() {
+	sources.Add(name, NewPrometheus)
+}
@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestPrometheus_impl(t *testing.T) {
+	var _ optic.Source = new(Prometheus)
+}
+
+const sampleText = `# TYPE go_goroutines gauge
+go_goroutines 15
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 1
+rpc_duration_seconds_sum 2
+rpc_duration_seconds_count 3
+`
+
+const sampleOpenMetrics = `# TYPE requests counter
+requests_total{code="200"} 7 1520879607.789
+# EOF
+`
+
+func TestGather(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Accept"), "application/openmetrics-text")
+		switch r.URL.Path {
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			fmt.Fprint(w, sampleText)
+		case "/openmetrics":
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+			fmt.Fprint(w, sampleOpenMetrics)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := NewPrometheus().(*Prometheus)
+	p.URLs = []string{ts.URL + "/text", ts.URL + "/openmetrics", ts.URL + "/missing"}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, p.Gather(acc))
+
+	require.Len(t, acc.Errors, 1)
+	assert.Contains(t, acc.Errors[0].Error(), "404")
+
+	m, ok := acc.GetMetric("go_goroutines")
+	require.True(t, ok)
+	assert.Equal(t, optic.GaugeMetric, m.MetricType)
+	assert.Equal(t, map[string]string{"url": ts.URL + "/text"}, m.Tags)
+	assert.Equal(t, map[string]interface{}{"gauge": float64(15)}, m.Fields)
+
+	m, ok = acc.GetMetric("rpc_duration_seconds")
+	require.True(t, ok)
+	assert.Equal(t, optic.SummaryMetric, m.MetricType)
+	assert.Equal(t, map[string]interface{}{
+		"quantile_0_5": float64(1),
+		"sum":          float64(2),
+		"count":        float64(3),
+	}, m.Fields)
+
+	m, ok = acc.GetMetric("requests_total")
+	require.True(t, ok)
+	assert.Equal(t, optic.CounterMetric, m.MetricType)
+	assert.Equal(t, map[string]string{"code": "200", "url": ts.URL + "/openmetrics"}, m.Tags)
+	assert.Equal(t, int64(1520879607789), m.Time.UnixNano()/1e6)
+}
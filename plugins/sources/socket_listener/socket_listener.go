@@ -0,0 +1,143 @@
+package socket_listener
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/zbiljic/optic/internal/listener"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/codecs/line"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "socket_listener"
+	description = `Receive events over TCP, UDP or unix sockets, decoded with the configured codec.`
+)
+
+const (
+	defaultAddress       = "tcp://:8094"
+	defaultRemoteAddrTag = "remote_addr"
+
+	// DefaultMaxMessageSize is the default maximum size of a single datagram
+	// or line.
+	DefaultMaxMessageSize = 64 * 1024
+)
+
+type SocketListener struct {
+	// Address to listen on, e.g. "tcp://:8094", "udp://127.0.0.1:8094",
+	// "unix:///var/run/optic.sock" or "unixgram:///var/run/optic.sock".
+	Address string `mapstructure:"address"`
+
+	// MaxConnections limits the number of concurrent stream connections.
+	// Connections over the limit are closed right away. Zero means no limit.
+	MaxConnections int `mapstructure:"max_connections"`
+
+	// ReadTimeout closes stream connections which are idle for longer. Zero
+	// means no timeout.
+	ReadTimeout time.Duration `mapstructure:"read_timeout"`
+
+	// MaxMessageSize is the maximum size of a single datagram or line.
+	MaxMessageSize int `mapstructure:"max_message_size"`
+
+	// RemoteAddrTag is the tag which is set to the address of the sender.
+	// Empty disables the tag.
+	RemoteAddrTag string `mapstructure:"remote_addr_tag"`
+
+	decoder optic.Decoder
+	acc     optic.Accumulator
+
+	ln *listener.Listener
+}
+
+func NewSocketListener() optic.Source {
+	return &SocketListener{
+		Address:        defaultAddress,
+		MaxMessageSize: DefaultMaxMessageSize,
+		RemoteAddrTag:  defaultRemoteAddrTag,
+		decoder:        line.NewLineCodec(),
+	}
+}
+
+func (*SocketListener) Kind() string {
+	return name
+}
+
+func (*SocketListener) Description() string {
+	return description
+}
+
+func (s *SocketListener) SetDecoder(decoder optic.Decoder) {
+	s.decoder = decoder
+}
+
+func (*SocketListener) Gather(acc optic.Accumulator) error {
+	// events are emitted by the listener goroutines
+	return nil
+}
+
+func (s *SocketListener) Start(acc optic.Accumulator) error {
+	if s.MaxMessageSize <= 0 {
+		s.MaxMessageSize = DefaultMaxMessageSize
+	}
+	s.acc = acc
+
+	s.ln = &listener.Listener{
+		Name:           name,
+		Address:        s.Address,
+		MaxConnections: s.MaxConnections,
+		ReadTimeout:    s.ReadTimeout,
+		MaxMessageSize: s.MaxMessageSize,
+		// every datagram is decoded as a whole
+		HandlePacket: func(b []byte, remote net.Addr) {
+			s.handle(bytes.TrimRight(b, "\r\n"), remote)
+		},
+		HandleConn:  s.handleConn,
+		HandleError: acc.AddError,
+	}
+	return s.ln.Listen()
+}
+
+func (s *SocketListener) Stop() {
+	if s.ln != nil {
+		s.ln.Close()
+	}
+}
+
+// handleConn decodes every line received on a stream connection.
+func (s *SocketListener) handleConn(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), s.MaxMessageSize)
+	for scanner.Scan() {
+		s.handle(bytes.TrimRight(scanner.Bytes(), "\r"), conn.RemoteAddr())
+	}
+	if err := scanner.Err(); err != nil && !listener.IsClosed(err) {
+		s.acc.AddError(fmt.Errorf("%s: %s", conn.RemoteAddr(), err))
+	}
+}
+
+func (s *SocketListener) handle(b []byte, remote net.Addr) {
+	if len(b) == 0 {
+		return
+	}
+
+	events, err := s.decoder.Decode(b)
+	if err != nil {
+		s.acc.AddError(fmt.Errorf("failed to decode: %s", err))
+		return
+	}
+
+	for _, event := range events {
+		if s.RemoteAddrTag != "" && remote != nil && remote.String() != "" {
+			event.AddTag(s.RemoteAddrTag, remote.String())
+		}
+		s.acc.AddEvent(event)
+	}
+}
+
+func init() {
+	sources.Add(name, NewSocketListener)
+}
@@ -0,0 +1,163 @@
+package socket_listener
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/codecs/influx"
+)
+
+// Check the interfaces are satisfied
+func TestSocketListener_impl(t *testing.T) {
+	var _ optic.ServiceSource = new(SocketListener)
+	var _ optic.DecoderInput = new(SocketListener)
+}
+
+func startListener(t *testing.T, s *SocketListener, address string) *testutil.Accumulator {
+	s.Address = address
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	return acc
+}
+
+func TestListenTCP(t *testing.T) {
+	s := NewSocketListener().(*SocketListener)
+	acc := startListener(t, s, "tcp://127.0.0.1:0")
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.ln.Addr().String())
+	require.NoError(t, err)
+	fmt.Fprint(conn, "first line\r\nsecond line\n\n")
+	conn.Close()
+
+	acc.Wait(2)
+	acc.Lock()
+	defer acc.Unlock()
+	require.Len(t, acc.Events, 2)
+	assert.Equal(t, optic.RawEvent, acc.Events[0].Type)
+	assert.Equal(t, "first line", string(acc.Events[0].Value))
+	assert.Equal(t, "second line", string(acc.Events[1].Value))
+	assert.Equal(t, conn.LocalAddr().String(), acc.Events[0].Tags["remote_addr"])
+	assert.Empty(t, acc.Errors)
+}
+
+func TestListenUDPDecoder(t *testing.T) {
+	s := NewSocketListener().(*SocketListener)
+	s.SetDecoder(influx.NewInfluxCodec())
+	s.RemoteAddrTag = "source"
+	acc := startListener(t, s, "udp://127.0.0.1:0")
+	defer s.Stop()
+
+	conn, err := net.Dial("udp", s.ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("cpu,host=a usage=1 1\ncpu,host=b usage=2 2\n"))
+	require.NoError(t, err)
+
+	acc.Wait(2)
+	acc.Lock()
+	defer acc.Unlock()
+	require.Len(t, acc.Events, 2)
+	assert.Equal(t, optic.MetricEvent, acc.Events[0].Type)
+	assert.Equal(t, "cpu", acc.Events[0].Name)
+	assert.Equal(t, map[string]string{
+		"host":   "b",
+		"source": conn.LocalAddr().String(),
+	}, acc.Events[1].Tags)
+}
+
+func TestDecodeError(t *testing.T) {
+	s := NewSocketListener().(*SocketListener)
+	s.SetDecoder(influx.NewInfluxCodec())
+	acc := startListener(t, s, "udp://127.0.0.1:0")
+	defer s.Stop()
+
+	conn, err := net.Dial("udp", s.ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("not line protocol"))
+	require.NoError(t, err)
+
+	acc.WaitError(1)
+	assert.Empty(t, acc.Events)
+}
+
+func TestMaxConnections(t *testing.T) {
+	s := NewSocketListener().(*SocketListener)
+	s.MaxConnections = 1
+	acc := startListener(t, s, "tcp://127.0.0.1:0")
+	defer s.Stop()
+
+	first, err := net.Dial("tcp", s.ln.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+	fmt.Fprint(first, "first\n")
+	acc.Wait(1)
+
+	// the second connection is closed without reading from it
+	second, err := net.Dial("tcp", s.ln.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = second.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	fmt.Fprint(first, "still open\n")
+	acc.Wait(2)
+	acc.Lock()
+	assert.Equal(t, "still open", string(acc.Events[1].Value))
+	acc.Unlock()
+}
+
+func TestReadTimeout(t *testing.T) {
+	s := NewSocketListener().(*SocketListener)
+	s.ReadTimeout = 50 * time.Millisecond
+	acc := startListener(t, s, "tcp://127.0.0.1:0")
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.Empty(t, acc.Errors)
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socket_listener")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "optic.sock")
+
+	s := NewSocketListener().(*SocketListener)
+	acc := startListener(t, s, "unix://"+sock)
+
+	conn, err := net.Dial("unix", sock)
+	require.NoError(t, err)
+	fmt.Fprint(conn, "hello\n")
+
+	acc.Wait(1)
+	acc.Lock()
+	assert.Equal(t, "hello", string(acc.Events[0].Value))
+	acc.Unlock()
+
+	// stop closes open connections and removes the socket
+	s.Stop()
+	conn.Close()
+	_, err = os.Stat(sock)
+	assert.True(t, os.IsNotExist(err))
+}
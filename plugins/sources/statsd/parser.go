@@ -0,0 +1,122 @@
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Metric types as they appear in the StatsD protocol.
+const (
+	typeCounter      = "c"
+	typeGauge        = "g"
+	typeTimer        = "ms"
+	typeHistogram    = "h"
+	typeDistribution = "d"
+	typeSet          = "s"
+)
+
+// sample is a single value of a metric received in a StatsD line.
+type sample struct {
+	name  string
+	tags  map[string]string
+	typ   string
+	value float64
+	// raw value, used as the member of a set
+	raw string
+	// relative is set for gauges which are incremented or decremented
+	relative bool
+	rate     float64
+}
+
+// parseLine parses a single line in the StatsD format, with optional
+// DogStatsD sample rate and tags
+//
+//	<bucket>:<value>|<type>[|@<rate>][|#<tag>:<value>,<tag>...]
+//
+// A line may contain multiple values for the same bucket, separated by ':'.
+func parseLine(line string) ([]sample, error) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid line: %s", line)
+	}
+	name := line[:i]
+
+	// tags contain ':' too, so lines with tags have a single value
+	values := []string{line[i+1:]}
+	if !strings.Contains(values[0], "|#") {
+		values = strings.Split(values[0], ":")
+	}
+
+	var samples []sample
+	for _, part := range values {
+		s, err := parseValue(name, part)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+func parseValue(name, s string) (sample, error) {
+	parts := strings.Split(s, "|")
+	if len(parts) < 2 {
+		return sample{}, fmt.Errorf("missing type")
+	}
+
+	smp := sample{
+		name: name,
+		typ:  parts[1],
+		raw:  parts[0],
+		rate: 1,
+	}
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return sample{}, fmt.Errorf("invalid sample rate: %s", part[1:])
+			}
+			smp.rate = rate
+		case strings.HasPrefix(part, "#"):
+			smp.tags = parseTags(part[1:])
+		}
+	}
+
+	switch smp.typ {
+	case typeSet:
+		return smp, nil
+	case typeCounter, typeGauge, typeTimer, typeHistogram, typeDistribution:
+	default:
+		return sample{}, fmt.Errorf("unsupported type: %s", smp.typ)
+	}
+
+	if smp.typ == typeGauge && (strings.HasPrefix(smp.raw, "+") || strings.HasPrefix(smp.raw, "-")) {
+		smp.relative = true
+	}
+	value, err := strconv.ParseFloat(smp.raw, 64)
+	if err != nil {
+		return sample{}, fmt.Errorf("invalid value: %s", smp.raw)
+	}
+	smp.value = value
+	return smp, nil
+}
+
+// parseTags parses DogStatsD tags. Tags without a value get "true".
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+		k, v := tag, "true"
+		if i := strings.IndexByte(tag, ':'); i >= 0 {
+			k, v = tag[:i], tag[i+1:]
+		}
+		if k != "" {
+			tags[k] = v
+		}
+	}
+	return tags
+}
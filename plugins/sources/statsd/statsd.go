@@ -0,0 +1,374 @@
+package statsd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zbiljic/optic/internal/listener"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "statsd"
+	description = `Receive StatsD metrics over UDP or TCP and aggregate them between gathers.`
+)
+
+const (
+	defaultAddress = "udp://:8125"
+
+	// DefaultMaxMessageSize is the default maximum size of a single UDP packet
+	// or TCP line.
+	DefaultMaxMessageSize = 64 * 1024
+
+	// DefaultPercentileLimit is the default number of timer values kept for
+	// calculating percentiles.
+	DefaultPercentileLimit = 1000
+)
+
+type Statsd struct {
+	// Address to listen on, e.g. "udp://:8125" or "tcp://127.0.0.1:8125".
+	Address string `mapstructure:"address"`
+
+	// MaxMessageSize is the maximum size of a single UDP packet or TCP line.
+	MaxMessageSize int `mapstructure:"max_message_size"`
+
+	// ReadTimeout closes TCP connections which are idle for longer. Zero means
+	// no timeout.
+	ReadTimeout time.Duration `mapstructure:"read_timeout"`
+
+	// Percentiles calculated for timers, e.g. 90 or 99.9.
+	Percentiles []float64 `mapstructure:"percentiles"`
+
+	// PercentileLimit is the maximum number of values of a single timer kept
+	// for calculating percentiles. Once reached, values are sampled.
+	PercentileLimit int `mapstructure:"percentile_limit"`
+
+	// Delete* reset the aggregated values after every gather. Otherwise
+	// the last value is emitted again, and counters keep counting.
+	DeleteCounters bool `mapstructure:"delete_counters"`
+	DeleteGauges   bool `mapstructure:"delete_gauges"`
+	DeleteSets     bool `mapstructure:"delete_sets"`
+	DeleteTimings  bool `mapstructure:"delete_timings"`
+
+	acc optic.Accumulator
+
+	ln *listener.Listener
+
+	counters map[string]*counter
+	gauges   map[string]*gauge
+	sets     map[string]*set
+	timers   map[string]*timer
+
+	mu sync.Mutex
+}
+
+type series struct {
+	name string
+	tags map[string]string
+}
+
+// copyTags returns a copy of the tags, as the accumulator may add to them.
+func (s series) copyTags() map[string]string {
+	tags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+type counter struct {
+	series
+	value float64
+}
+
+type gauge struct {
+	series
+	value float64
+}
+
+type set struct {
+	series
+	members map[string]struct{}
+}
+
+// timer keeps running statistics over all values, and a sample of the values
+// for percentiles. Sample rates are taken into account as weights.
+type timer struct {
+	series
+	count  float64
+	sum    float64
+	sumSq  float64
+	lower  float64
+	upper  float64
+	values []float64
+	seen   int
+}
+
+func NewStatsd() optic.Source {
+	return &Statsd{
+		Address:         defaultAddress,
+		MaxMessageSize:  DefaultMaxMessageSize,
+		Percentiles:     []float64{90},
+		PercentileLimit: DefaultPercentileLimit,
+		DeleteCounters:  true,
+		DeleteGauges:    true,
+		DeleteSets:      true,
+		DeleteTimings:   true,
+		counters:        make(map[string]*counter),
+		gauges:          make(map[string]*gauge),
+		sets:            make(map[string]*set),
+		timers:          make(map[string]*timer),
+	}
+}
+
+func (*Statsd) Kind() string {
+	return name
+}
+
+func (*Statsd) Description() string {
+	return description
+}
+
+// Gather emits all metrics aggregated since the previous gather.
+func (s *Statsd) Gather(acc optic.Accumulator) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for _, c := range s.counters {
+		fields := map[string]interface{}{
+			"value": int64(math.Round(c.value)),
+		}
+		acc.AddMetricType(c.name, c.copyTags(), fields, optic.CounterMetric, now)
+	}
+	for _, g := range s.gauges {
+		fields := map[string]interface{}{
+			"value": g.value,
+		}
+		acc.AddMetricType(g.name, g.copyTags(), fields, optic.GaugeMetric, now)
+	}
+	for _, st := range s.sets {
+		fields := map[string]interface{}{
+			"value": int64(len(st.members)),
+		}
+		acc.AddMetricType(st.name, st.copyTags(), fields, optic.GaugeMetric, now)
+	}
+	for _, t := range s.timers {
+		acc.AddMetricType(t.name, t.copyTags(), t.fields(s.Percentiles), optic.GaugeMetric, now)
+	}
+
+	if s.DeleteCounters {
+		s.counters = make(map[string]*counter)
+	}
+	if s.DeleteGauges {
+		s.gauges = make(map[string]*gauge)
+	}
+	if s.DeleteSets {
+		s.sets = make(map[string]*set)
+	}
+	if s.DeleteTimings {
+		s.timers = make(map[string]*timer)
+	}
+	return nil
+}
+
+func (s *Statsd) Start(acc optic.Accumulator) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.MaxMessageSize <= 0 {
+		s.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if s.PercentileLimit <= 0 {
+		s.PercentileLimit = DefaultPercentileLimit
+	}
+	if s.counters == nil {
+		s.counters = make(map[string]*counter)
+		s.gauges = make(map[string]*gauge)
+		s.sets = make(map[string]*set)
+		s.timers = make(map[string]*timer)
+	}
+
+	s.acc = acc
+
+	s.ln = &listener.Listener{
+		Name:           name,
+		Address:        s.Address,
+		Networks:       []string{"udp", "udp4", "udp6", "tcp", "tcp4", "tcp6"},
+		ReadTimeout:    s.ReadTimeout,
+		MaxMessageSize: s.MaxMessageSize,
+		// every packet holds one or more lines
+		HandlePacket: func(b []byte, _ net.Addr) {
+			for _, line := range bytes.Split(b, []byte("\n")) {
+				s.handle(string(line))
+			}
+		},
+		HandleConn:  s.handleConn,
+		HandleError: acc.AddError,
+	}
+	return s.ln.Listen()
+}
+
+func (s *Statsd) Stop() {
+	if s.ln != nil {
+		s.ln.Close()
+	}
+}
+
+// handleConn reads newline delimited lines from a TCP connection.
+func (s *Statsd) handleConn(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), s.MaxMessageSize)
+	for scanner.Scan() {
+		s.handle(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil && !listener.IsClosed(err) {
+		s.acc.AddError(fmt.Errorf("%s: %s", conn.RemoteAddr(), err))
+	}
+}
+
+func (s *Statsd) handle(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	samples, err := parseLine(line)
+	if err != nil {
+		s.acc.AddError(fmt.Errorf("failed to parse statsd line: %s", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, smp := range samples {
+		s.aggregate(smp)
+	}
+}
+
+func (s *Statsd) aggregate(smp sample) {
+	key := seriesKey(smp.name, smp.tags)
+	ser := series{name: smp.name, tags: smp.tags}
+
+	switch smp.typ {
+	case typeCounter:
+		c, ok := s.counters[key]
+		if !ok {
+			c = &counter{series: ser}
+			s.counters[key] = c
+		}
+		c.value += smp.value / smp.rate
+	case typeGauge:
+		g, ok := s.gauges[key]
+		if !ok {
+			g = &gauge{series: ser}
+			s.gauges[key] = g
+		}
+		if smp.relative {
+			g.value += smp.value
+		} else {
+			g.value = smp.value
+		}
+	case typeSet:
+		st, ok := s.sets[key]
+		if !ok {
+			st = &set{series: ser, members: make(map[string]struct{})}
+			s.sets[key] = st
+		}
+		st.members[smp.raw] = struct{}{}
+	case typeTimer, typeHistogram, typeDistribution:
+		t, ok := s.timers[key]
+		if !ok {
+			t = &timer{series: ser, lower: smp.value, upper: smp.value}
+			s.timers[key] = t
+		}
+		t.add(smp.value, 1/smp.rate, s.PercentileLimit)
+	}
+}
+
+func (t *timer) add(value, weight float64, limit int) {
+	t.count += weight
+	t.sum += value * weight
+	t.sumSq += value * value * weight
+	t.lower = math.Min(t.lower, value)
+	t.upper = math.Max(t.upper, value)
+
+	// reservoir sampling keeps a uniform sample once the limit is reached
+	t.seen++
+	if len(t.values) < limit {
+		t.values = append(t.values, value)
+	} else if i := rand.Intn(t.seen); i < limit {
+		t.values[i] = value
+	}
+}
+
+func (t *timer) fields(percentiles []float64) map[string]interface{} {
+	mean := t.sum / t.count
+	fields := map[string]interface{}{
+		"count":  int64(math.Round(t.count)),
+		"sum":    t.sum,
+		"lower":  t.lower,
+		"upper":  t.upper,
+		"mean":   mean,
+		"stddev": math.Sqrt(math.Max(t.sumSq/t.count-mean*mean, 0)),
+	}
+
+	values := make([]float64, len(t.values))
+	copy(values, t.values)
+	sort.Float64s(values)
+	for _, p := range percentiles {
+		if p <= 0 || p > 100 {
+			continue
+		}
+		fields[percentileField(p)] = percentile(values, p)
+	}
+	return fields
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(values []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	return values[rank-1]
+}
+
+// percentileField returns the field name for a percentile, e.g. "p90" or
+// "p99_9".
+func percentileField(p float64) string {
+	return "p" + strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "_", 1)
+}
+
+func seriesKey(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(tags[k])
+	}
+	return b.String()
+}
+
+func init() {
+	sources.Add(name, NewStatsd)
+}
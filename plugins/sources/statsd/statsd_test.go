@@ -0,0 +1,211 @@
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestStatsd_impl(t *testing.T) {
+	var _ optic.ServiceSource = new(Statsd)
+}
+
+func TestParseLine(t *testing.T) {
+	samples, err := parseLine("requests:1|c|@0.5|#env:prod,canary")
+	require.NoError(t, err)
+	require.Len(t, samples, 1)
+	assert.Equal(t, "requests", samples[0].name)
+	assert.Equal(t, typeCounter, samples[0].typ)
+	assert.Equal(t, 1.0, samples[0].value)
+	assert.Equal(t, 0.5, samples[0].rate)
+	assert.Equal(t, map[string]string{"env": "prod", "canary": "true"}, samples[0].tags)
+
+	samples, err = parseLine("latency:10|ms:20|ms")
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	assert.Equal(t, 20.0, samples[1].value)
+
+	samples, err = parseLine("temp:-3|g")
+	require.NoError(t, err)
+	assert.True(t, samples[0].relative)
+
+	samples, err = parseLine("users:alice|s")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", samples[0].raw)
+}
+
+func TestParseLineErrors(t *testing.T) {
+	for _, in := range []string{
+		"nocolon",
+		":1|c",
+		"a:1",
+		"a:x|c",
+		"a:1|q",
+		"a:1|c|@2",
+	} {
+		_, err := parseLine(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	s := NewStatsd().(*Statsd)
+	s.Percentiles = []float64{50, 99.9}
+	acc := &testutil.Accumulator{}
+	s.acc = acc
+
+	for _, line := range []string{
+		"hits:1|c",
+		"hits:2|c|@0.5",
+		"hits:1|c|#env:prod",
+		"temp:20|g",
+		"temp:+5|g",
+		"temp:-2|g",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+		"latency:10|ms",
+		"latency:20|ms",
+		"latency:30|ms",
+		"latency:40|ms",
+	} {
+		s.handle(line)
+	}
+	require.Empty(t, acc.Errors)
+
+	require.NoError(t, s.Gather(acc))
+	require.Len(t, acc.Events, 5)
+
+	acc.AssertContainsMetricWithTaggedFields(t, "hits", map[string]string{},
+		map[string]interface{}{"value": int64(5)})
+	acc.AssertContainsMetricWithTaggedFields(t, "hits", map[string]string{"env": "prod"},
+		map[string]interface{}{"value": int64(1)})
+	acc.AssertContainsMetricWithTaggedFields(t, "temp", map[string]string{},
+		map[string]interface{}{"value": 23.0})
+	acc.AssertContainsMetricWithTaggedFields(t, "users", map[string]string{},
+		map[string]interface{}{"value": int64(2)})
+	acc.AssertContainsMetricWithTaggedFields(t, "latency", map[string]string{},
+		map[string]interface{}{
+			"count":  int64(4),
+			"sum":    100.0,
+			"lower":  10.0,
+			"upper":  40.0,
+			"mean":   25.0,
+			"stddev": 11.180339887498949,
+			"p50":    20.0,
+			"p99_9":  40.0,
+		})
+
+	for _, e := range acc.Events {
+		if e.Name == "hits" {
+			assert.Equal(t, optic.CounterMetric, e.MetricType)
+		} else {
+			assert.Equal(t, optic.GaugeMetric, e.MetricType)
+		}
+	}
+
+	// everything is reset after a gather
+	acc.ClearEvents()
+	require.NoError(t, s.Gather(acc))
+	assert.Empty(t, acc.Events)
+}
+
+func TestAggregateKeep(t *testing.T) {
+	s := NewStatsd().(*Statsd)
+	s.DeleteCounters = false
+	s.DeleteGauges = false
+	acc := &testutil.Accumulator{}
+	s.acc = acc
+
+	s.handle("hits:1|c")
+	s.handle("temp:20|g")
+	require.NoError(t, s.Gather(acc))
+
+	acc.ClearEvents()
+	s.handle("hits:1|c")
+	s.handle("temp:+1|g")
+	require.NoError(t, s.Gather(acc))
+
+	acc.AssertContainsMetricWithTaggedFields(t, "hits", map[string]string{},
+		map[string]interface{}{"value": int64(2)})
+	acc.AssertContainsMetricWithTaggedFields(t, "temp", map[string]string{},
+		map[string]interface{}{"value": 21.0})
+}
+
+func TestPercentileLimit(t *testing.T) {
+	s := NewStatsd().(*Statsd)
+	s.PercentileLimit = 10
+	s.acc = &testutil.Accumulator{}
+
+	for i := 0; i < 100; i++ {
+		s.handle(fmt.Sprintf("latency:%d|ms", i))
+	}
+	for _, timer := range s.timers {
+		assert.Len(t, timer.values, 10)
+		assert.Equal(t, 100.0, timer.count)
+		assert.Equal(t, 99.0, timer.upper)
+	}
+}
+
+func startStatsd(t *testing.T, address string) (*Statsd, *testutil.Accumulator) {
+	s := NewStatsd().(*Statsd)
+	s.Address = address
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	return s, acc
+}
+
+// gatherWhenReady waits until n series are aggregated, as the listener handles
+// lines asynchronously, and gathers them.
+func gatherWhenReady(t *testing.T, s *Statsd, acc *testutil.Accumulator, n int) {
+	for {
+		s.mu.Lock()
+		count := len(s.counters) + len(s.gauges) + len(s.sets) + len(s.timers)
+		s.mu.Unlock()
+		if count >= n {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, s.Gather(acc))
+}
+
+func TestListenUDP(t *testing.T) {
+	s, acc := startStatsd(t, "udp://127.0.0.1:0")
+	defer s.Stop()
+
+	conn, err := net.Dial("udp", s.ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hits:1|c\ntemp:3|g|#host:a\n"))
+	require.NoError(t, err)
+
+	gatherWhenReady(t, s, acc, 2)
+	acc.AssertContainsMetricWithTaggedFields(t, "hits", map[string]string{},
+		map[string]interface{}{"value": int64(1)})
+	acc.AssertContainsMetricWithTaggedFields(t, "temp", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 3.0})
+}
+
+func TestListenTCP(t *testing.T) {
+	s, acc := startStatsd(t, "tcp://127.0.0.1:0")
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.ln.Addr().String())
+	require.NoError(t, err)
+	fmt.Fprint(conn, "hits:1|c\r\nhits:2|c\nusers:a|s\n")
+	conn.Close()
+
+	gatherWhenReady(t, s, acc, 2)
+	acc.AssertContainsMetricWithTaggedFields(t, "users", map[string]string{},
+		map[string]interface{}{"value": int64(1)})
+}
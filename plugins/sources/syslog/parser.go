@@ -0,0 +1,270 @@
+package syslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const nilValue = "-"
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// message is a parsed syslog message. Empty strings stand for missing values.
+type message struct {
+	facility  int
+	severity  int
+	version   int
+	timestamp time.Time
+	hostname  string
+	appname   string
+	procID    string
+	msgID     string
+	// structured data as "<SD-ID>_<PARAM-NAME>" -> value
+	structuredData map[string]string
+	msg            string
+}
+
+// parse parses a message in RFC 5424 format, or in the legacy RFC 3164 format
+// if it has no version. Messages without a timestamp get now.
+func parse(b []byte, now time.Time) (*message, error) {
+	s := strings.TrimRight(string(b), "\r\n\x00")
+
+	pri, rest, err := parsePriority(s)
+	if err != nil {
+		return nil, err
+	}
+	m := &message{
+		facility: pri / 8,
+		severity: pri % 8,
+	}
+
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' {
+		if i := strings.IndexByte(rest, ' '); i > 0 {
+			if v, err := strconv.Atoi(rest[:i]); err == nil {
+				m.version = v
+				return m, m.parseRFC5424(rest[i+1:], now)
+			}
+		}
+	}
+	m.parseRFC3164(rest, now)
+	return m, nil
+}
+
+func parsePriority(s string) (int, string, error) {
+	if len(s) < 3 || s[0] != '<' {
+		return 0, "", fmt.Errorf("missing priority")
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return 0, "", fmt.Errorf("invalid priority")
+	}
+	pri, err := strconv.Atoi(s[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", fmt.Errorf("invalid priority: %s", s[1:end])
+	}
+	return pri, s[end+1:], nil
+}
+
+// parseRFC5424 parses the part of the message after the version:
+//
+//	TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP STRUCTURED-DATA [SP MSG]
+func (m *message) parseRFC5424(s string, now time.Time) error {
+	header := make([]string, 5)
+	for i := range header {
+		j := strings.IndexByte(s, ' ')
+		if j < 0 {
+			return fmt.Errorf("incomplete header")
+		}
+		header[i], s = s[:j], s[j+1:]
+	}
+
+	m.timestamp = now
+	if header[0] != nilValue {
+		ts, err := time.Parse(time.RFC3339Nano, header[0])
+		if err != nil {
+			return fmt.Errorf("invalid timestamp: %s", header[0])
+		}
+		m.timestamp = ts
+	}
+	m.hostname = nilToEmpty(header[1])
+	m.appname = nilToEmpty(header[2])
+	m.procID = nilToEmpty(header[3])
+	m.msgID = nilToEmpty(header[4])
+
+	rest, err := m.parseStructuredData(s)
+	if err != nil {
+		return err
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	m.msg = strings.TrimPrefix(rest, "\ufeff")
+	return nil
+}
+
+// parseStructuredData parses either the nil value or a list of elements
+//
+//	[SD-ID SP PARAM-NAME="PARAM-VALUE" ...]
+//
+// and returns the rest of the message.
+func (m *message) parseStructuredData(s string) (string, error) {
+	if strings.HasPrefix(s, nilValue) {
+		return s[1:], nil
+	}
+	if !strings.HasPrefix(s, "[") {
+		return "", fmt.Errorf("invalid structured data")
+	}
+
+	m.structuredData = make(map[string]string)
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		i++
+		start := i
+		for i < len(s) && s[i] != ' ' && s[i] != ']' {
+			i++
+		}
+		id := s[start:i]
+		if id == "" || i >= len(s) {
+			return "", fmt.Errorf("invalid structured data element")
+		}
+
+		for i < len(s) && s[i] == ' ' {
+			i++
+			start = i
+			for i < len(s) && s[i] != '=' {
+				i++
+			}
+			if i+1 >= len(s) || s[i+1] != '"' {
+				return "", fmt.Errorf("invalid structured data parameter in %s", id)
+			}
+			param := s[start:i]
+			i += 2
+
+			var value []byte
+			for {
+				if i >= len(s) {
+					return "", fmt.Errorf("unterminated structured data value in %s", id)
+				}
+				c := s[i]
+				if c == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					value = append(value, s[i+1])
+					i += 2
+					continue
+				}
+				i++
+				if c == '"' {
+					break
+				}
+				value = append(value, c)
+			}
+			m.structuredData[id+"_"+param] = string(value)
+		}
+
+		if i >= len(s) || s[i] != ']' {
+			return "", fmt.Errorf("unterminated structured data element %s", id)
+		}
+		i++
+	}
+	return s[i:], nil
+}
+
+// parseRFC3164 parses the part of the message after the priority:
+//
+//	TIMESTAMP SP HOSTNAME SP TAG[PID]: MSG
+//
+// The format is loosely defined, so anything that can't be recognized ends up
+// in the message.
+func (m *message) parseRFC3164(s string, now time.Time) {
+	m.timestamp = now
+
+	const layout = time.Stamp // "Jan _2 15:04:05"
+	if len(s) >= len(layout) {
+		if ts, err := time.ParseInLocation(layout, s[:len(layout)], now.Location()); err == nil {
+			// the year is missing, assume the latest one which isn't in the future
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			m.timestamp = ts
+			s = strings.TrimPrefix(s[len(layout):], " ")
+
+			if i := strings.IndexByte(s, ' '); i > 0 && !strings.HasSuffix(s[:i], ":") {
+				m.hostname, s = s[:i], s[i+1:]
+			}
+		}
+	}
+
+	// TAG is terminated by '[', ':' or a space
+	i := 0
+	for i < len(s) && i <= 48 && s[i] != '[' && s[i] != ':' && s[i] != ' ' {
+		i++
+	}
+	if i > 0 && i < len(s) && (s[i] == '[' || s[i] == ':') {
+		m.appname = s[:i]
+		s = s[i:]
+		if s[0] == '[' {
+			if end := strings.IndexByte(s, ']'); end > 0 {
+				m.procID, s = s[1:end], s[end+1:]
+			}
+		}
+		s = strings.TrimPrefix(s, ":")
+		s = strings.TrimPrefix(s, " ")
+	}
+	m.msg = s
+}
+
+func (m *message) tags() map[string]string {
+	tags := map[string]string{
+		"facility": facilityName(m.facility),
+		"severity": severities[m.severity],
+	}
+	if m.hostname != "" {
+		tags["hostname"] = m.hostname
+	}
+	if m.appname != "" {
+		tags["appname"] = m.appname
+	}
+	return tags
+}
+
+func (m *message) fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"facility_code": int64(m.facility),
+		"severity_code": int64(m.severity),
+	}
+	if m.version > 0 {
+		fields["version"] = int64(m.version)
+	}
+	if m.procID != "" {
+		fields["procid"] = m.procID
+	}
+	if m.msgID != "" {
+		fields["msgid"] = m.msgID
+	}
+	for k, v := range m.structuredData {
+		fields[k] = v
+	}
+	return fields
+}
+
+func facilityName(facility int) string {
+	if facility < len(facilities) {
+		return facilities[facility]
+	}
+	return strconv.Itoa(facility)
+}
+
+func nilToEmpty(s string) string {
+	if s == nilValue {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/zbiljic/optic/internal/listener"
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "syslog"
+	description = `Receive syslog messages over UDP, TCP or unix sockets.`
+)
+
+const (
+	defaultAddress = "udp://:6514"
+
+	// DefaultMaxMessageSize is the default maximum size of a single message.
+	DefaultMaxMessageSize = 64 * 1024
+)
+
+type Syslog struct {
+	// Address to listen on, e.g. "udp://:6514", "tcp://127.0.0.1:6514" or
+	// "unix:///var/run/optic-syslog.sock".
+	Address string `mapstructure:"address"`
+
+	// MaxMessageSize is the maximum size of a single message.
+	MaxMessageSize int `mapstructure:"max_message_size"`
+
+	// ReadTimeout closes stream connections which are idle for longer. Zero
+	// means no timeout.
+	ReadTimeout time.Duration `mapstructure:"read_timeout"`
+
+	acc optic.Accumulator
+
+	ln *listener.Listener
+}
+
+func NewSyslog() optic.Source {
+	return &Syslog{
+		Address:        defaultAddress,
+		MaxMessageSize: DefaultMaxMessageSize,
+	}
+}
+
+func (*Syslog) Kind() string {
+	return name
+}
+
+func (*Syslog) Description() string {
+	return description
+}
+
+func (*Syslog) Gather(acc optic.Accumulator) error {
+	// messages are emitted by the listener goroutines
+	return nil
+}
+
+func (s *Syslog) Start(acc optic.Accumulator) error {
+	if s.MaxMessageSize <= 0 {
+		s.MaxMessageSize = DefaultMaxMessageSize
+	}
+	s.acc = acc
+
+	s.ln = &listener.Listener{
+		Name:           name,
+		Address:        s.Address,
+		ReadTimeout:    s.ReadTimeout,
+		MaxMessageSize: s.MaxMessageSize,
+		// every datagram is a message
+		HandlePacket: func(b []byte, _ net.Addr) {
+			s.handle(b)
+		},
+		HandleConn:  s.handleConn,
+		HandleError: acc.AddError,
+	}
+	return s.ln.Listen()
+}
+
+func (s *Syslog) Stop() {
+	if s.ln != nil {
+		s.ln.Close()
+	}
+}
+
+// handleConn reads messages from a stream connection. Both octet-counting
+// (RFC 6587 3.4.1) and newline delimited framing are detected per message.
+func (s *Syslog) handleConn(conn net.Conn) {
+	r := bufio.NewReaderSize(conn, 4096)
+	for {
+		msg, err := s.readFrame(r)
+		if len(msg) > 0 {
+			s.handle(msg)
+		}
+		if err != nil {
+			if err != io.EOF && !listener.IsClosed(err) {
+				s.acc.AddError(fmt.Errorf("%s: %s", conn.RemoteAddr(), err))
+			}
+			return
+		}
+	}
+}
+
+func (s *Syslog) readFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		lenStr, err := r.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(lenStr[:len(lenStr)-1])
+		if err != nil || n <= 0 || n > s.MaxMessageSize {
+			return nil, fmt.Errorf("invalid message length: %s", lenStr)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	var msg []byte
+	for {
+		line, isPrefix, err := r.ReadLine()
+		msg = append(msg, line...)
+		if len(msg) > s.MaxMessageSize {
+			return nil, fmt.Errorf("message exceeds %d bytes", s.MaxMessageSize)
+		}
+		if err != nil || !isPrefix {
+			return msg, err
+		}
+	}
+}
+
+func (s *Syslog) handle(b []byte) {
+	m, err := parse(b, time.Now())
+	if err != nil {
+		s.acc.AddError(fmt.Errorf("failed to parse syslog message: %s", err))
+		return
+	}
+
+	content := m.msg
+	if content == "" {
+		content = string(b)
+	}
+	s.acc.AddLogLine(s.Address, content, m.tags(), m.fields(), m.timestamp)
+}
+
+func init() {
+	sources.Add(name, NewSyslog)
+}
@@ -0,0 +1,176 @@
+package syslog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestSyslog_impl(t *testing.T) {
+	var _ optic.ServiceSource = new(Syslog)
+}
+
+const rfc5424 = `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high \"x\" \]"] ` + "\ufeff" + `An application event log entry...`
+
+func TestParseRFC5424(t *testing.T) {
+	m, err := parse([]byte(rfc5424), time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC), m.timestamp)
+	assert.Equal(t, map[string]string{
+		"facility": "local4",
+		"severity": "notice",
+		"hostname": "mymachine.example.com",
+		"appname":  "evntslog",
+	}, m.tags())
+	assert.Equal(t, map[string]interface{}{
+		"facility_code":                 int64(20),
+		"severity_code":                 int64(5),
+		"version":                       int64(1),
+		"msgid":                         "ID47",
+		"exampleSDID@32473_iut":         "3",
+		"exampleSDID@32473_eventSource": "Application",
+		"exampleSDID@32473_eventID":     "1011",
+		"examplePriority@32473_class":   `high "x" ]`,
+	}, m.fields())
+	assert.Equal(t, "An application event log entry...", m.msg)
+}
+
+func TestParseRFC5424NilValues(t *testing.T) {
+	now := time.Now()
+	m, err := parse([]byte("<34>1 - - - - - -\n"), now)
+	require.NoError(t, err)
+	assert.Equal(t, now, m.timestamp)
+	assert.Equal(t, map[string]string{"facility": "auth", "severity": "crit"}, m.tags())
+	assert.Equal(t, "", m.msg)
+}
+
+func TestParseRFC3164(t *testing.T) {
+	now := time.Date(2018, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	m, err := parse([]byte("<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8"), now)
+	require.NoError(t, err)
+	// the timestamp would be in the future, so it's from the previous year
+	assert.Equal(t, time.Date(2017, time.October, 11, 22, 14, 15, 0, time.UTC), m.timestamp)
+	assert.Equal(t, map[string]string{
+		"facility": "auth",
+		"severity": "crit",
+		"hostname": "mymachine",
+		"appname":  "su",
+	}, m.tags())
+	assert.Equal(t, "123", m.fields()["procid"])
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", m.msg)
+
+	m, err = parse([]byte("<13>Jan  1 08:00:00 cron: job done"), now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2018, time.January, 1, 8, 0, 0, 0, time.UTC), m.timestamp)
+	assert.Equal(t, "cron", m.appname)
+	assert.Equal(t, "", m.hostname)
+	assert.Equal(t, "job done", m.msg)
+
+	// anything unrecognized is the message
+	m, err = parse([]byte("<13>just a message"), now)
+	require.NoError(t, err)
+	assert.Equal(t, now, m.timestamp)
+	assert.Equal(t, "just a message", m.msg)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"no priority",
+		"<999>1 - - - - - -",
+		"<1>1 2003-10-11",
+		"<1>1 notatime - - - - -",
+		"<1>1 - - - - - [unterminated",
+		`<1>1 - - - - - [id p="unterminated]`,
+	} {
+		_, err := parse([]byte(in), time.Now())
+		assert.Error(t, err, in)
+	}
+}
+
+func startSyslog(t *testing.T, address string) (*Syslog, *testutil.Accumulator) {
+	s := NewSyslog().(*Syslog)
+	s.Address = address
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	return s, acc
+}
+
+func TestListenUDP(t *testing.T) {
+	s, acc := startSyslog(t, "udp://127.0.0.1:0")
+	defer s.Stop()
+
+	conn, err := net.Dial("udp", s.ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(rfc5424))
+	require.NoError(t, err)
+
+	acc.Wait(1)
+	acc.Lock()
+	defer acc.Unlock()
+	require.Len(t, acc.Events, 1)
+	assert.Equal(t, optic.LogLineEvent, acc.Events[0].Type)
+	assert.Equal(t, "udp://127.0.0.1:0", acc.Events[0].Path)
+	assert.Equal(t, "An application event log entry...", acc.Events[0].Content)
+	assert.Equal(t, "evntslog", acc.Events[0].Tags["appname"])
+}
+
+func TestListenTCPFraming(t *testing.T) {
+	s, acc := startSyslog(t, "tcp://127.0.0.1:0")
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.ln.Addr().String())
+	require.NoError(t, err)
+
+	msg := "<13>1 - host app - - - octet counted\nwith newline"
+	fmt.Fprintf(conn, "%d %s", len(msg), msg)
+	fmt.Fprint(conn, "<13>Jan  1 08:00:00 host app: newline delimited\r\n")
+	fmt.Fprint(conn, "<13>1 - host app - - - last\n")
+	conn.Close()
+
+	acc.Wait(3)
+	acc.Lock()
+	defer acc.Unlock()
+	require.Len(t, acc.Events, 3)
+	assert.Equal(t, "octet counted\nwith newline", acc.Events[0].Content)
+	assert.Equal(t, "newline delimited", acc.Events[1].Content)
+	assert.Equal(t, "last", acc.Events[2].Content)
+	assert.Empty(t, acc.Errors)
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "syslog.sock")
+
+	s, acc := startSyslog(t, "unix://"+sock)
+
+	conn, err := net.Dial("unix", sock)
+	require.NoError(t, err)
+	fmt.Fprint(conn, "<13>1 - host app - - - hello\n")
+
+	acc.Wait(1)
+	acc.Lock()
+	assert.Equal(t, "hello", acc.Events[0].Content)
+	acc.Unlock()
+
+	// stop closes open connections
+	s.Stop()
+	conn.Close()
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package tail
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns an identifier of the underlying file, used to detect that a
+// checkpointed path now refers to a different file.
+func fileID(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
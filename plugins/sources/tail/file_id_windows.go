@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package tail
+
+import "os"
+
+// fileID returns an identifier of the underlying file. File identity is not
+// available from os.FileInfo on Windows, so only offsets are checkpointed.
+func fileID(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,492 @@
+package tail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zbiljic/optic/optic"
+	"github.com/zbiljic/optic/optic/logline"
+	"github.com/zbiljic/optic/plugins/sources"
+)
+
+const (
+	name        = "tail"
+	description = `Follow files and emit every new line as a log line.`
+)
+
+const (
+	// DefaultPollInterval is the default interval at which files are checked
+	// for new content.
+	DefaultPollInterval = 250 * time.Millisecond
+
+	readBufferSize = 32 * 1024
+)
+
+type Tail struct {
+	// Files is a list of files or glob patterns to follow.
+	Files []string `mapstructure:"files"`
+	// FromBeginning controls whether files present at startup, without a
+	// checkpoint, are read from the beginning or only from their end.
+	FromBeginning bool `mapstructure:"from_beginning"`
+	// StateFile is the file where read offsets are checkpointed.
+	StateFile string `mapstructure:"state_file"`
+	// PollInterval is the interval at which files are checked for changes.
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+
+	acc optic.Accumulator
+
+	tailers map[string]*tailer
+	// offsets loaded from the state file, consumed when a file is opened
+	state map[string]fileState
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+}
+
+// fileState is a checkpoint entry persisted in the state file.
+type fileState struct {
+	Offset int64  `json:"offset"`
+	ID     uint64 `json:"id,omitempty"`
+}
+
+// tailer follows a single file.
+type tailer struct {
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	next    int64 // offset just after the last emitted line
+	pending []byte
+
+	// Guards the delivery state, lines are resolved by the sinks
+	mu sync.Mutex
+	// offset just after the last line delivered along with all lines
+	// before it, this is what is checkpointed
+	offset int64
+	// emitted lines which weren't delivered yet, in file order
+	inflight []*inflightLine
+	// set once a line failed, the offset then stays before it
+	failed bool
+	// incremented when the file is read again from its start
+	gen int
+}
+
+// inflightLine is an emitted line, waiting for its delivery.
+type inflightLine struct {
+	gen       int
+	end       int64
+	delivered bool
+}
+
+func NewTail() optic.Source {
+	return &Tail{
+		PollInterval: DefaultPollInterval,
+	}
+}
+
+func (*Tail) Kind() string {
+	return name
+}
+
+func (*Tail) Description() string {
+	return description
+}
+
+func (*Tail) Gather(acc optic.Accumulator) error {
+	// lines are emitted by the service goroutine
+	return nil
+}
+
+func (t *Tail) Start(acc optic.Accumulator) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.Files) == 0 {
+		return fmt.Errorf("no files configured")
+	}
+	if t.PollInterval <= 0 {
+		t.PollInterval = DefaultPollInterval
+	}
+
+	t.acc = acc
+	t.tailers = make(map[string]*tailer)
+	t.done = make(chan struct{})
+
+	state, err := t.loadState()
+	if err != nil {
+		return err
+	}
+	t.state = state
+
+	// files present at startup are handled differently than the ones that
+	// show up later, so the first pass is done synchronously
+	t.poll(true)
+
+	t.wg.Add(1)
+	go t.run()
+
+	return nil
+}
+
+func (t *Tail) Stop() {
+	t.mu.Lock()
+	if t.done == nil {
+		t.mu.Unlock()
+		return
+	}
+	close(t.done)
+	t.mu.Unlock()
+
+	t.wg.Wait()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// pick up anything written since the last poll
+	t.poll(false)
+
+	if err := t.saveState(); err != nil {
+		t.acc.AddError(err)
+	}
+	for _, tl := range t.tailers {
+		tl.file.Close()
+	}
+	t.tailers = nil
+	t.done = nil
+}
+
+func (t *Tail) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			t.poll(false)
+			if err := t.saveState(); err != nil {
+				t.acc.AddError(err)
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+// poll discovers new files, reads new lines from all followed files and
+// handles rotation and truncation.
+func (t *Tail) poll(startup bool) {
+	for _, path := range t.match() {
+		if _, ok := t.tailers[path]; ok {
+			continue
+		}
+		tl, err := t.open(path, startup)
+		if err != nil {
+			t.acc.AddError(err)
+			continue
+		}
+		t.tailers[path] = tl
+	}
+
+	for path, tl := range t.tailers {
+		info, statErr := os.Stat(path)
+		switch {
+		case statErr != nil || !os.SameFile(info, tl.info):
+			// rotated by rename, or removed; the old file is read to its end
+			// before it is closed, and the replacement (if any) is read from
+			// the start
+			if err := t.read(tl); err != nil {
+				t.acc.AddError(fmt.Errorf("reading %s: %s", path, err))
+			}
+			t.flushPending(tl)
+			tl.file.Close()
+			delete(t.tailers, path)
+
+			if statErr != nil {
+				continue
+			}
+			ntl, err := t.openAt(path, 0)
+			if err != nil {
+				t.acc.AddError(err)
+				continue
+			}
+			t.tailers[path] = ntl
+			if err := t.read(ntl); err != nil {
+				t.acc.AddError(fmt.Errorf("reading %s: %s", path, err))
+			}
+		case info.Size() < tl.next+int64(len(tl.pending)):
+			// truncated in place
+			log.Printf("DEBUG [%s] file truncated: %s", name, path)
+			if _, err := tl.file.Seek(0, io.SeekStart); err != nil {
+				t.acc.AddError(err)
+				continue
+			}
+			tl.reset()
+			tl.info = info
+			if err := t.read(tl); err != nil {
+				t.acc.AddError(fmt.Errorf("reading %s: %s", path, err))
+			}
+		default:
+			tl.info = info
+			if err := t.read(tl); err != nil {
+				t.acc.AddError(fmt.Errorf("reading %s: %s", path, err))
+			}
+		}
+	}
+}
+
+// match returns all files matching the configured patterns.
+func (t *Tail) match() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, pattern := range t.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.acc.AddError(fmt.Errorf("invalid pattern %s: %s", pattern, err))
+			continue
+		}
+		for _, m := range matches {
+			if seen[m] {
+				continue
+			}
+			if info, err := os.Stat(m); err != nil || info.IsDir() {
+				continue
+			}
+			seen[m] = true
+			paths = append(paths, m)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// open opens the file and positions it at the offset from which reading
+// should continue.
+func (t *Tail) open(path string, startup bool) (*tailer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int64
+	if st, ok := t.state[path]; ok {
+		delete(t.state, path)
+		if (st.ID == 0 || st.ID == fileID(info)) && st.Offset <= info.Size() {
+			offset = st.Offset
+		}
+	} else if startup && !t.FromBeginning {
+		offset = info.Size()
+	}
+
+	return t.openAt(path, offset)
+}
+
+func (t *Tail) openAt(path string, offset int64) (*tailer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	log.Printf("DEBUG [%s] following %s from offset %d", name, path, offset)
+	return &tailer{
+		path:   path,
+		file:   f,
+		info:   info,
+		next:   offset,
+		offset: offset,
+	}, nil
+}
+
+// read reads all complete lines available in the file.
+func (t *Tail) read(tl *tailer) error {
+	buf := make([]byte, readBufferSize)
+	for {
+		n, err := tl.file.Read(buf)
+		if n > 0 {
+			tl.pending = append(tl.pending, buf[:n]...)
+			t.emitLines(tl)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (t *Tail) emitLines(tl *tailer) {
+	for {
+		i := bytes.IndexByte(tl.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := tl.pending[:i]
+		tl.pending = tl.pending[i+1:]
+		tl.next += int64(i + 1)
+		t.emit(tl, line)
+	}
+	// don't keep the already consumed part of the buffer around
+	tl.pending = append([]byte(nil), tl.pending...)
+}
+
+// flushPending emits the last line of a file that doesn't end with a newline.
+func (t *Tail) flushPending(tl *tailer) {
+	if len(tl.pending) == 0 {
+		return
+	}
+	tl.next += int64(len(tl.pending))
+	t.emit(tl, tl.pending)
+	tl.pending = nil
+}
+
+// emit adds the line ending at the current read offset of the file. The
+// offset is checkpointed only once the line was delivered.
+func (t *Tail) emit(tl *tailer, line []byte) {
+	content := strings.TrimSuffix(string(line), "\r")
+	l := tl.track(tl.next)
+	if content == "" {
+		tl.resolve(l, true)
+		return
+	}
+	ll, err := logline.New(tl.path, content, nil, nil, time.Now())
+	if err != nil {
+		t.acc.AddError(err)
+		tl.resolve(l, false)
+		return
+	}
+	ll.SetDelivery(optic.NewDelivery(func(delivered bool) {
+		tl.resolve(l, delivered)
+	}))
+	t.acc.AddEvent(ll)
+}
+
+// track starts tracking the delivery of a line ending at the given offset.
+func (tl *tailer) track(end int64) *inflightLine {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	l := &inflightLine{gen: tl.gen, end: end}
+	tl.inflight = append(tl.inflight, l)
+	return l
+}
+
+// resolve records the delivery of a line, and moves the checkpointed offset
+// past every line delivered along with all lines before it.
+func (tl *tailer) resolve(l *inflightLine, delivered bool) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if l.gen != tl.gen {
+		// read before the file was truncated
+		return
+	}
+	if !delivered {
+		if !tl.failed {
+			log.Printf("WARNING [%s] failed to deliver a line of %s, keeping offset %d",
+				name, tl.path, tl.offset)
+		}
+		tl.failed = true
+		return
+	}
+	l.delivered = true
+	if tl.failed {
+		return
+	}
+	i := 0
+	for ; i < len(tl.inflight) && tl.inflight[i].delivered; i++ {
+		tl.offset = tl.inflight[i].end
+	}
+	tl.inflight = append(tl.inflight[:0], tl.inflight[i:]...)
+}
+
+// reset starts over at the beginning of the file. Lines still in flight
+// no longer move the offset.
+func (tl *tailer) reset() {
+	tl.next = 0
+	tl.pending = nil
+
+	tl.mu.Lock()
+	tl.offset = 0
+	tl.inflight = nil
+	tl.failed = false
+	tl.gen++
+	tl.mu.Unlock()
+}
+
+// checkpoint returns the offset up to which all lines were delivered.
+func (tl *tailer) checkpoint() int64 {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return tl.offset
+}
+
+func (t *Tail) loadState() (map[string]fileState, error) {
+	state := make(map[string]fileState)
+	if t.StateFile == "" {
+		return state, nil
+	}
+
+	b, err := ioutil.ReadFile(t.StateFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return state, nil
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %s", t.StateFile, err)
+	}
+	return state, nil
+}
+
+// saveState atomically writes offsets of all followed files.
+func (t *Tail) saveState() error {
+	if t.StateFile == "" {
+		return nil
+	}
+
+	state := make(map[string]fileState, len(t.tailers))
+	for path, tl := range t.tailers {
+		state[path] = fileState{
+			Offset: tl.checkpoint(),
+			ID:     fileID(tl.info),
+		}
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := t.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.StateFile)
+}
+
+func init() {
+	sources.Add(name, NewTail)
+}
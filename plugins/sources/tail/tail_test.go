@@ -0,0 +1,276 @@
+package tail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zbiljic/optic/internal/testutil"
+	"github.com/zbiljic/optic/optic"
+)
+
+// Check the interfaces are satisfied
+func TestTail_impl(t *testing.T) {
+	var _ optic.ServiceSource = new(Tail)
+}
+
+func newTestTail(dir string, files ...string) *Tail {
+	t := NewTail().(*Tail)
+	t.Files = files
+	t.FromBeginning = true
+	t.StateFile = filepath.Join(dir, "state.json")
+	t.PollInterval = 10 * time.Millisecond
+	return t
+}
+
+func appendFile(t *testing.T, path, content string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+}
+
+// deliveryAccumulator acks the added events if ack is set, and otherwise
+// keeps them to be resolved by the test.
+type deliveryAccumulator struct {
+	testutil.Accumulator
+	ack    bool
+	events []optic.Event
+}
+
+func (a *deliveryAccumulator) AddEvent(event optic.Event) {
+	a.Accumulator.AddEvent(event)
+	if a.ack {
+		optic.Ack(event)
+		return
+	}
+	a.Lock()
+	a.events = append(a.events, event)
+	a.Unlock()
+}
+
+func contents(acc *testutil.Accumulator) []string {
+	acc.Lock()
+	defer acc.Unlock()
+	var out []string
+	for _, e := range acc.Events {
+		out = append(out, e.Content)
+	}
+	return out
+}
+
+func TestTailFromBeginning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	appendFile(t, path, "first\nsecond\n")
+
+	tl := newTestTail(dir, path)
+	acc := &testutil.Accumulator{}
+	require.NoError(t, tl.Start(acc))
+	defer tl.Stop()
+
+	acc.Wait(2)
+	assert.Equal(t, []string{"first", "second"}, contents(acc))
+	assert.Equal(t, optic.LogLineEvent, acc.Events[0].Type)
+	assert.Equal(t, path, acc.Events[0].Path)
+
+	// partial lines are emitted only once they are complete
+	appendFile(t, path, "thi")
+	time.Sleep(50 * time.Millisecond)
+	assert.EqualValues(t, 2, acc.Count())
+	appendFile(t, path, "rd\n")
+	acc.Wait(3)
+	assert.Equal(t, []string{"first", "second", "third"}, contents(acc))
+}
+
+func TestTailFromEnd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	appendFile(t, path, "old\n")
+
+	tl := newTestTail(dir, path)
+	tl.FromBeginning = false
+	acc := &testutil.Accumulator{}
+	require.NoError(t, tl.Start(acc))
+	defer tl.Stop()
+
+	appendFile(t, path, "new\n")
+	acc.Wait(1)
+	assert.Equal(t, []string{"new"}, contents(acc))
+}
+
+func TestTailGlobNewFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tl := newTestTail(dir, filepath.Join(dir, "*.log"))
+	tl.FromBeginning = false
+	acc := &testutil.Accumulator{}
+	require.NoError(t, tl.Start(acc))
+	defer tl.Stop()
+
+	// files created after start are always read from the beginning
+	path := filepath.Join(dir, "late.log")
+	appendFile(t, path, "hello\n")
+	appendFile(t, filepath.Join(dir, "ignored.txt"), "nope\n")
+
+	acc.Wait(1)
+	assert.Equal(t, []string{"hello"}, contents(acc))
+	assert.Equal(t, path, acc.Events[0].Path)
+}
+
+func TestTailRotateRename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	appendFile(t, path, "one\n")
+
+	tl := newTestTail(dir, path)
+	acc := &testutil.Accumulator{}
+	require.NoError(t, tl.Start(acc))
+	defer tl.Stop()
+	acc.Wait(1)
+
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path, "two\n")
+
+	acc.Wait(2)
+	assert.Equal(t, []string{"one", "two"}, contents(acc))
+}
+
+func TestTailRotateDrainsOldFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	appendFile(t, path, "one\n")
+
+	// polled by hand, so the file is written and rotated between two polls
+	tl := newTestTail(dir, path)
+	acc := &testutil.Accumulator{}
+	tl.acc = acc
+	tl.tailers = make(map[string]*tailer)
+	tl.state = make(map[string]fileState)
+	tl.poll(true)
+	defer func() {
+		for _, f := range tl.tailers {
+			f.file.Close()
+		}
+	}()
+
+	appendFile(t, path, "two\n")
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path, "three\n")
+
+	tl.poll(false)
+	assert.Equal(t, []string{"one", "two", "three"}, contents(acc))
+}
+
+func TestTailRotateTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	appendFile(t, path, "a long first line\n")
+
+	tl := newTestTail(dir, path)
+	acc := &testutil.Accumulator{}
+	require.NoError(t, tl.Start(acc))
+	defer tl.Stop()
+	acc.Wait(1)
+
+	require.NoError(t, os.Truncate(path, 0))
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "short\n")
+
+	acc.Wait(2)
+	assert.Equal(t, []string{"a long first line", "short"}, contents(acc))
+}
+
+func TestTailResumeFromState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	appendFile(t, path, "one\ntwo\n")
+
+	tl := newTestTail(dir, path)
+	acc := &deliveryAccumulator{ack: true}
+	require.NoError(t, tl.Start(acc))
+	acc.Wait(2)
+	tl.Stop()
+
+	// written while the source was not running
+	appendFile(t, path, "three\n")
+
+	tl = newTestTail(dir, path)
+	acc = &deliveryAccumulator{ack: true}
+	require.NoError(t, tl.Start(acc))
+	defer tl.Stop()
+
+	acc.Wait(1)
+	appendFile(t, path, "four\n")
+	acc.Wait(2)
+	assert.Equal(t, []string{"three", "four"}, contents(&acc.Accumulator))
+}
+
+func TestTailCheckpointDelivered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	appendFile(t, path, "one\ntwo\nthree\n")
+
+	tl := newTestTail(dir, path)
+	acc := &deliveryAccumulator{}
+	tl.acc = acc
+	tl.tailers = make(map[string]*tailer)
+	tl.state = make(map[string]fileState)
+	tl.poll(true)
+	defer func() {
+		for _, f := range tl.tailers {
+			f.file.Close()
+		}
+	}()
+	require.Len(t, acc.events, 3)
+	f := tl.tailers[path]
+	assert.EqualValues(t, 0, f.checkpoint())
+
+	// the offset only moves past lines delivered along with all before them
+	optic.Ack(acc.events[1])
+	assert.EqualValues(t, 0, f.checkpoint())
+	optic.Ack(acc.events[0])
+	assert.EqualValues(t, 8, f.checkpoint())
+
+	// a failed line keeps the offset before it, so it is read again
+	optic.Nack(acc.events[2])
+	appendFile(t, path, "four\n")
+	tl.poll(false)
+	require.Len(t, acc.events, 4)
+	optic.Ack(acc.events[3])
+	assert.EqualValues(t, 8, f.checkpoint())
+
+	require.NoError(t, tl.saveState())
+	state, err := tl.loadState()
+	require.NoError(t, err)
+	assert.EqualValues(t, 8, state[path].Offset)
+}